@@ -0,0 +1,223 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+// テーブルへの複数の変更をまとめて行うために使用される。
+// TableのBatchメソッドのコールバック関数に渡される。
+// 変更はメモリ上のノードに対して行われ、ファイルへの書き込みはBatchの終了時にまとめて行われる。
+// コールバック関数の終了後に*Batchのメソッドを呼び出すとErrInvalidOperationのエラーになる。
+type Batch struct {
+	table *Table
+	tree  *tableTree
+	// コールバック関数が終了したかどうか
+	closed bool
+	// 木を変更する操作の途中でpanicしてメモリ上の木が中途半端な状態になったかどうか
+	failed bool
+	// 失敗した操作のエラー
+	err error
+	// Batchの開始時のテーブルの情報(失敗した場合に戻す)
+	nodeCount int
+	counter   uint64
+}
+
+// 複数の変更をまとめて行う。
+// fnに渡される*Batchを使ってInsertやReplaceやDeleteを行うと、
+// 変更されたノードや空き領域の情報やテーブル情報のファイルへの書き込みはfnの終了後にまとめて1回だけ行われる。
+// fnの中ではテーブルのメソッドではなく*Batchのメソッドを使う必要がある（テーブルのInsertなどはErrInvalidOperationのエラーになる）。
+// トランザクションではないため、fnがエラーを返した場合やpanicした場合もそれまでに行った変更はファイルに書き込まれる。
+// fnがpanicした場合はpanicの値が戻り値のエラーとして返る。
+// ただし*Batchのメソッドの処理の途中でエラー(IOエラーなど)が起きた場合は、木の変更が中途半端な状態になっている可能性があるため
+// それ以降の*Batchのメソッドの呼び出しはErrInvalidOperationのエラーになり、Batchの中で行った変更は全て破棄される(ファイルに書き込まれない)。
+// この場合はfnがエラーを返さなくても戻り値のエラーにはそのエラーが返る。
+// fnが返したエラーはそのまま戻り値のエラーとして返る。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+//
+//	err := table.Batch(func(b *unkodb.Batch) error {
+//		for _, food := range foods {
+//			_, err := b.Insert(food)
+//			if err != nil {
+//				return err
+//			}
+//		}
+//		return b.Delete(unkodb.CounterType(3))
+//	})
+func (table *Table) Batch(fn func(b *Batch) error) (err error) {
+	if table.isIterating() {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var tree *tableTree
+	tree, err = newTableTree(table, false)
+	if err != nil {
+		return
+	}
+	segManager := table.db.segManager
+	tree.batching = true
+	table.batching = true
+	segManager.beginBatch()
+	table.beginIteration()
+	b := &Batch{
+		table:     table,
+		tree:      tree,
+		nodeCount: table.nodeCount,
+		counter:   table.counter,
+	}
+	// fnがpanicした場合もメモリ上の変更を書き込まないとファイルの状態と食い違うので
+	// 書き込みはdeferで行う(panicはこの後にcatchErrorで回収される)
+	defer func() {
+		b.closed = true
+		table.endIteration()
+		tree.batching = false
+		table.batching = false
+		segManager.endBatch()
+		var flushErr error
+		if b.failed {
+			flushErr = b.abort()
+		} else {
+			flushErr = b.flush()
+		}
+		if flushErr != nil {
+			err = flushErr
+		}
+	}()
+	err = fn(b)
+	return
+}
+
+// Batchの中で行った変更をファイルに書き込む
+func (b *Batch) flush() (err error) {
+	// 空き領域の木の書き込みとテーブルのノードの書き込みは同じセグメントに対して行われることはないので順番はどちらでもよい。
+	// 空き領域の木から取り出されて再利用されたセグメントのノードはidleSegmentTree.ReleaseNodeで
+	// 空き領域の木のキャッシュから外されているので、空き領域の木の書き込みはテーブルのノードのセグメントを上書きしない。
+	// 木から削除されたテーブルのノードもtableTree.ReleaseNodeで空き領域の木に入れる前にテーブルの木のキャッシュから外されているので、
+	// テーブルのノードの書き込みは空き領域のセグメントを上書きしない。
+	err = b.table.db.segManager.flushIdleTree()
+	if err != nil {
+		return
+	}
+	err = b.tree.flush()
+	if err != nil {
+		return
+	}
+	err = b.table.flush()
+	if err != nil {
+		return
+	}
+	// 木から外れたノードのセグメントはファイル上の木から参照されなくなってから空き領域にする
+	err = b.table.db.segManager.ReleaseSegmentsByAddress(b.tree.releasedAddresses)
+	return
+}
+
+// Batchの中で行った変更を破棄して失敗した操作のエラーを返す
+// 書き込まれていないノードのために新たに確保したセグメントはどこからも参照されない領域になる
+func (b *Batch) abort() (err error) {
+	b.table.nodeCount = b.nodeCount
+	b.table.counter = b.counter
+	// 中途半端な木のノードのセグメントのキャッシュを除去する
+	b.tree.invalidateCacheOnAbort(&b.err)
+	// 空き領域の木はそれ自体では整合していて、取り出したセグメントの分割はファイルに書き込まれているので書き込んでおく
+	// 木から外れたノードのセグメントはファイル上の木から参照されたままなので空き領域にしない
+	err = b.table.db.segManager.flushIdleTree()
+	if err != nil {
+		return
+	}
+	err = b.err
+	return
+}
+
+// 木を変更する操作を行う
+// 操作の途中でpanicした場合はメモリ上の木が中途半端に変更されている可能性があるのでBatchを失敗扱いにする
+func (b *Batch) modify(operation func() error) (err error) {
+	completed := false
+	defer func() {
+		if !completed {
+			b.failed = true
+			b.err = err
+		}
+	}()
+	if !debugMode {
+		defer catchError(&err)
+	}
+	err = operation()
+	completed = true
+	return
+}
+
+// Batchの中でデータを挿入する。
+// 戻り値やエラーはTableのInsertと同じ。
+func (b *Batch) Insert(data any) (r *Record, err error) {
+	if b.closed || b.failed {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var record any
+	record, err = b.table.parseRecord(data)
+	if err != nil {
+		return
+	}
+	err = b.modify(func() (err error) {
+		r, err = b.table.insert(b.tree, record)
+		return
+	})
+	return
+}
+
+// Batchの中でキーに対応するデータを置き換える。
+// 戻り値やエラーはTableのReplaceと同じ。
+func (b *Batch) Replace(data any) (r *Record, err error) {
+	if b.closed || b.failed {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var record any
+	record, err = b.table.parseRecord(data)
+	if err != nil {
+		return
+	}
+	err = b.modify(func() (err error) {
+		r, err = b.table.replace(b.tree, record)
+		return
+	})
+	return
+}
+
+// Batchの中で指定したキーに対応するデータとキーを削除する。
+// 戻り値のエラーはTableのDeleteと同じ。
+func (b *Batch) Delete(key any) (err error) {
+	if b.closed || b.failed {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	err = b.modify(func() error {
+		return b.table.delete(b.tree, key)
+	})
+	return
+}
+
+// Batchの中でキーに対応するデータを取得する。
+// Batchの中で行った変更はまだファイルに書き込まれていないため、TableのFindではなくこちらを使う必要がある。
+// 戻り値やエラーはTableのFindと同じ。
+func (b *Batch) Find(key any) (r *Record, err error) {
+	if b.closed || b.failed {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	r, err = b.table.find(b.tree, key)
+	return
+}
@@ -0,0 +1,372 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTable_Batch(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.TextColumn("body")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const N = 300
+	err = table.Batch(func(b *Batch) error {
+		for i := 1; i <= N; i++ {
+			_, err := b.Insert(map[string]any{"body": fmt.Sprint("note", i)})
+			if err != nil {
+				return err
+			}
+		}
+		// 書き込み前の変更も参照できる
+		r, err := b.Find(CounterType(10))
+		if err != nil {
+			return err
+		}
+		if r == nil || r.Column("body") != any("note10") {
+			return fmt.Errorf("wrong record %#v", r)
+		}
+		for i := 1; i <= N; i += 2 {
+			err := b.Delete(CounterType(i))
+			if err != nil {
+				return err
+			}
+		}
+		for i := 2; i <= N; i += 4 {
+			// 領域の再確保が起きる大きさに置き換える
+			_, err := b.Replace(map[string]any{
+				"id":   CounterType(i),
+				"body": strings.Repeat(fmt.Sprint("long", i), 50),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := table.Insert(map[string]any{"body": "x"}); err != ErrInvalidOperation {
+			return fmt.Errorf("wrong error %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(table *Table) {
+		if table.Count() != N/2 {
+			t.Fatalf("wrong count %d", table.Count())
+		}
+		id := 2
+		err := table.IterateAll(func(r *Record) (_ bool) {
+			if r.Key() != any(CounterType(id)) {
+				t.Fatalf("wrong key %#v (expected %d)", r.Key(), id)
+			}
+			body := fmt.Sprint("note", id)
+			if id%4 == 2 {
+				body = strings.Repeat(fmt.Sprint("long", id), 50)
+			}
+			if r.Column("body") != any(body) {
+				t.Fatalf("wrong body %#v", r.Column("body"))
+			}
+			id += 2
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("notes")
+	check(table)
+
+	// 空き領域の木が壊れていないこと
+	for i := 1; i <= N; i += 2 {
+		_, err := table.Insert(map[string]any{"body": fmt.Sprint("note", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errStop := errors.New("stop")
+	err = table.Batch(func(b *Batch) error {
+		_, err := b.Insert(map[string]any{"body": "applied"})
+		if err != nil {
+			return err
+		}
+		return errStop
+	})
+	if err != errStop {
+		t.Fatalf("wrong error %v", err)
+	}
+	if table.Count() != N+1 {
+		t.Fatalf("wrong count %d", table.Count())
+	}
+	_, err = table.Insert(map[string]any{"body": "after batch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 終了後に*Batchを使うことはできない
+	var kept *Batch
+	err = table.Batch(func(b *Batch) error {
+		kept = b
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = kept.Insert(map[string]any{"body": "stale"}); err != ErrInvalidOperation {
+		t.Fatalf("wrong error %v", err)
+	}
+	if _, err = kept.Replace(map[string]any{"id": CounterType(2), "body": "stale"}); err != ErrInvalidOperation {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = kept.Delete(CounterType(2)); err != ErrInvalidOperation {
+		t.Fatalf("wrong error %v", err)
+	}
+	if _, err = kept.Find(CounterType(2)); err != ErrInvalidOperation {
+		t.Fatalf("wrong error %v", err)
+	}
+	if table.Count() != N+2 {
+		t.Fatalf("wrong count %d", table.Count())
+	}
+}
+
+func TestTable_Batch_panic(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.TextColumn("body")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const N = 100
+	errPanic := errors.New("panic in batch")
+	err = table.Batch(func(b *Batch) error {
+		for i := 1; i <= N; i++ {
+			_, err := b.Insert(map[string]any{"body": fmt.Sprint("note", i)})
+			if err != nil {
+				return err
+			}
+		}
+		for i := 1; i <= N; i += 2 {
+			err := b.Delete(CounterType(i))
+			if err != nil {
+				return err
+			}
+		}
+		panic(errPanic)
+	})
+	if err != errPanic {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	// panicまでに行った変更がファイルに書き込まれていること
+	check := func(table *Table) {
+		if table.Count() != N/2 {
+			t.Fatalf("wrong count %d", table.Count())
+		}
+		id := 2
+		err := table.IterateAll(func(r *Record) (_ bool) {
+			if r.Key() != any(CounterType(id)) || r.Column("body") != any(fmt.Sprint("note", id)) {
+				t.Fatalf("wrong record %#v", r.Take())
+			}
+			id += 2
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("notes")
+	check(table)
+
+	// 空き領域の木が壊れていないこと
+	for i := 1; i <= N; i++ {
+		_, err := table.Insert(map[string]any{"body": fmt.Sprint("note", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if table.Count() != N+N/2 {
+		t.Fatalf("wrong count %d", table.Count())
+	}
+}
+
+// 値によってEncodeがエラーになるColumnCodec
+type testRejectingPointCodec struct {
+	testPointCodec
+}
+
+func (c testRejectingPointCodec) Encode(value any) ([]byte, error) {
+	if value.(testPoint).X < 0 {
+		return nil, fmt.Errorf("rejected point")
+	}
+	return c.testPointCodec.Encode(value)
+}
+
+func TestTable_Batch_failure(t *testing.T) {
+	if err := RegisterColumnType("TestRejectingPoint", testRejectingPointCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(columnCodecs, "TestRejectingPoint")
+
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("spots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.CustomColumn("point", "TestRejectingPoint")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const N = 50
+	for i := 1; i <= N; i++ {
+		_, err = table.Insert(map[string]any{"point": testPoint{int32(i), 0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 操作の途中でエラーが起きた場合はBatchの中の変更は全て破棄される
+	var opErr error
+	err = table.Batch(func(b *Batch) error {
+		for i := 1; i <= N; i += 2 {
+			err := b.Delete(CounterType(i))
+			if err != nil {
+				return err
+			}
+		}
+		for i := 1; i <= N; i++ {
+			_, err := b.Insert(map[string]any{"point": testPoint{int32(N + i), 0}})
+			if err != nil {
+				return err
+			}
+		}
+		_, opErr = b.Replace(map[string]any{"id": CounterType(2), "point": testPoint{-1, 0}})
+		if opErr == nil {
+			return fmt.Errorf("no error")
+		}
+		// 失敗した後の操作は受け付けない
+		if _, err := b.Insert(map[string]any{"point": testPoint{1, 1}}); err != ErrInvalidOperation {
+			return fmt.Errorf("wrong error %v", err)
+		}
+		if _, err := b.Find(CounterType(2)); err != ErrInvalidOperation {
+			return fmt.Errorf("wrong error %v", err)
+		}
+		// 失敗したエラーを無視してもBatchのエラーになる
+		return nil
+	})
+	if err == nil || err != opErr {
+		t.Fatalf("wrong error %v %v", err, opErr)
+	}
+
+	check := func(table *Table) {
+		if table.Count() != N {
+			t.Fatalf("wrong count %d", table.Count())
+		}
+		id := 1
+		err := table.IterateAll(func(r *Record) (_ bool) {
+			if r.Key() != any(CounterType(id)) || r.Column("point") != any(testPoint{int32(id), 0}) {
+				t.Fatalf("wrong record %#v", r.Take())
+			}
+			id++
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("spots")
+	check(table)
+
+	// 空き領域の木もテーブルの木も壊れていないこと
+	for i := 1; i <= N; i++ {
+		r, err := table.Insert(map[string]any{"point": testPoint{int32(N + i), 0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Key() != any(CounterType(N+i)) {
+			t.Fatalf("wrong key %v", r.Key())
+		}
+	}
+	for i := 1; i <= N; i += 2 {
+		err = table.Delete(CounterType(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if table.Count() != N+N/2 {
+		t.Fatalf("wrong count %d", table.Count())
+	}
+}
@@ -0,0 +1,187 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type benchmarkItem struct {
+	Id    CounterType `unkodb:"id,key@Counter"`
+	Name  string      `unkodb:"name,ShortString"`
+	Price int64       `unkodb:"price,Int64"`
+}
+
+const benchmarkItemCount = 1000
+
+func newBenchmarkTable(b *testing.B) *Table {
+	tempfile, err := os.Create(filepath.Join(b.TempDir(), "bench.unkodb"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { tempfile.Close() })
+
+	db, err := Create(tempfile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	table, err := db.CreateTableByTaggedStruct("items", (*benchmarkItem)(nil))
+	if err != nil {
+		b.Fatal(err)
+	}
+	i := 0
+	_, err = table.BulkLoad(func() (data any, ok bool) {
+		if i < benchmarkItemCount {
+			data, ok = &benchmarkItem{Name: fmt.Sprint("item", i), Price: int64(i)}, true
+			i++
+		}
+		return
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return table
+}
+
+func benchmarkKey(i int) CounterType {
+	return CounterType(i%benchmarkItemCount + 1)
+}
+
+func BenchmarkTable_Find(b *testing.B) {
+	table := newBenchmarkTable(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := table.Find(benchmarkKey(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTable_Find_taggedStruct(b *testing.B) {
+	table := newBenchmarkTable(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := table.Find(&benchmarkItem{Id: benchmarkKey(i)})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTable_FindCounter(b *testing.B) {
+	table := newBenchmarkTable(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := table.FindCounter(benchmarkKey(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindKey(b *testing.B) {
+	table := newBenchmarkTable(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := FindKey(table, benchmarkKey(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTable_FindColumns(b *testing.B) {
+	table := newBenchmarkTable(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := table.FindColumns(benchmarkKey(i), "price")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTable_Insert(b *testing.B) {
+	table := newBenchmarkTable(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := table.Insert(&benchmarkItem{Name: "new item", Price: int64(i)})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTable_Batch_Insert(b *testing.B) {
+	table := newBenchmarkTable(b)
+	b.ResetTimer()
+	err := table.Batch(func(batch *Batch) error {
+		for i := 0; i < b.N; i++ {
+			_, err := batch.Insert(&benchmarkItem{Name: "new item", Price: int64(i)})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+func newBenchmarkGeneratedFoods(b *testing.B) *TypedTable[generatedFood] {
+	tempfile, err := os.Create(filepath.Join(b.TempDir(), "bench.unkodb"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { tempfile.Close() })
+
+	db, err := Create(tempfile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	table, err := db.CreateTableByTaggedStruct("foods", (*generatedFood)(nil))
+	if err != nil {
+		b.Fatal(err)
+	}
+	foods, err := Typed[generatedFood](table)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < benchmarkItemCount; i++ {
+		price := int64(i)
+		_, err = foods.Insert(&generatedFood{Name: fmt.Sprint("food", i), Price: &price, Image: []byte{1, 2, 3}})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	return foods
+}
+
+func BenchmarkTypedTable_Find_generated(b *testing.B) {
+	foods := newBenchmarkGeneratedFoods(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := foods.Find(benchmarkKey(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTypedTable_Insert_generated(b *testing.B) {
+	foods := newBenchmarkGeneratedFoods(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		price := int64(i)
+		_, err := foods.Insert(&generatedFood{Name: "new food", Price: &price, Image: []byte{1, 2, 3}})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -77,6 +77,17 @@ func (decoder *byteDecoder) RawBytes(buffer []byte) error {
 	return nil
 }
 
+func (decoder *byteDecoder) Skip(length int) error {
+	n, err := io.CopyN(io.Discard, decoder.reader, int64(length))
+	if err != nil {
+		return err
+	}
+	if n != int64(length) {
+		return fmt.Errorf("cannot skip data (length: %d, skipped: %d)", length, n)
+	}
+	return nil
+}
+
 func (encoder *byteEncoder) Value(src any) error {
 	return binary.Write(encoder.writer, encoder.order, src)
 }
@@ -125,6 +136,22 @@ func (decoder *byteDecoder) Uint32(dst *uint32) error {
 	return decoder.Value(dst)
 }
 
+func (encoder *byteEncoder) Int64(src int64) error {
+	return encoder.Value(src)
+}
+
+func (decoder *byteDecoder) Int64(dst *int64) error {
+	return decoder.Value(dst)
+}
+
+func (encoder *byteEncoder) Uint64(src uint64) error {
+	return encoder.Value(src)
+}
+
+func (decoder *byteDecoder) Uint64(dst *uint64) error {
+	return decoder.Value(dst)
+}
+
 func (encoder *byteEncoder) WriteShortString(s string) (err error) {
 	buf := []byte(s)
 	if len(buf) > shortStringMaximumDataByteSize {
@@ -172,6 +199,43 @@ func (encoder *byteEncoder) WriteColumnSpec(col Column) (err error) {
 		err = encoder.Uint8(c.size)
 	case *fixedSizeLongBytesColumn:
 		err = encoder.Uint16(c.size)
+	case *decimalColumn:
+		err = encoder.Uint8(c.precision)
+		if err != nil {
+			return
+		}
+		err = encoder.Uint8(c.scale)
+	case listColumnSpec:
+		elem, maxCount := c.listSpec()
+		err = encoder.Uint8(uint8(elem.Type()))
+		if err != nil {
+			return
+		}
+		err = encoder.Uint16(maxCount)
+	case *structColumn:
+		err = encoder.Uint16(uint16(len(c.columns)))
+		if err != nil {
+			return
+		}
+		for _, sub := range c.columns {
+			err = encoder.WriteColumnSpec(sub)
+			if err != nil {
+				return
+			}
+		}
+	case *customColumn:
+		err = encoder.WriteShortString(c.typeName)
+	case *enumColumn:
+		err = encoder.Uint16(uint16(len(c.values)))
+		if err != nil {
+			return
+		}
+		for _, v := range c.values {
+			err = encoder.WriteShortString(v)
+			if err != nil {
+				return
+			}
+		}
 	}
 	return
 }
@@ -192,6 +256,8 @@ func (decoder *byteDecoder) ReadColumnSpec() (col Column, err error) {
 		err = &ErrWrongFileFormat{"Unknown ColumnType"}
 	case Counter:
 		col = &counterColumn{name: name}
+	case Counter64:
+		col = &counter64Column{name: name}
 	case Int8:
 		col = &intColumn[int8]{name: name}
 	case Uint8:
@@ -268,6 +334,114 @@ func (decoder *byteDecoder) ReadColumnSpec() (col Column, err error) {
 		}
 	case Blob:
 		col = &blobColumn{name: name}
+	case Bool:
+		col = &boolColumn{name: name}
+	case Timestamp:
+		col = &timestampColumn{name: name}
+	case Date:
+		col = &dateColumn{name: name}
+	case DecimalColumnType:
+		var precision, scale uint8
+		err = decoder.Uint8(&precision)
+		if err != nil {
+			return
+		}
+		err = decoder.Uint8(&scale)
+		if err != nil {
+			return
+		}
+		if precision < 1 || MaximumDecimalPrecision < precision || precision < scale {
+			err = &ErrWrongFileFormat{"Invalid Decimal precision or scale"}
+			return
+		}
+		col = &decimalColumn{
+			name:      name,
+			precision: precision,
+			scale:     scale,
+		}
+	case UUIDColumnType:
+		col = &uuidColumn{name: name}
+	case Int128ColumnType:
+		col = &int128Column{name: name}
+	case Uint128ColumnType:
+		col = &uint128Column{name: name}
+	case List:
+		var (
+			elemType uint8
+			maxCount uint16
+			ok       bool
+		)
+		err = decoder.Uint8(&elemType)
+		if err != nil {
+			return
+		}
+		err = decoder.Uint16(&maxCount)
+		if err != nil {
+			return
+		}
+		col, ok = newListColumn(name, ColumnType(elemType), maxCount)
+		if !ok || maxCount == 0 {
+			col = nil
+			err = &ErrWrongFileFormat{"Invalid List element type or count"}
+			return
+		}
+	case Struct:
+		var count uint16
+		err = decoder.Uint16(&count)
+		if err != nil {
+			return
+		}
+		if count == 0 || MaximumColumnCountWithoutKey < count {
+			err = &ErrWrongFileFormat{"Invalid Struct column count"}
+			return
+		}
+		columns := make([]Column, count)
+		names := make(map[string]bool, count)
+		for i := range columns {
+			columns[i], err = decoder.ReadColumnSpec()
+			if err != nil {
+				return
+			}
+			if columns[i].Type() == Counter || columns[i].Type() == Counter64 || len(columns[i].Name()) == 0 || names[columns[i].Name()] {
+				err = &ErrWrongFileFormat{"Invalid Struct column"}
+				return
+			}
+			names[columns[i].Name()] = true
+		}
+		col = newStructColumn(name, columns)
+	case JSON:
+		col = &jsonColumn{name: name}
+	case Enum:
+		var count uint16
+		err = decoder.Uint16(&count)
+		if err != nil {
+			return
+		}
+		values := make([]string, count)
+		for i := range values {
+			values[i], err = decoder.ReadShortString()
+			if err != nil {
+				return
+			}
+		}
+		var ok bool
+		col, ok = newEnumColumn(name, values)
+		if !ok {
+			col = nil
+			err = &ErrWrongFileFormat{"Invalid Enum values"}
+			return
+		}
+	case Custom:
+		var typeName string
+		typeName, err = decoder.ReadShortString()
+		if err != nil {
+			return
+		}
+		col, err = newCustomColumn(name, typeName)
+		if err != nil {
+			col = nil
+			return
+		}
 	}
 	return
 }
@@ -0,0 +1,500 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+// unkodb-genはunkodbタグ付きの構造体に対してリフレクションを使わずにテーブルとデータをやりとりする
+// EncodeUnkoDB/DecodeUnkoDBメソッド(unkodb.Encoder/unkodb.Decoderの実装)を生成する。
+// 生成されるメソッドはunkodb.RecordEncoder/unkodb.RecordDecoderを使ってフィールドの値をカラムの形式で直接読み書きする(マップは経由しない)。
+//
+// go generateから使うことを想定している。
+//
+//	//go:generate unkodb-gen -type Food,Drink
+//	type Food struct {
+//		Id    unkodb.CounterType `unkodb:"id,key@Counter"`
+//		Name  string             `unkodb:"name,ShortString"`
+//		Price int64              `unkodb:"price,Int64"`
+//	}
+//
+// 入力ファイル名(省略時は環境変数GOFILE)がfood.goなら生成されるファイルはfood_unkodb.goとなる。
+// カラム型の指定がないタグのフィールドのGoの型は、int8などの組み込みの数値型、unkodb.CounterType、string、bool、time.Time、unkodb.UUID、unkodb.Int128、unkodb.Uint128、[]byte、byteの配列のいずれかである必要がある。
+// カラム型がDecimalのフィールドのGoの型はunkodb.Decimalである必要がある(*big.Ratやstringには対応していない)。
+// カラム型がUUIDのフィールドのGoの型はunkodb.UUIDに型変換できる型([16]byteなど)である必要がある(encoding.TextMarshalerには対応していない)。
+// カラム型がInt128やUint128のフィールドのGoの型はunkodb.Int128やunkodb.Uint128である必要がある(*big.Intには対応していない)。
+// カラム型がListのフィールドのGoの型は要素のカラム型に対応したGoの型のスライス([]int64など)に型変換できる型である必要がある。
+// カラム型がEnumのフィールドのGoの型はstringに型変換できる型である必要がある。
+// カラム型がJSONのフィールドのGoの型はjson.RawMessageに型変換できる型(json.RawMessageやstringや[]byteなど)である必要がある(任意の型のjson.Marshalには対応していない)。
+// カラム型がStructやCustomのフィールドには対応していない。
+// 埋め込みフィールドには対応していない。
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/neetsdkasu/unkodb/internal/unkodbtag"
+)
+
+const (
+	structTagKey = "unkodb"
+	importPath   = "github.com/neetsdkasu/unkodb"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of struct type names (required)")
+	output    = flag.String("output", "", "output file name (default: <input>_unkodb.go)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: unkodb-gen -type T[,T...] [-output file] [file.go]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if len(*typeNames) == 0 || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input := os.Getenv("GOFILE")
+	if flag.NArg() == 1 {
+		input = flag.Arg(0)
+	}
+	if len(input) == 0 {
+		fmt.Fprintln(os.Stderr, "unkodb-gen: no input file")
+		os.Exit(2)
+	}
+	src, err := os.ReadFile(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unkodb-gen:", err)
+		os.Exit(1)
+	}
+	code, err := generate(input, src, strings.Split(*typeNames, ","))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unkodb-gen:", err)
+		os.Exit(1)
+	}
+	out := *output
+	if len(out) == 0 {
+		out = defaultOutput(input)
+	}
+	if err = os.WriteFile(out, code, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "unkodb-gen:", err)
+		os.Exit(1)
+	}
+}
+
+// 生成対象のフィールドの情報
+type field struct {
+	name     string // フィールド名
+	column   string // カラム名
+	goType   string // カラム型に対応したGoの型 (uint32, int64, string, []byteなど)
+	typeExpr string // フィールドの型(ポインタを除いたもの)のソースコード上の表記
+	pointer  bool   // フィールドの型がポインタかどうか
+	array    bool   // フィールドの型がbyteの配列かどうか
+	arrayLen string // byteの配列の長さのソースコード上の表記
+}
+
+// カラム型名とカラム型に対応したGoの型
+var columnGoTypes = map[string]string{
+	"Counter":              "uint32",
+	"Counter64":            "uint64",
+	"Int8":                 "int8",
+	"Uint8":                "uint8",
+	"Int16":                "int16",
+	"Uint16":               "uint16",
+	"Int32":                "int32",
+	"Uint32":               "uint32",
+	"Int64":                "int64",
+	"Uint64":               "uint64",
+	"Float32":              "float32",
+	"Float64":              "float64",
+	"ShortString":          "string",
+	"FixedSizeShortString": "string",
+	"LongString":           "string",
+	"FixedSizeLongString":  "string",
+	"Text":                 "string",
+	"ShortBytes":           "[]byte",
+	"FixedSizeShortBytes":  "[]byte",
+	"LongBytes":            "[]byte",
+	"FixedSizeLongBytes":   "[]byte",
+	"Blob":                 "[]byte",
+	"Bool":                 "bool",
+	"Timestamp":            "time.Time",
+	"Date":                 "time.Time",
+	"Decimal":              "Decimal",
+	"UUID":                 "UUID",
+	"Int128":               "Int128",
+	"Uint128":              "Uint128",
+	"JSON":                 "json.RawMessage",
+}
+
+// Listの要素に使えるカラム型名と要素のカラム型に対応したGoの型
+var listElementGoTypes = map[string]string{
+	"Int8":        "int8",
+	"Uint8":       "uint8",
+	"Int16":       "int16",
+	"Uint16":      "uint16",
+	"Int32":       "int32",
+	"Uint32":      "uint32",
+	"Int64":       "int64",
+	"Uint64":      "uint64",
+	"Float32":     "float32",
+	"Float64":     "float64",
+	"Bool":        "bool",
+	"ShortString": "string",
+	"ShortBytes":  "[]byte",
+	"Timestamp":   "time.Time",
+}
+
+// unkodbパッケージで定義されたGoの型(パッケージ修飾子はparseFieldsで付ける)
+var unkodbGoTypes = map[string]bool{
+	"Decimal": true,
+	"UUID":    true,
+	"Int128":  true,
+	"Uint128": true,
+}
+
+// filenameのソースコードsrcからtypesの構造体のEncodeUnkoDB/DecodeUnkoDBを生成する
+func generate(filename string, src []byte, types []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	structs := make(map[string]*ast.StructType)
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if st, ok := ts.Type.(*ast.StructType); ok {
+				structs[ts.Name.Name] = st
+			}
+		}
+	}
+
+	// このパッケージ自身に対して生成する場合は修飾子を付けない
+	qualifier := "unkodb."
+	if file.Name.Name == "unkodb" {
+		qualifier = ""
+	}
+
+	var body bytes.Buffer
+	useTime := false
+	useJSON := false
+	for _, name := range types {
+		name = strings.TrimSpace(name)
+		st, ok := structs[name]
+		if !ok {
+			return nil, fmt.Errorf("not found struct type %s in %s", name, filename)
+		}
+		fields, err := parseFields(fset, src, st, qualifier)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, f := range fields {
+			useTime = useTime || strings.HasSuffix(f.goType, "time.Time")
+			useJSON = useJSON || f.goType == "json.RawMessage"
+		}
+		writeEncoder(&body, name, fields, qualifier)
+		writeDecoder(&body, name, fields, qualifier)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by unkodb-gen. DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintf(&buf, "package %s\n", file.Name.Name)
+	var imports []string
+	if useJSON {
+		imports = append(imports, "encoding/json")
+	}
+	if useTime {
+		imports = append(imports, "time")
+	}
+	if len(qualifier) > 0 {
+		imports = append(imports, importPath)
+	}
+	if len(imports) > 0 {
+		fmt.Fprintln(&buf)
+		fmt.Fprintln(&buf, "import (")
+		for _, path := range imports {
+			fmt.Fprintf(&buf, "\t%q\n", path)
+		}
+		fmt.Fprintln(&buf, ")")
+	}
+	buf.Write(body.Bytes())
+	return format.Source(buf.Bytes())
+}
+
+func parseFields(fset *token.FileSet, src []byte, st *ast.StructType, qualifier string) ([]field, error) {
+	var fields []field
+	names := make(map[string]bool)
+	hasKey := false
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		tag, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			return nil, err
+		}
+		tv, ok := reflect.StructTag(tag).Lookup(structTagKey)
+		if !ok {
+			continue
+		}
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("embedded field is not supported")
+		}
+		if len(f.Names) > 1 {
+			return nil, fmt.Errorf("multiple fields in one declaration are not supported (field: %s)", f.Names[0].Name)
+		}
+		fd := field{name: f.Names[0].Name}
+		t := f.Type
+		if star, ok := t.(*ast.StarExpr); ok {
+			fd.pointer = true
+			t = star.X
+		}
+		if _, ok := t.(*ast.StarExpr); ok {
+			return nil, fmt.Errorf("pointer to pointer is not supported (field: %s)", fd.name)
+		}
+		fd.typeExpr = string(src[fset.Position(t.Pos()).Offset:fset.Position(t.End()).Offset])
+		if at, ok := t.(*ast.ArrayType); ok && at.Len != nil {
+			fd.array = true
+			fd.arrayLen = string(src[fset.Position(at.Len.Pos()).Offset:fset.Position(at.Len.End()).Offset])
+		}
+
+		index := unkodbtag.SeparatorIndex(tv)
+		fd.column = tv
+		if index < 0 {
+			fd.goType, err = inferGoType(t, qualifier)
+			if err != nil {
+				return nil, fmt.Errorf("%w (field: %s)", err, fd.name)
+			}
+		} else {
+			fd.column = tv[:index]
+			var isKey bool
+			isKey, fd.goType, err = parseTagColumnType(tv[index+1:])
+			if err != nil {
+				return nil, fmt.Errorf("%w (field: %s)", err, fd.name)
+			}
+			if unkodbGoTypes[fd.goType] {
+				// UUIDのカラムの[16]byteのフィールドはバイト列としてではなく型変換で扱う
+				fd.array = false
+				fd.goType = qualifier + fd.goType
+			}
+			if isKey {
+				if hasKey {
+					return nil, fmt.Errorf("duplicate key (field: %s)", fd.name)
+				}
+				hasKey = true
+			}
+			if _, ok := t.(*ast.ArrayType); ok && fd.goType == "[]byte" {
+				if !isByteSliceOrArray(t) {
+					return nil, fmt.Errorf("cannot convert type %s to %s (field: %s)", fd.typeExpr, fd.goType, fd.name)
+				}
+			} else if fd.array {
+				return nil, fmt.Errorf("cannot convert type %s to %s (field: %s)", fd.typeExpr, fd.goType, fd.name)
+			}
+		}
+		if len(fd.column) == 0 {
+			fd.column = fd.name
+		}
+		if names[fd.column] {
+			return nil, fmt.Errorf(`duplicate name "%s" (field: %s)`, fd.column, fd.name)
+		}
+		names[fd.column] = true
+		fields = append(fields, fd)
+	}
+	return fields, nil
+}
+
+func parseTagColumnType(s string) (isKey bool, goType string, err error) {
+	tct, err := unkodbtag.ParseColumnType(s)
+	if err != nil {
+		return
+	}
+	isKey = tct.IsKey
+	switch tct.Name {
+	case "List":
+		if isKey {
+			err = fmt.Errorf("invalid key type")
+			return
+		}
+		elemType, ok := listElementGoTypes[tct.Elem]
+		if !ok {
+			err = fmt.Errorf("invalid element type")
+			return
+		}
+		goType = "[]" + elemType
+		return
+	case "Enum":
+		goType = "string"
+		return
+	case "Struct", "Custom":
+		err = fmt.Errorf("%s column type is not supported", tct.Name)
+		return
+	}
+	goType, ok := columnGoTypes[tct.Name]
+	if !ok {
+		err = fmt.Errorf("not found type name")
+		return
+	}
+	if (tct.Name == "Counter" || tct.Name == "Counter64") && !isKey {
+		err = fmt.Errorf(`%s type need prefix "key@"`, tct.Name)
+	}
+	return
+}
+
+// カラム型の指定がないフィールドの型からカラム型に対応したGoの型を推定する
+func inferGoType(t ast.Expr, qualifier string) (string, error) {
+	switch t := t.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "int8", "uint8", "int16", "uint16", "int32", "uint32", "int64", "uint64", "float32", "float64", "string", "bool":
+			return t.Name, nil
+		case "byte":
+			return "uint8", nil
+		case "rune":
+			return "int32", nil
+		case "CounterType":
+			if len(qualifier) == 0 {
+				return "uint32", nil
+			}
+		case "Counter64Type":
+			if len(qualifier) == 0 {
+				return "uint64", nil
+			}
+		case "UUID", "Int128", "Uint128":
+			if len(qualifier) == 0 {
+				return t.Name, nil
+			}
+		}
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok && x.Name+"." == qualifier && t.Sel.Name == "CounterType" {
+			return "uint32", nil
+		}
+		if x, ok := t.X.(*ast.Ident); ok && x.Name+"." == qualifier && t.Sel.Name == "Counter64Type" {
+			return "uint64", nil
+		}
+		if x, ok := t.X.(*ast.Ident); ok && x.Name+"." == qualifier {
+			switch t.Sel.Name {
+			case "UUID", "Int128", "Uint128":
+				return qualifier + t.Sel.Name, nil
+			}
+		}
+		if x, ok := t.X.(*ast.Ident); ok && x.Name == "time" && t.Sel.Name == "Time" {
+			return "time.Time", nil
+		}
+	case *ast.ArrayType:
+		if isByteSliceOrArray(t) {
+			return "[]byte", nil
+		}
+	}
+	return "", fmt.Errorf("cannot infer column type; specify column type in tag")
+}
+
+func isByteSliceOrArray(t ast.Expr) bool {
+	at, ok := t.(*ast.ArrayType)
+	if !ok {
+		return false
+	}
+	elt, ok := at.Elt.(*ast.Ident)
+	return ok && (elt.Name == "byte" || elt.Name == "uint8")
+}
+
+// カラム型に対応したGoの型を読み書きするRecordEncoder/RecordDecoderのメソッド名
+// Listのカラムの場合は空文字列を返す(ValueメソッドやDecodeValue関数で読み書きする)
+func coderMethod(goType, qualifier string) string {
+	switch goType {
+	case "int8", "uint8", "int16", "uint16", "int32", "uint32", "int64", "uint64", "float32", "float64", "string", "bool":
+		return strings.ToUpper(goType[:1]) + goType[1:]
+	case "[]byte":
+		return "Bytes"
+	case "time.Time":
+		return "Time"
+	case "json.RawMessage":
+		return "JSON"
+	}
+	if name := strings.TrimPrefix(goType, qualifier); unkodbGoTypes[name] {
+		return name
+	}
+	return ""
+}
+
+func writeEncoder(buf *bytes.Buffer, name string, fields []field, qualifier string) {
+	fmt.Fprintln(buf)
+	fmt.Fprintf(buf, "// EncodeUnkoDB implements %sEncoder.\n", qualifier)
+	fmt.Fprintf(buf, "func (x *%s) EncodeUnkoDB(e *%sRecordEncoder) error {\n", name, qualifier)
+	fmt.Fprintf(buf, "\tif x == nil {\n\t\treturn %sErrNotFoundData\n\t}\n", qualifier)
+	for _, f := range fields {
+		ref := "x." + f.name
+		if f.pointer {
+			fmt.Fprintf(buf, "\tif %s == nil {\n\t\treturn %sErrNotFoundData\n\t}\n", ref, qualifier)
+			if f.array {
+				ref = "(*" + ref + ")"
+			} else {
+				ref = "*" + ref
+			}
+		}
+		method := coderMethod(f.goType, qualifier)
+		switch {
+		case f.array:
+			fmt.Fprintf(buf, "\te.Bytes(%q, %s[:])\n", f.column, ref)
+		case len(method) == 0:
+			fmt.Fprintf(buf, "\te.Value(%q, %s(%s))\n", f.column, f.goType, ref)
+		default:
+			fmt.Fprintf(buf, "\te.%s(%q, %s(%s))\n", method, f.column, f.goType, ref)
+		}
+	}
+	fmt.Fprintln(buf, "\treturn e.Err()")
+	fmt.Fprintln(buf, "}")
+}
+
+func writeDecoder(buf *bytes.Buffer, name string, fields []field, qualifier string) {
+	fmt.Fprintln(buf)
+	fmt.Fprintf(buf, "// DecodeUnkoDB implements %sDecoder.\n", qualifier)
+	fmt.Fprintf(buf, "func (x *%s) DecodeUnkoDB(d *%sRecordDecoder) error {\n", name, qualifier)
+	for _, f := range fields {
+		ref := "x." + f.name
+		if f.pointer {
+			fmt.Fprintf(buf, "\tif %s == nil {\n\t\t%s = new(%s)\n\t}\n", ref, ref, f.typeExpr)
+			if f.array {
+				ref = "(*" + ref + ")"
+			} else {
+				ref = "*" + ref
+			}
+		}
+		var call string
+		if method := coderMethod(f.goType, qualifier); len(method) > 0 {
+			call = fmt.Sprintf("d.%s(%q)", method, f.column)
+		} else {
+			call = fmt.Sprintf("%sDecodeValue[%s](d, %q)", qualifier, f.goType, f.column)
+		}
+		if f.array {
+			fmt.Fprintf(buf, "\tif v := %s; len(v) == %s {\n", call, f.arrayLen)
+			fmt.Fprintf(buf, "\t\tcopy(%s[:], v)\n", ref)
+			fmt.Fprintln(buf, "\t} else {")
+			fmt.Fprintf(buf, "\t\treturn %sErrCannotAssignValueToField\n", qualifier)
+			fmt.Fprintln(buf, "\t}")
+		} else {
+			fmt.Fprintf(buf, "\t%s = %s(%s)\n", ref, f.typeExpr, call)
+		}
+	}
+	fmt.Fprintln(buf, "\treturn d.Err()")
+	fmt.Fprintln(buf, "}")
+}
+
+// 出力ファイル名の既定値
+func defaultOutput(input string) string {
+	return filepath.Join(filepath.Dir(input), strings.TrimSuffix(filepath.Base(input), ".go")+"_unkodb.go")
+}
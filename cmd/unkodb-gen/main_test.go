@@ -0,0 +1,110 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	src := []byte("package foods\n" +
+		"import \"github.com/neetsdkasu/unkodb\"\n" +
+		"import \"time\"\n" +
+		"type Food struct {\n" +
+		"	Id    unkodb.CounterType `unkodb:\"id,key@Counter\"`\n" +
+		"	Name  string             `unkodb:\"name\"`\n" +
+		"	Price *uint16            `unkodb:\"\"`\n" +
+		"	Code  *[8]byte           `unkodb:\"code,FixedSizeShortBytes[8]\"`\n" +
+		"	Sold  bool               `unkodb:\"sold\"`\n" +
+		"	At    time.Time          `unkodb:\"at,Timestamp\"`\n" +
+		"	Cost  unkodb.Decimal     `unkodb:\"cost,Decimal[10,2]\"`\n" +
+		"	Ref   [16]byte           `unkodb:\"ref,UUID\"`\n" +
+		"	Big   unkodb.Int128      `unkodb:\"big\"`\n" +
+		"	Tags  []string           `unkodb:\"tags,List<ShortString>[10]\"`\n" +
+		"	Conf  string             `unkodb:\"conf,JSON\"`\n" +
+		"	Kind  string             `unkodb:\"kind,Enum[food,drink]\"`\n" +
+		"	Memo  string\n" +
+		"}\n")
+
+	code, err := generate("food.go", src, []string{"Food"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(code)
+	for _, want := range []string{
+		"// Code generated by unkodb-gen. DO NOT EDIT.",
+		`"github.com/neetsdkasu/unkodb"`,
+		`"time"`,
+		"func (x *Food) EncodeUnkoDB(e *unkodb.RecordEncoder) error {",
+		"func (x *Food) DecodeUnkoDB(d *unkodb.RecordDecoder) error {",
+		`e.Uint32("id", uint32(x.Id))`,
+		`e.String("name", string(x.Name))`,
+		`e.Uint16("Price", uint16(*x.Price))`,
+		`e.Bytes("code", (*x.Code)[:])`,
+		`x.Id = unkodb.CounterType(d.Uint32("id"))`,
+		"x.Code = new([8]byte)",
+		`if v := d.Bytes("code"); len(v) == 8 {`,
+		`e.Bool("sold", bool(x.Sold))`,
+		`x.At = time.Time(d.Time("at"))`,
+		`e.Decimal("cost", unkodb.Decimal(x.Cost))`,
+		`x.Cost = unkodb.Decimal(d.Decimal("cost"))`,
+		`e.UUID("ref", unkodb.UUID(x.Ref))`,
+		`x.Ref = [16]byte(d.UUID("ref"))`,
+		`e.Int128("big", unkodb.Int128(x.Big))`,
+		`e.Value("tags", []string(x.Tags))`,
+		`x.Tags = []string(unkodb.DecodeValue[[]string](d, "tags"))`,
+		`"encoding/json"`,
+		`e.JSON("conf", json.RawMessage(x.Conf))`,
+		`x.Conf = string(d.JSON("conf"))`,
+		`e.String("kind", string(x.Kind))`,
+		"return e.Err()",
+		"return d.Err()",
+		"return unkodb.ErrCannotAssignValueToField",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("not found %q in\n%s", want, s)
+		}
+	}
+	if strings.Contains(s, "Memo") {
+		t.Fatalf("untagged field is generated\n%s", s)
+	}
+
+	code, err = generate("event.go", []byte("package events\n"+
+		"import \"github.com/neetsdkasu/unkodb\"\n"+
+		"type Event struct { Id unkodb.Counter64Type `unkodb:\"id,key@Counter64\"` }\n"), []string{"Event"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s = string(code); !strings.Contains(s, `e.Uint64("id", uint64(x.Id))`) || !strings.Contains(s, `x.Id = unkodb.Counter64Type(d.Uint64("id"))`) {
+		t.Fatalf("wrong code\n%s", s)
+	}
+
+	wrongs := []string{
+		"type Food struct { Id int `unkodb:\"id\"` }",
+		"type Food struct { Id uint32 `unkodb:\"id,Counter\"` }",
+		"type Food struct { Id uint64 `unkodb:\"id,Counter64\"` }",
+		"type Food struct { Id int `unkodb:\"id,key@Counter\"`; At time.Time `unkodb:\"at,key@Timestamp\"` }",
+		"type Food struct { Id uint32 `unkodb:\"id,key@Unknown\"` }",
+		"type Food struct { Code [4]byte `unkodb:\"code,Int64\"` }",
+		"type Food struct { Code [4]int `unkodb:\"code,ShortBytes\"` }",
+		"type Food struct { Cost int64 `unkodb:\"cost,Decimal\"` }",
+		"type Food struct { Cost int64 `unkodb:\"cost,Decimal[10]\"` }",
+		"type Food struct { Tags []string `unkodb:\"tags,List<Text>[10]\"` }",
+		"type Food struct { Tags []string `unkodb:\"tags,List<ShortString>\"` }",
+		"type Food struct { Tags []string `unkodb:\"tags,key@List<ShortString>[3]\"` }",
+		"type Food struct { Addr Address `unkodb:\"addr,Struct\"` }",
+		"type Food struct { Pos Point `unkodb:\"pos,Custom[Point]\"` }",
+		"type Food struct { Kind string `unkodb:\"kind,Enum[]\"` }",
+		"type Food struct { A int8 `unkodb:\"a\"`; B int8 `unkodb:\"a\"` }",
+		"type Food struct { A, B int8 `unkodb:\"a,Int8\"` }",
+		"type Bar struct { A int8 `unkodb:\"a\"` }",
+	}
+	for _, w := range wrongs {
+		_, err := generate("food.go", []byte("package foods\n"+w+"\n"), []string{"Food"})
+		if err == nil {
+			t.Fatalf("no error: %s", w)
+		}
+	}
+}
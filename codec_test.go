@@ -0,0 +1,281 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+//go:generate go run ./cmd/unkodb-gen -type generatedFood -output codec_unkodb_test.go codec_test.go
+
+type generatedFood struct {
+	Id    CounterType `unkodb:"id,key@Counter"`
+	Name  string      `unkodb:"name,ShortString"`
+	Price *int64      `unkodb:"price,Int64"`
+	Code  [4]byte     `unkodb:"code,FixedSizeShortBytes[4]"`
+	Image []byte      `unkodb:"image,Blob"`
+	Memo  string
+}
+
+// reflectionを使う経路ではなく生成されたメソッドが使われたことを確認するための型
+type countingFood struct {
+	generatedFood
+	encoded int
+	decoded int
+}
+
+func (x *countingFood) EncodeUnkoDB(e *RecordEncoder) error {
+	x.encoded++
+	return x.generatedFood.EncodeUnkoDB(e)
+}
+
+func (x *countingFood) DecodeUnkoDB(d *RecordDecoder) error {
+	x.decoded++
+	return x.generatedFood.DecodeUnkoDB(d)
+}
+
+// ノードのバイト列から直接読み込まれたことを確認するための型
+type nodeDecodedFood struct {
+	generatedFood
+}
+
+func (x *nodeDecodedFood) DecodeUnkoDB(d *RecordDecoder) error {
+	if d.data != nil {
+		return fmt.Errorf("decoded from map")
+	}
+	return x.generatedFood.DecodeUnkoDB(d)
+}
+
+// テーブルのカラムの順番とは異なる順番で読み書きする型
+type reversedFood struct {
+	name  string
+	image []byte
+	skip  bool
+	// テーブルに存在しないカラムにも書き込む
+	unknown bool
+}
+
+func (x *reversedFood) EncodeUnkoDB(e *RecordEncoder) error {
+	e.Bytes("image", x.image)
+	e.Bytes("code", []byte{9, 9, 9, 9})
+	if !x.skip {
+		e.Int64("price", 100)
+	}
+	e.String("name", x.name)
+	if x.unknown {
+		e.String("unknown", "unknown")
+	}
+	return e.Err()
+}
+
+func (x *reversedFood) DecodeUnkoDB(d *RecordDecoder) error {
+	x.image = d.Bytes("image")
+	x.name = d.String("name")
+	return d.Err()
+}
+
+func TestGeneratedCodec(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := db.CreateTableByTaggedStruct("foods", (*generatedFood)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := int64(300)
+	food := &countingFood{
+		generatedFood: generatedFood{
+			Name:  "メロンパン",
+			Price: &price,
+			Code:  [4]byte{1, 2, 3, 4},
+			Image: []byte{5, 6, 7},
+			Memo:  "memo",
+		},
+	}
+
+	m, err := parseData(table, food)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if food.encoded != 1 {
+		t.Fatalf("EncodeUnkoDB is not used %d", food.encoded)
+	}
+	expected := tableTreeValue{
+		"id":    CounterType(0),
+		"name":  "メロンパン",
+		"price": int64(300),
+		"code":  []byte{1, 2, 3, 4},
+		"image": []byte{5, 6, 7},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("unmatch %#v %#v", m, expected)
+	}
+
+	r, err := table.Insert(food)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if food.encoded != 2 {
+		t.Fatalf("EncodeUnkoDB is not used %d", food.encoded)
+	}
+	// 書き込まれたデータは呼び出し元のスライスと共有されない
+	food.Image[0] = 99
+	if r.Column("image").([]byte)[0] != 5 {
+		t.Fatal("record value is aliased")
+	}
+	food.Image[0] = 5
+
+	var moved countingFood
+	if err = r.MoveTo(&moved); err != nil {
+		t.Fatal(err)
+	}
+	if moved.decoded != 1 {
+		t.Fatalf("DecodeUnkoDB is not used %d", moved.decoded)
+	}
+
+	r, err = table.Find(CounterType(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var filled countingFood
+	if err = r.CopyTo(&filled); err != nil {
+		t.Fatal(err)
+	}
+	if filled.decoded != 1 {
+		t.Fatalf("DecodeUnkoDB is not used %d", filled.decoded)
+	}
+	// CopyToではレコードの値のコピーが渡される
+	filled.Image[0] = 99
+	if r.Column("image").([]byte)[0] != 5 {
+		t.Fatal("record value is modified")
+	}
+	filled.Image[0] = 5
+
+	for _, got := range []*generatedFood{&moved.generatedFood, &filled.generatedFood} {
+		if got.Id != 1 || got.Name != "メロンパン" || *got.Price != 300 ||
+			got.Code != [4]byte{1, 2, 3, 4} || !reflect.DeepEqual(got.Image, []byte{5, 6, 7}) || got.Memo != "" {
+			t.Fatalf("wrong food %#v", got)
+		}
+	}
+
+	foods, err := Typed[generatedFood](table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := foods.Find(CounterType(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Name != "メロンパン" || *got.Price != 300 || got.Code != [4]byte{1, 2, 3, 4} {
+		t.Fatalf("wrong food %#v", got)
+	}
+
+	price = 500
+	got, err = foods.Insert(&generatedFood{Name: "あんパン", Price: &price, Image: []byte{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != 2 || got.Name != "あんパン" || *got.Price != 500 || len(got.Image) != 0 {
+		t.Fatalf("wrong food %#v", got)
+	}
+	got.Name = "つぶあんパン"
+	got, err = foods.Replace(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != 2 || got.Name != "つぶあんパン" || *got.Price != 500 {
+		t.Fatalf("wrong food %#v", got)
+	}
+	r, err = table.Find(CounterType(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("name") != "つぶあんパン" || r.Column("price") != int64(500) {
+		t.Fatalf("wrong record %#v", r.Take())
+	}
+
+	nodeFoods, err := Typed[nodeDecodedFood](table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iterate := range []func(callback func(*nodeDecodedFood) bool) error{
+		nodeFoods.IterateAll,
+		nodeFoods.IterateBackAll,
+		func(callback func(*nodeDecodedFood) bool) error {
+			return nodeFoods.IterateRange(CounterType(1), CounterType(2), callback)
+		},
+		func(callback func(*nodeDecodedFood) bool) error {
+			return nodeFoods.IterateBackRange(CounterType(1), CounterType(2), callback)
+		},
+	} {
+		count := 0
+		err = iterate(func(food *nodeDecodedFood) (_ bool) {
+			if food.Id != 1 && food.Id != 2 {
+				t.Fatalf("wrong food %#v", food)
+			}
+			count++
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Fatalf("wrong count %d", count)
+		}
+	}
+
+	reversed := &reversedFood{name: "クリームパン", image: []byte{1}}
+	r, err = table.Insert(reversed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Key() != CounterType(3) || r.Column("name") != "クリームパン" || r.Column("price") != int64(100) ||
+		!reflect.DeepEqual(r.Column("code"), []byte{9, 9, 9, 9}) || !reflect.DeepEqual(r.Column("image"), []byte{1}) {
+		t.Fatalf("wrong record %#v", r.Take())
+	}
+	node, err := table.findNode(CounterType(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded reversedFood
+	if err = decodeRecord(&decoded, newNodeRecordDecoder(node)); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.name != "クリームパン" || !reflect.DeepEqual(decoded.image, []byte{1}) {
+		t.Fatalf("wrong food %#v", decoded)
+	}
+
+	_, err = table.Insert(&reversedFood{name: "ジャムパン", skip: true})
+	if e, ok := err.(*ErrNotFoundColumnName); !ok || e.Column.Name() != "price" {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = table.Insert(&reversedFood{name: "ジャムパン", unknown: true})
+	if e, ok := err.(*ErrNotFoundColumnName); !ok || e.Column.Name() != "unknown" || e.Column.Type() != Text {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = table.Insert(&generatedFood{Name: "食パン"})
+	if err != ErrNotFoundData {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	err = (&generatedFood{}).DecodeUnkoDB(newMapRecordDecoder(tableTreeValue{"id": CounterType(1)}))
+	if err != ErrCannotAssignValueToField {
+		t.Fatalf("wrong error %v", err)
+	}
+}
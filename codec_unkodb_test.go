@@ -0,0 +1,36 @@
+// Code generated by unkodb-gen. DO NOT EDIT.
+
+package unkodb
+
+// EncodeUnkoDB implements Encoder.
+func (x *generatedFood) EncodeUnkoDB(e *RecordEncoder) error {
+	if x == nil {
+		return ErrNotFoundData
+	}
+	e.Uint32("id", uint32(x.Id))
+	e.String("name", string(x.Name))
+	if x.Price == nil {
+		return ErrNotFoundData
+	}
+	e.Int64("price", int64(*x.Price))
+	e.Bytes("code", x.Code[:])
+	e.Bytes("image", []byte(x.Image))
+	return e.Err()
+}
+
+// DecodeUnkoDB implements Decoder.
+func (x *generatedFood) DecodeUnkoDB(d *RecordDecoder) error {
+	x.Id = CounterType(d.Uint32("id"))
+	x.Name = string(d.String("name"))
+	if x.Price == nil {
+		x.Price = new(int64)
+	}
+	*x.Price = int64(d.Int64("price"))
+	if v := d.Bytes("code"); len(v) == 4 {
+		copy(x.Code[:], v)
+	} else {
+		return ErrCannotAssignValueToField
+	}
+	x.Image = []byte(d.Bytes("image"))
+	return d.Err()
+}
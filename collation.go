@@ -0,0 +1,211 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/neetsdkasu/avltree"
+)
+
+// 文字列のキーの順序の決め方(照合順序)。
+// TableCreatorのSetKeyCollationでShortStringやFixedSizeShortStringやLongStringのキーに設定する。
+// 設定した照合順序はファイルに保存され、Find、IterateRangeなどのキーの比較に使われる。
+type Collation uint8
+
+const (
+	// バイト列としての順序(デフォルト)。
+	BinaryCollation Collation = iota
+
+	// ASCIIの英字の大文字と小文字を区別しない順序。
+	// 大文字と小文字だけが異なるキーは同じキーとして扱われる。
+	ASCIICaseInsensitiveCollation
+
+	// Unicodeの大文字と小文字を区別しない順序(strings.EqualFoldで等しい文字列を同じキーとして扱う)。
+	UnicodeFoldCollation
+
+	// 数字の並びを数値として比較する順序("file2" < "file10")。
+	// 数値として等しい"file02"と"file2"のようなキーはバイト列としての順序で区別される。
+	NumericCollation
+)
+
+func (collation Collation) String() string {
+	switch collation {
+	case BinaryCollation:
+		return "Binary"
+	case ASCIICaseInsensitiveCollation:
+		return "ASCIICaseInsensitive"
+	case UnicodeFoldCollation:
+		return "UnicodeFold"
+	case NumericCollation:
+		return "Numeric"
+	default:
+		return "Unknown"
+	}
+}
+
+func (collation Collation) isValid() bool {
+	return collation <= NumericCollation
+}
+
+// 照合順序でaとbを比較する
+// a < bなら負、a == bなら0、a > bなら正を返す
+func (collation Collation) compare(a, b string) int {
+	switch collation {
+	case BinaryCollation:
+		return strings.Compare(a, b)
+	case ASCIICaseInsensitiveCollation:
+		return compareASCIICaseInsensitive(a, b)
+	case UnicodeFoldCollation:
+		return compareUnicodeFold(a, b)
+	case NumericCollation:
+		if c := compareNumeric(a, b); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	default:
+		bug.Panicf("invalid collation (%d)", collation)
+		return 0
+	}
+}
+
+func toLowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
+
+func compareASCIICaseInsensitive(a, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, y := toLowerASCII(a[i]), toLowerASCII(b[i])
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+// 大文字と小文字の同値類の中で最小のコードポイントの文字を返す
+func foldRune(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+func compareUnicodeFold(a, b string) int {
+	for len(a) > 0 && len(b) > 0 {
+		r1, s1 := utf8.DecodeRuneInString(a)
+		r2, s2 := utf8.DecodeRuneInString(b)
+		if r1 == r2 {
+			// 不正なUTF-8のバイトは全てRuneErrorになるのでバイト列で比較する
+			if r1 == utf8.RuneError {
+				if c := strings.Compare(a[:s1], b[:s2]); c != 0 {
+					return c
+				}
+			}
+			a, b = a[s1:], b[s2:]
+			continue
+		}
+		a, b = a[s1:], b[s2:]
+		r1, r2 = foldRune(r1), foldRune(r2)
+		if r1 != r2 {
+			if r1 < r2 {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// 数字の並びを数値として比較する
+// 数値は先頭の0を除いた桁数と数字の並びで比較するので桁数の制限はない
+func compareNumeric(a, b string) int {
+	for len(a) > 0 && len(b) > 0 {
+		if !isDigit(a[0]) || !isDigit(b[0]) {
+			if a[0] != b[0] {
+				if a[0] < b[0] {
+					return -1
+				}
+				return 1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		i, j := 0, 0
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		x := strings.TrimLeft(a[:i], "0")
+		y := strings.TrimLeft(b[:j], "0")
+		if len(x) != len(y) {
+			return len(x) - len(y)
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+		a, b = a[i:], b[j:]
+	}
+	return len(a) - len(b)
+}
+
+// 照合順序で比較する文字列のキー
+type collatedStringKey struct {
+	value     string
+	collation Collation
+}
+
+func (key collatedStringKey) CompareTo(other avltree.Key) (_ avltree.KeyOrdering) {
+	if x, ok := other.(collatedStringKey); ok {
+		c := key.collation.compare(key.value, x.value)
+		switch {
+		case c < 0:
+			return avltree.LessThanOtherKey
+		case c > 0:
+			return avltree.GreaterThanOtherKey
+		default:
+			return avltree.EqualToOtherKey
+		}
+	} else {
+		bug.Panicf("invalid key type (key: %T %#v)", other, other)
+		return
+	}
+}
+
+func (key collatedStringKey) Copy() avltree.Key {
+	return key
+}
+
+// キーのカラムに照合順序を設定する
+// 文字列のキーではない場合はfalseを返す
+func setKeyCollation(key keyColumn, collation Collation) bool {
+	switch c := key.(type) {
+	case *shortStringColumn:
+		c.collation = collation
+	case *fixedSizeShortStringColumn:
+		c.collation = collation
+	case *longStringColumn:
+		c.collation = collation
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import "testing"
+
+func TestCollation_compare(t *testing.T) {
+	testCases := []struct {
+		collation Collation
+		a, b      string
+		sign      int
+	}{
+		{BinaryCollation, "abc", "abc", 0},
+		{BinaryCollation, "ABC", "abc", -1},
+		{BinaryCollation, "file10", "file2", -1},
+		{ASCIICaseInsensitiveCollation, "ABC", "abc", 0},
+		{ASCIICaseInsensitiveCollation, "abc", "ABD", -1},
+		{ASCIICaseInsensitiveCollation, "Z", "a", 1},
+		{ASCIICaseInsensitiveCollation, "ab", "ABC", -1},
+		{ASCIICaseInsensitiveCollation, "Ä", "ä", -1},
+		{UnicodeFoldCollation, "Ä", "ä", 0},
+		{UnicodeFoldCollation, "ΣΑΣ", "σας", 0},
+		{UnicodeFoldCollation, "Straße", "STRASSE", 1},
+		{UnicodeFoldCollation, "abc", "ABD", -1},
+		{UnicodeFoldCollation, "\xff", "\xfe", 1},
+		{UnicodeFoldCollation, "a\xfeb", "A\xffB", -1},
+		{UnicodeFoldCollation, "\xff", "\uFFFD", 1},
+		{UnicodeFoldCollation, "A\xff", "a\xff", 0},
+		{NumericCollation, "file2", "file10", -1},
+		{NumericCollation, "file10", "file10", 0},
+		{NumericCollation, "file02", "file2", -1},
+		{NumericCollation, "file2a", "file2b", -1},
+		{NumericCollation, "a100000000000000000000000", "a99", 1},
+		{NumericCollation, "10", "9x", 1},
+		{NumericCollation, "x", "1", 1},
+	}
+	for _, tc := range testCases {
+		c := tc.collation.compare(tc.a, tc.b)
+		if (c < 0 && tc.sign >= 0) || (c == 0 && tc.sign != 0) || (c > 0 && tc.sign <= 0) {
+			t.Fatalf("%s: compare(%q, %q) = %d (expected sign %d)", tc.collation, tc.a, tc.b, c, tc.sign)
+		}
+		r := tc.collation.compare(tc.b, tc.a)
+		if (r < 0) != (c > 0) || (r == 0) != (c == 0) {
+			t.Fatalf("%s: compare(%q, %q) = %d is not symmetric", tc.collation, tc.b, tc.a, r)
+		}
+	}
+}
@@ -4,7 +4,11 @@
 package unkodb
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/neetsdkasu/avltree"
@@ -14,6 +18,9 @@ import (
 // カラム型のCounterで用いるGoの型。ただのuint32のエイリアス。
 type CounterType = uint32
 
+// カラム型のCounter64で用いるGoの型。ただのuint64のエイリアス。
+type Counter64Type = uint64
+
 // カラム型の種類を表す。
 type ColumnType int
 
@@ -63,6 +70,32 @@ func (ct ColumnType) String() string {
 		return "FixedSizeLongBytes"
 	case Blob:
 		return "Blob"
+	case Bool:
+		return "Bool"
+	case Timestamp:
+		return "Timestamp"
+	case Date:
+		return "Date"
+	case DecimalColumnType:
+		return "Decimal"
+	case UUIDColumnType:
+		return "UUID"
+	case Int128ColumnType:
+		return "Int128"
+	case Uint128ColumnType:
+		return "Uint128"
+	case List:
+		return "List"
+	case Struct:
+		return "Struct"
+	case JSON:
+		return "JSON"
+	case Enum:
+		return "Enum"
+	case Custom:
+		return "Custom"
+	case Counter64:
+		return "Counter64"
 	}
 }
 
@@ -113,6 +146,32 @@ func (ct ColumnType) GoTypeHint() string {
 		return "[]byte"
 	case Blob:
 		return "[]byte"
+	case Bool:
+		return "bool"
+	case Timestamp:
+		return "time.Time"
+	case Date:
+		return "time.Time"
+	case DecimalColumnType:
+		return "unkodb.Decimal"
+	case UUIDColumnType:
+		return "unkodb.UUID"
+	case Int128ColumnType:
+		return "unkodb.Int128"
+	case Uint128ColumnType:
+		return "unkodb.Uint128"
+	case List:
+		return "[]T"
+	case Struct:
+		return "map[string]any"
+	case JSON:
+		return "json.RawMessage"
+	case Enum:
+		return "string"
+	case Custom:
+		return "any"
+	case Counter64:
+		return "uint64"
 	}
 }
 
@@ -122,6 +181,8 @@ func (ct ColumnType) keyColumnType() bool {
 		return false
 	case Counter:
 		return true
+	case Counter64:
+		return true
 	case Int8:
 		return true
 	case Uint8:
@@ -138,14 +199,34 @@ func (ct ColumnType) keyColumnType() bool {
 		return true
 	case Uint64:
 		return true
+	case Float32:
+		return true
+	case Float64:
+		return true
 	case ShortString:
 		return true
 	case FixedSizeShortString:
 		return true
+	case LongString:
+		return true
 	case ShortBytes:
 		return true
 	case FixedSizeShortBytes:
 		return true
+	case LongBytes:
+		return true
+	case Timestamp:
+		return true
+	case DecimalColumnType:
+		return true
+	case UUIDColumnType:
+		return true
+	case Int128ColumnType:
+		return true
+	case Uint128ColumnType:
+		return true
+	case Enum:
+		return true
 	}
 }
 
@@ -167,6 +248,25 @@ func ColumnTypeHint(col Column) string {
 	case FixedSizeLongBytes:
 		size := col.(*fixedSizeLongBytesColumn).size
 		return fmt.Sprint(ct.String(), "[", size, "] (", ct.GoTypeHint(), ")")
+	case DecimalColumnType:
+		c := col.(*decimalColumn)
+		return fmt.Sprint(ct.String(), "[", c.precision, ",", c.scale, "] (", ct.GoTypeHint(), ")")
+	case List:
+		elem, maxCount := col.(listColumnSpec).listSpec()
+		return fmt.Sprint(ct.String(), "<", elem.Type().String(), ">[", maxCount, "] ([]", elem.Type().GoTypeHint(), ")")
+	case Struct:
+		var b strings.Builder
+		for i, sub := range col.(*structColumn).columns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(sub.Name() + " " + ColumnTypeHint(sub))
+		}
+		return ct.String() + "{" + b.String() + "} (" + ct.GoTypeHint() + ")"
+	case Enum:
+		return ct.String() + "[" + strings.Join(col.(*enumColumn).values, ",") + "] (" + ct.GoTypeHint() + ")"
+	case Custom:
+		return ct.String() + "[" + col.(*customColumn).typeName + "] (" + ct.GoTypeHint() + ")"
 	}
 }
 
@@ -199,6 +299,10 @@ type Column interface {
 
 	// データコピーを生成
 	copyValue(value any) (copiedVale any)
+
+	// カラム追加時に既存のデータに入れる値(数値なら0、可変長の文字列やバイト列なら空、固定長ならバイトが0で埋められた値)
+	// 独自のカラム型でゼロ値が得られない場合はエラーを返す
+	zeroValue() (value any, err error)
 }
 
 type keyColumn interface {
@@ -287,6 +391,10 @@ func (*intColumn[T]) copyValue(value any) any {
 	return value
 }
 
+func (*intColumn[T]) zeroValue() (value any, err error) {
+	return T(0), nil
+}
+
 func (*intColumn[T]) toKey(value any) (_ avltree.Key) {
 	if v, ok := value.(T); ok {
 		return intKey[T](v)
@@ -361,6 +469,10 @@ func (*counterColumn) copyValue(value any) any {
 	return value
 }
 
+func (*counterColumn) zeroValue() (value any, err error) {
+	return CounterType(0), nil
+}
+
 func (*counterColumn) toKey(value any) (_ avltree.Key) {
 	if v, ok := value.(uint32); ok {
 		return intKey[uint32](v)
@@ -379,7 +491,85 @@ func (*counterColumn) unwrapKey(key avltree.Key) (_ any) {
 	}
 }
 
-// 構造的にint系と分ける意味は･･･toKeyがあるか、ないか、か？
+type counter64Column struct {
+	name string
+}
+
+func (c *counter64Column) Name() string {
+	return c.name
+}
+
+func (*counter64Column) Type() ColumnType {
+	return Counter64
+}
+
+func (*counter64Column) IsValidValueType(value any) (ok bool) {
+	_, ok = value.(uint64)
+	return
+}
+
+func (*counter64Column) MinimumDataByteSize() uint64 {
+	return uint64(unsafe.Sizeof(uint64(0)))
+}
+
+func (*counter64Column) MaximumDataByteSize() uint64 {
+	return uint64(unsafe.Sizeof(uint64(0)))
+}
+
+func (*counter64Column) byteSizeHint(value any) (_ uint64) {
+	if _, ok := value.(uint64); ok {
+		return uint64(unsafe.Sizeof(uint64(0)))
+	} else {
+		bug.Panicf("counter64Column.byteSizeHint: value type is not uint64 (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*counter64Column) read(decoder *byteDecoder) (value any, err error) {
+	var counting uint64
+	err = decoder.Uint64(&counting)
+	if err != nil {
+		return nil, err
+	}
+	return counting, nil
+}
+
+func (*counter64Column) write(encoder *byteEncoder, value any) (err error) {
+	if v, ok := value.(uint64); ok {
+		err = encoder.Uint64(v)
+	} else {
+		bug.Panicf("counter64Column.write: value type is not uint64 (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (*counter64Column) copyValue(value any) any {
+	return value
+}
+
+func (*counter64Column) zeroValue() (value any, err error) {
+	return Counter64Type(0), nil
+}
+
+func (*counter64Column) toKey(value any) (_ avltree.Key) {
+	if v, ok := value.(uint64); ok {
+		return intKey[uint64](v)
+	} else {
+		bug.Panicf("counter64Column.toKey: value type is not uint64 (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*counter64Column) unwrapKey(key avltree.Key) (_ any) {
+	if k, ok := key.(*geneKey[uint64]); ok {
+		return k.value
+	} else {
+		bug.Panic("key is not geneKey")
+		return
+	}
+}
+
+// キーにする場合は-0と+0やNaNも区別した全順序で比較する(floatKeyを参照)
 type floatColumn[T float32 | float64] struct {
 	name string
 }
@@ -445,8 +635,31 @@ func (*floatColumn[T]) copyValue(value any) any {
 	return value
 }
 
+func (*floatColumn[T]) zeroValue() (value any, err error) {
+	return T(0), nil
+}
+
+func (*floatColumn[T]) toKey(value any) (_ avltree.Key) {
+	if v, ok := value.(T); ok {
+		return floatKey[T]{value: v}
+	} else {
+		bug.Panicf("floatColumn.toKey: value type is not %T (value: %T %#v)", T(0), value, value)
+		return
+	}
+}
+
+func (*floatColumn[T]) unwrapKey(key avltree.Key) (_ any) {
+	if k, ok := key.(floatKey[T]); ok {
+		return k.value
+	} else {
+		bug.Panic("key is not floatKey[T]")
+		return
+	}
+}
+
 type shortStringColumn struct {
-	name string
+	name      string
+	collation Collation // キーの場合の照合順序
 }
 
 func (c *shortStringColumn) Name() string {
@@ -519,8 +732,15 @@ func (*shortStringColumn) copyValue(value any) any {
 	return value
 }
 
-func (*shortStringColumn) toKey(value any) (_ avltree.Key) {
+func (*shortStringColumn) zeroValue() (value any, err error) {
+	return "", nil
+}
+
+func (c *shortStringColumn) toKey(value any) (_ avltree.Key) {
 	if s, ok := value.(string); ok {
+		if c.collation != BinaryCollation {
+			return collatedStringKey{value: s, collation: c.collation}
+		}
 		return stringkey.StringKey(s)
 	} else {
 		bug.Panicf("shortStringColumn.toKey: value type is not string (value: %T %#v)", value, value)
@@ -532,6 +752,8 @@ func (*shortStringColumn) unwrapKey(key avltree.Key) (_ any) {
 	if s, ok := key.(stringkey.StringKey); ok {
 		// サイズチェック必要？
 		return string(s)
+	} else if k, ok := key.(collatedStringKey); ok {
+		return k.value
 	} else {
 		bug.Panic("key is not stringKey.StringKey")
 		return
@@ -539,8 +761,9 @@ func (*shortStringColumn) unwrapKey(key avltree.Key) (_ any) {
 }
 
 type fixedSizeShortStringColumn struct {
-	name string
-	size uint8
+	name      string
+	size      uint8
+	collation Collation // キーの場合の照合順序
 }
 
 func (c *fixedSizeShortStringColumn) Name() string {
@@ -608,6 +831,11 @@ func (*fixedSizeShortStringColumn) copyValue(value any) any {
 	return value
 }
 
+// 全てのバイトが0の文字列をゼロ値とする
+func (c *fixedSizeShortStringColumn) zeroValue() (value any, err error) {
+	return string(make([]byte, c.size)), nil
+}
+
 func (c *fixedSizeShortStringColumn) toKey(value any) (_ avltree.Key) {
 	if s, ok := value.(string); ok {
 		buf := []byte(s)
@@ -619,6 +847,9 @@ func (c *fixedSizeShortStringColumn) toKey(value any) (_ avltree.Key) {
 			}
 			s = string(buf)
 		}
+		if c.collation != BinaryCollation {
+			return collatedStringKey{value: s, collation: c.collation}
+		}
 		return stringkey.StringKey(s)
 	} else {
 		bug.Panicf("fixedSizeShortStringColumn.toKey: value type is not string (value: %T %#v)", value, value)
@@ -630,6 +861,8 @@ func (*fixedSizeShortStringColumn) unwrapKey(key avltree.Key) (_ any) {
 	if s, ok := key.(stringkey.StringKey); ok {
 		// サイズチェック必要？
 		return string(s)
+	} else if k, ok := key.(collatedStringKey); ok {
+		return k.value
 	} else {
 		bug.Panic("key is not stringKey.StringKey")
 		return
@@ -637,7 +870,8 @@ func (*fixedSizeShortStringColumn) unwrapKey(key avltree.Key) (_ any) {
 }
 
 type longStringColumn struct {
-	name string
+	name      string
+	collation Collation // キーの場合の照合順序
 }
 
 func (c *longStringColumn) Name() string {
@@ -710,6 +944,34 @@ func (*longStringColumn) copyValue(value any) any {
 	return value
 }
 
+func (*longStringColumn) zeroValue() (value any, err error) {
+	return "", nil
+}
+
+// キーはノードにそのまま全体が保存される
+func (c *longStringColumn) toKey(value any) (_ avltree.Key) {
+	if s, ok := value.(string); ok {
+		if c.collation != BinaryCollation {
+			return collatedStringKey{value: s, collation: c.collation}
+		}
+		return stringkey.StringKey(s)
+	} else {
+		bug.Panicf("longStringColumn.toKey: value type is not string (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*longStringColumn) unwrapKey(key avltree.Key) (_ any) {
+	if s, ok := key.(stringkey.StringKey); ok {
+		return string(s)
+	} else if k, ok := key.(collatedStringKey); ok {
+		return k.value
+	} else {
+		bug.Panic("key is not stringKey.StringKey")
+		return
+	}
+}
+
 type fixedSizeLongStringColumn struct {
 	name string
 	size uint16
@@ -780,6 +1042,11 @@ func (*fixedSizeLongStringColumn) copyValue(value any) any {
 	return value
 }
 
+// 全てのバイトが0の文字列をゼロ値とする
+func (c *fixedSizeLongStringColumn) zeroValue() (value any, err error) {
+	return string(make([]byte, c.size)), nil
+}
+
 type textColumn struct {
 	name string
 }
@@ -854,6 +1121,10 @@ func (*textColumn) copyValue(value any) any {
 	return value
 }
 
+func (*textColumn) zeroValue() (value any, err error) {
+	return "", nil
+}
+
 type shortBytesColumn struct {
 	name string
 }
@@ -932,6 +1203,10 @@ func (*shortBytesColumn) copyValue(value any) (_ any) {
 	}
 }
 
+func (*shortBytesColumn) zeroValue() (value any, err error) {
+	return []byte{}, nil
+}
+
 func (*shortBytesColumn) toKey(value any) (_ avltree.Key) {
 	if s, ok := value.([]byte); ok {
 		return bytesKey(s)
@@ -1020,6 +1295,10 @@ func (c *fixedSizeShortBytesColumn) copyValue(value any) (_ any) {
 	}
 }
 
+func (c *fixedSizeShortBytesColumn) zeroValue() (value any, err error) {
+	return make([]byte, c.size), nil
+}
+
 func (c *fixedSizeShortBytesColumn) toKey(value any) (_ avltree.Key) {
 	if buf, ok := value.([]byte); ok {
 		tmp := make([]byte, c.size)
@@ -1119,6 +1398,29 @@ func (*longBytesColumn) copyValue(value any) (_ any) {
 	}
 }
 
+func (*longBytesColumn) zeroValue() (value any, err error) {
+	return []byte{}, nil
+}
+
+// キーはノードにそのまま全体が保存される
+func (*longBytesColumn) toKey(value any) (_ avltree.Key) {
+	if s, ok := value.([]byte); ok {
+		return bytesKey(s)
+	} else {
+		bug.Panicf("longBytesColumn.toKey: value type is not []byte (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*longBytesColumn) unwrapKey(key avltree.Key) (_ any) {
+	if s, ok := key.(bytesKey); ok {
+		return []byte(s)
+	} else {
+		bug.Panic("key is not bytesKey")
+		return
+	}
+}
+
 type fixedSizeLongBytesColumn struct {
 	name string
 	size uint16
@@ -1188,6 +1490,10 @@ func (c *fixedSizeLongBytesColumn) copyValue(value any) (_ any) {
 	}
 }
 
+func (c *fixedSizeLongBytesColumn) zeroValue() (value any, err error) {
+	return make([]byte, c.size), nil
+}
+
 type blobColumn struct {
 	name string
 }
@@ -1265,3 +1571,1233 @@ func (*blobColumn) copyValue(value any) (_ any) {
 		return
 	}
 }
+
+func (*blobColumn) zeroValue() (value any, err error) {
+	return []byte{}, nil
+}
+
+type boolColumn struct {
+	name string
+}
+
+func (c *boolColumn) Name() string {
+	return c.name
+}
+
+func (*boolColumn) Type() ColumnType {
+	return Bool
+}
+
+func (*boolColumn) IsValidValueType(value any) (ok bool) {
+	_, ok = value.(bool)
+	return
+}
+
+func (*boolColumn) MinimumDataByteSize() uint64 {
+	return boolByteSize
+}
+
+func (*boolColumn) MaximumDataByteSize() uint64 {
+	return boolByteSize
+}
+
+func (*boolColumn) byteSizeHint(value any) (_ uint64) {
+	if _, ok := value.(bool); ok {
+		return boolByteSize
+	} else {
+		bug.Panicf("boolColumn.byteSizeHint: value type is not bool (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*boolColumn) read(decoder *byteDecoder) (value any, err error) {
+	var v uint8
+	err = decoder.Uint8(&v)
+	if err != nil {
+		return nil, err
+	}
+	return v != 0, nil
+}
+
+func (*boolColumn) write(encoder *byteEncoder, value any) (err error) {
+	if v, ok := value.(bool); ok {
+		if v {
+			err = encoder.Uint8(1)
+		} else {
+			err = encoder.Uint8(0)
+		}
+	} else {
+		bug.Panicf("boolColumn.write: value type is not bool (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (*boolColumn) copyValue(value any) any {
+	return value
+}
+
+func (*boolColumn) zeroValue() (value any, err error) {
+	return false, nil
+}
+
+// 時刻はUTCにしてナノ秒まで保存する
+// ファイルには1970-01-01 00:00:00 UTCからの秒数(int64)とナノ秒(uint32)の順で書き込む
+type timestampColumn struct {
+	name string
+}
+
+func (c *timestampColumn) Name() string {
+	return c.name
+}
+
+func (*timestampColumn) Type() ColumnType {
+	return Timestamp
+}
+
+func (*timestampColumn) IsValidValueType(value any) (ok bool) {
+	_, ok = value.(time.Time)
+	return
+}
+
+func (*timestampColumn) MinimumDataByteSize() uint64 {
+	return timestampByteSize
+}
+
+func (*timestampColumn) MaximumDataByteSize() uint64 {
+	return timestampByteSize
+}
+
+func (*timestampColumn) byteSizeHint(value any) (_ uint64) {
+	if _, ok := value.(time.Time); ok {
+		return timestampByteSize
+	} else {
+		bug.Panicf("timestampColumn.byteSizeHint: value type is not time.Time (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*timestampColumn) read(decoder *byteDecoder) (value any, err error) {
+	var (
+		sec  uint64
+		nsec uint32
+	)
+	err = decoder.Uint64(&sec)
+	if err != nil {
+		return nil, err
+	}
+	err = decoder.Uint32(&nsec)
+	if err != nil {
+		return nil, err
+	}
+	return time.Unix(int64(sec), int64(nsec)).UTC(), nil
+}
+
+func (*timestampColumn) write(encoder *byteEncoder, value any) (err error) {
+	if v, ok := value.(time.Time); ok {
+		err = encoder.Uint64(uint64(v.Unix()))
+		if err != nil {
+			return
+		}
+		err = encoder.Uint32(uint32(v.Nanosecond()))
+	} else {
+		bug.Panicf("timestampColumn.write: value type is not time.Time (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (*timestampColumn) copyValue(value any) any {
+	return value
+}
+
+func (*timestampColumn) zeroValue() (value any, err error) {
+	return time.Unix(0, 0).UTC(), nil
+}
+
+func (*timestampColumn) toKey(value any) (_ avltree.Key) {
+	if v, ok := value.(time.Time); ok {
+		return timestampKey{sec: v.Unix(), nsec: int32(v.Nanosecond())}
+	} else {
+		bug.Panicf("timestampColumn.toKey: value type is not time.Time (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*timestampColumn) unwrapKey(key avltree.Key) (_ any) {
+	if k, ok := key.(timestampKey); ok {
+		return time.Unix(k.sec, int64(k.nsec)).UTC()
+	} else {
+		bug.Panic("key is not timestampKey")
+		return
+	}
+}
+
+// 日付はtime.Timeの(time.Timeのロケーションでの)年月日だけを保存する
+// ファイルには1970-01-01からの日数(int32)を書き込む
+// 読み込んだ値はその日のUTCの0時0分0秒のtime.Timeとなる
+type dateColumn struct {
+	name string
+}
+
+func (c *dateColumn) Name() string {
+	return c.name
+}
+
+func (*dateColumn) Type() ColumnType {
+	return Date
+}
+
+func (*dateColumn) IsValidValueType(value any) (ok bool) {
+	_, ok = value.(time.Time)
+	return
+}
+
+func (*dateColumn) MinimumDataByteSize() uint64 {
+	return dateByteSize
+}
+
+func (*dateColumn) MaximumDataByteSize() uint64 {
+	return dateByteSize
+}
+
+func (*dateColumn) byteSizeHint(value any) (_ uint64) {
+	if _, ok := value.(time.Time); ok {
+		return dateByteSize
+	} else {
+		bug.Panicf("dateColumn.byteSizeHint: value type is not time.Time (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*dateColumn) read(decoder *byteDecoder) (value any, err error) {
+	var days int32
+	err = decoder.Int32(&days)
+	if err != nil {
+		return nil, err
+	}
+	return time.Unix(int64(days)*secondsPerDay, 0).UTC(), nil
+}
+
+func (*dateColumn) write(encoder *byteEncoder, value any) (err error) {
+	if v, ok := value.(time.Time); ok {
+		y, m, d := v.Date()
+		days := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / secondsPerDay
+		err = encoder.Int32(int32(days))
+	} else {
+		bug.Panicf("dateColumn.write: value type is not time.Time (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (*dateColumn) copyValue(value any) any {
+	return value
+}
+
+func (*dateColumn) zeroValue() (value any, err error) {
+	return time.Unix(0, 0).UTC(), nil
+}
+
+// 固定小数点数はカラムのscaleに合わせた整数値(int64)として保存する
+// precisionは整数部と小数部を合わせた最大桁数
+type decimalColumn struct {
+	name      string
+	precision uint8
+	scale     uint8
+}
+
+func (c *decimalColumn) Name() string {
+	return c.name
+}
+
+func (*decimalColumn) Type() ColumnType {
+	return DecimalColumnType
+}
+
+func (c *decimalColumn) IsValidValueType(value any) bool {
+	if v, ok := value.(Decimal); ok {
+		_, ok = c.unscaled(v)
+		return ok
+	} else {
+		return false
+	}
+}
+
+func (*decimalColumn) MinimumDataByteSize() uint64 {
+	return decimalByteSize
+}
+
+func (*decimalColumn) MaximumDataByteSize() uint64 {
+	return decimalByteSize
+}
+
+func (*decimalColumn) byteSizeHint(value any) (_ uint64) {
+	if _, ok := value.(Decimal); ok {
+		return decimalByteSize
+	} else {
+		bug.Panicf("decimalColumn.byteSizeHint: value type is not Decimal (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (c *decimalColumn) read(decoder *byteDecoder) (value any, err error) {
+	var v int64
+	err = decoder.Int64(&v)
+	if err != nil {
+		return nil, err
+	}
+	return Decimal{unscaled: v, scale: c.scale}, nil
+}
+
+func (c *decimalColumn) write(encoder *byteEncoder, value any) (err error) {
+	if v, ok := value.(Decimal); ok {
+		if u, ok := c.unscaled(v); ok {
+			err = encoder.Int64(u)
+		} else {
+			bug.Panicf("decimalColumn.write: value is out of range (value: %s)", v)
+		}
+	} else {
+		bug.Panicf("decimalColumn.write: value type is not Decimal (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (*decimalColumn) copyValue(value any) any {
+	return value
+}
+
+func (c *decimalColumn) zeroValue() (value any, err error) {
+	return Decimal{unscaled: 0, scale: c.scale}, nil
+}
+
+func (c *decimalColumn) toKey(value any) (_ avltree.Key) {
+	if v, ok := value.(Decimal); ok {
+		if u, ok := c.unscaled(v); ok {
+			return intKey[int64](u)
+		} else {
+			bug.Panicf("decimalColumn.toKey: value is out of range (value: %s)", v)
+			return
+		}
+	} else {
+		bug.Panicf("decimalColumn.toKey: value type is not Decimal (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (c *decimalColumn) unwrapKey(key avltree.Key) (_ any) {
+	if k, ok := key.(*geneKey[int64]); ok {
+		return Decimal{unscaled: k.value, scale: c.scale}
+	} else {
+		bug.Panic("key is not *geneKey[int64]")
+		return
+	}
+}
+
+// カラムのscaleに合わせた整数値を返す
+// 小数点以下の桁が切り捨てられる場合やprecisionの桁数を超える場合はokはfalseとなる
+func (c *decimalColumn) unscaled(value Decimal) (u int64, ok bool) {
+	u, ok = value.rescale(c.scale)
+	if !ok {
+		return
+	}
+	limit := decimalPow10[c.precision]
+	ok = -limit < u && u < limit
+	return
+}
+
+// UUIDは16バイトをそのままのバイト順で保存する
+type uuidColumn struct {
+	name string
+}
+
+func (c *uuidColumn) Name() string {
+	return c.name
+}
+
+func (*uuidColumn) Type() ColumnType {
+	return UUIDColumnType
+}
+
+func (*uuidColumn) IsValidValueType(value any) (ok bool) {
+	_, ok = value.(UUID)
+	return
+}
+
+func (*uuidColumn) MinimumDataByteSize() uint64 {
+	return uuidByteSize
+}
+
+func (*uuidColumn) MaximumDataByteSize() uint64 {
+	return uuidByteSize
+}
+
+func (*uuidColumn) byteSizeHint(value any) (_ uint64) {
+	if _, ok := value.(UUID); ok {
+		return uuidByteSize
+	} else {
+		bug.Panicf("uuidColumn.byteSizeHint: value type is not UUID (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*uuidColumn) read(decoder *byteDecoder) (value any, err error) {
+	var v UUID
+	err = decoder.RawBytes(v[:])
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (*uuidColumn) write(encoder *byteEncoder, value any) (err error) {
+	if v, ok := value.(UUID); ok {
+		err = encoder.RawBytes(v[:])
+	} else {
+		bug.Panicf("uuidColumn.write: value type is not UUID (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (*uuidColumn) copyValue(value any) any {
+	return value
+}
+
+func (*uuidColumn) zeroValue() (value any, err error) {
+	return UUID{}, nil
+}
+
+func (*uuidColumn) toKey(value any) (_ avltree.Key) {
+	if v, ok := value.(UUID); ok {
+		return uuidKey(v)
+	} else {
+		bug.Panicf("uuidColumn.toKey: value type is not UUID (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*uuidColumn) unwrapKey(key avltree.Key) (_ any) {
+	if k, ok := key.(uuidKey); ok {
+		return UUID(k)
+	} else {
+		bug.Panic("key is not uuidKey")
+		return
+	}
+}
+
+// Int128は上位64ビット、下位64ビットの順で保存する
+type int128Column struct {
+	name string
+}
+
+func (c *int128Column) Name() string {
+	return c.name
+}
+
+func (*int128Column) Type() ColumnType {
+	return Int128ColumnType
+}
+
+func (*int128Column) IsValidValueType(value any) (ok bool) {
+	_, ok = value.(Int128)
+	return
+}
+
+func (*int128Column) MinimumDataByteSize() uint64 {
+	return int128ByteSize
+}
+
+func (*int128Column) MaximumDataByteSize() uint64 {
+	return int128ByteSize
+}
+
+func (*int128Column) byteSizeHint(value any) (_ uint64) {
+	if _, ok := value.(Int128); ok {
+		return int128ByteSize
+	} else {
+		bug.Panicf("int128Column.byteSizeHint: value type is not Int128 (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*int128Column) read(decoder *byteDecoder) (value any, err error) {
+	var v Int128
+	err = decoder.Int64(&v.Hi)
+	if err != nil {
+		return nil, err
+	}
+	err = decoder.Uint64(&v.Lo)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (*int128Column) write(encoder *byteEncoder, value any) (err error) {
+	if v, ok := value.(Int128); ok {
+		err = encoder.Int64(v.Hi)
+		if err != nil {
+			return
+		}
+		err = encoder.Uint64(v.Lo)
+	} else {
+		bug.Panicf("int128Column.write: value type is not Int128 (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (*int128Column) copyValue(value any) any {
+	return value
+}
+
+func (*int128Column) zeroValue() (value any, err error) {
+	return Int128{}, nil
+}
+
+func (*int128Column) toKey(value any) (_ avltree.Key) {
+	if v, ok := value.(Int128); ok {
+		return int128Key(v)
+	} else {
+		bug.Panicf("int128Column.toKey: value type is not Int128 (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*int128Column) unwrapKey(key avltree.Key) (_ any) {
+	if k, ok := key.(int128Key); ok {
+		return Int128(k)
+	} else {
+		bug.Panic("key is not int128Key")
+		return
+	}
+}
+
+// Uint128は上位64ビット、下位64ビットの順で保存する
+type uint128Column struct {
+	name string
+}
+
+func (c *uint128Column) Name() string {
+	return c.name
+}
+
+func (*uint128Column) Type() ColumnType {
+	return Uint128ColumnType
+}
+
+func (*uint128Column) IsValidValueType(value any) (ok bool) {
+	_, ok = value.(Uint128)
+	return
+}
+
+func (*uint128Column) MinimumDataByteSize() uint64 {
+	return int128ByteSize
+}
+
+func (*uint128Column) MaximumDataByteSize() uint64 {
+	return int128ByteSize
+}
+
+func (*uint128Column) byteSizeHint(value any) (_ uint64) {
+	if _, ok := value.(Uint128); ok {
+		return int128ByteSize
+	} else {
+		bug.Panicf("uint128Column.byteSizeHint: value type is not Uint128 (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*uint128Column) read(decoder *byteDecoder) (value any, err error) {
+	var v Uint128
+	err = decoder.Uint64(&v.Hi)
+	if err != nil {
+		return nil, err
+	}
+	err = decoder.Uint64(&v.Lo)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (*uint128Column) write(encoder *byteEncoder, value any) (err error) {
+	if v, ok := value.(Uint128); ok {
+		err = encoder.Uint64(v.Hi)
+		if err != nil {
+			return
+		}
+		err = encoder.Uint64(v.Lo)
+	} else {
+		bug.Panicf("uint128Column.write: value type is not Uint128 (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (*uint128Column) copyValue(value any) any {
+	return value
+}
+
+func (*uint128Column) zeroValue() (value any, err error) {
+	return Uint128{}, nil
+}
+
+func (*uint128Column) toKey(value any) (_ avltree.Key) {
+	if v, ok := value.(Uint128); ok {
+		return uint128Key(v)
+	} else {
+		bug.Panicf("uint128Column.toKey: value type is not Uint128 (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (*uint128Column) unwrapKey(key avltree.Key) (_ any) {
+	if k, ok := key.(uint128Key); ok {
+		return Uint128(k)
+	} else {
+		bug.Panic("key is not uint128Key")
+		return
+	}
+}
+
+// Listのカラムの要素のカラム型と最大要素数を取得するためのインターフェース
+type listColumnSpec interface {
+	listSpec() (elem Column, maxCount uint16)
+
+	// 要素数0の値([]int64{}など)
+	emptyList() any
+}
+
+// Listの要素のカラム型に対応したlistColumnを生成する
+// Listの要素に使えないカラム型の場合はokはfalseとなる
+func newListColumn(name string, elemType ColumnType, maxCount uint16) (col Column, ok bool) {
+	switch elemType {
+	default:
+		return nil, false
+	case Int8:
+		col = &listColumn[int8]{name: name, elem: &intColumn[int8]{}, maxCount: maxCount}
+	case Uint8:
+		col = &listColumn[uint8]{name: name, elem: &intColumn[uint8]{}, maxCount: maxCount}
+	case Int16:
+		col = &listColumn[int16]{name: name, elem: &intColumn[int16]{}, maxCount: maxCount}
+	case Uint16:
+		col = &listColumn[uint16]{name: name, elem: &intColumn[uint16]{}, maxCount: maxCount}
+	case Int32:
+		col = &listColumn[int32]{name: name, elem: &intColumn[int32]{}, maxCount: maxCount}
+	case Uint32:
+		col = &listColumn[uint32]{name: name, elem: &intColumn[uint32]{}, maxCount: maxCount}
+	case Int64:
+		col = &listColumn[int64]{name: name, elem: &intColumn[int64]{}, maxCount: maxCount}
+	case Uint64:
+		col = &listColumn[uint64]{name: name, elem: &intColumn[uint64]{}, maxCount: maxCount}
+	case Float32:
+		col = &listColumn[float32]{name: name, elem: &floatColumn[float32]{}, maxCount: maxCount}
+	case Float64:
+		col = &listColumn[float64]{name: name, elem: &floatColumn[float64]{}, maxCount: maxCount}
+	case Bool:
+		col = &listColumn[bool]{name: name, elem: &boolColumn{}, maxCount: maxCount}
+	case ShortString:
+		col = &listColumn[string]{name: name, elem: &shortStringColumn{}, maxCount: maxCount}
+	case ShortBytes:
+		col = &listColumn[[]byte]{name: name, elem: &shortBytesColumn{}, maxCount: maxCount}
+	case Timestamp:
+		col = &listColumn[time.Time]{name: name, elem: &timestampColumn{}, maxCount: maxCount}
+	}
+	return col, true
+}
+
+// 要素数(uint16)の後に要素のカラム型の形式で要素を順に保存する
+// 値は要素のカラム型に対応したGoの型のスライス([]int64や[]stringなど)となる
+type listColumn[T any] struct {
+	name     string
+	elem     Column
+	maxCount uint16
+}
+
+func (c *listColumn[T]) listSpec() (Column, uint16) {
+	return c.elem, c.maxCount
+}
+
+func (*listColumn[T]) emptyList() any {
+	return []T{}
+}
+
+func (c *listColumn[T]) Name() string {
+	return c.name
+}
+
+func (*listColumn[T]) Type() ColumnType {
+	return List
+}
+
+func (c *listColumn[T]) IsValidValueType(value any) bool {
+	if v, ok := value.([]T); ok {
+		if len(v) > int(c.maxCount) {
+			return false
+		}
+		for _, x := range v {
+			if !c.elem.IsValidValueType(x) {
+				return false
+			}
+		}
+		return true
+	} else {
+		return false
+	}
+}
+
+func (*listColumn[T]) MinimumDataByteSize() uint64 {
+	return listByteSizeDataLength
+}
+
+// 要素ごとの長さ情報のサイズも含める
+func (c *listColumn[T]) MaximumDataByteSize() uint64 {
+	elemSize := c.elem.MaximumDataByteSize()
+	if c.elem.MinimumDataByteSize() != elemSize {
+		// 可変長の要素はShortStringとShortBytesだけ
+		elemSize += shortStringByteSizeDataLength
+	}
+	return listByteSizeDataLength + uint64(c.maxCount)*elemSize
+}
+
+func (c *listColumn[T]) byteSizeHint(value any) (_ uint64) {
+	if v, ok := value.([]T); ok {
+		size := uint64(listByteSizeDataLength)
+		for _, x := range v {
+			size += c.elem.byteSizeHint(x)
+		}
+		return size
+	} else {
+		bug.Panicf("listColumn.byteSizeHint: value type is not %T (value: %T %#v)", []T(nil), value, value)
+		return
+	}
+}
+
+func (c *listColumn[T]) read(decoder *byteDecoder) (value any, err error) {
+	var count uint16
+	err = decoder.Uint16(&count)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]T, count)
+	for i := range list {
+		var x any
+		x, err = c.elem.read(decoder)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = x.(T)
+	}
+	return list, nil
+}
+
+func (c *listColumn[T]) write(encoder *byteEncoder, value any) (err error) {
+	if v, ok := value.([]T); ok {
+		if len(v) > int(c.maxCount) {
+			bug.Panicf("listColumn.write: too many elements (count: %d, max: %d)", len(v), c.maxCount)
+		}
+		err = encoder.Uint16(uint16(len(v)))
+		if err != nil {
+			return
+		}
+		for _, x := range v {
+			err = c.elem.write(encoder, x)
+			if err != nil {
+				return
+			}
+		}
+	} else {
+		bug.Panicf("listColumn.write: value type is not %T (value: %T %#v)", []T(nil), value, value)
+	}
+	return
+}
+
+func (c *listColumn[T]) copyValue(value any) any {
+	if v, ok := value.([]T); ok {
+		list := make([]T, len(v))
+		for i, x := range v {
+			list[i] = c.elem.copyValue(x).(T)
+		}
+		return list
+	} else {
+		bug.Panicf("listColumn.copyValue: value type is not %T (value: %T %#v)", []T(nil), value, value)
+		return nil
+	}
+}
+
+func (c *listColumn[T]) zeroValue() (value any, err error) {
+	return c.emptyList(), nil
+}
+
+// サブカラムを順にそれぞれのカラム型の形式で保存する
+// 値はサブカラム名をキーとしたmap[string]anyとなる(RecordのColumnではStructValueで包んで返す)
+// 書き込む値としてはStructValueも受け付ける
+type structColumn struct {
+	name    string
+	columns []Column
+	minSize uint64
+	maxSize uint64
+}
+
+// サブカラムのリストからstructColumnを生成する
+// データサイズにはサブカラムごとの長さ情報のサイズも含める
+func newStructColumn(name string, columns []Column) *structColumn {
+	col := &structColumn{
+		name:    name,
+		columns: columns,
+	}
+	for _, sub := range columns {
+		lengthSize := dataLengthByteSize(sub)
+		col.minSize += sub.MinimumDataByteSize() + lengthSize
+		col.maxSize += sub.MaximumDataByteSize() + lengthSize
+	}
+	return col
+}
+
+// カラムのデータの前に置かれる長さ情報のバイトサイズ
+// 長さ情報がデータ領域の最小バイトサイズに含まれないカラム型のみ0以外となる
+func dataLengthByteSize(col Column) uint64 {
+	switch col.Type() {
+	case ShortString:
+		return shortStringByteSizeDataLength
+	case LongString:
+		return longStringByteSizeDataLength
+	case Text:
+		return textByteSizeDataLength
+	case ShortBytes:
+		return shortBytesByteSizeDataLength
+	case LongBytes:
+		return longBytesByteSizeDataLength
+	case Blob:
+		return blobByteSizeDataLength
+	case JSON:
+		return jsonByteSizeDataLength
+	case Custom:
+		return customByteSizeDataLength
+	default:
+		return 0
+	}
+}
+
+func (c *structColumn) Name() string {
+	return c.name
+}
+
+func (*structColumn) Type() ColumnType {
+	return Struct
+}
+
+func (c *structColumn) IsValidValueType(value any) bool {
+	if m, ok := structValueMap(value); ok {
+		for _, sub := range c.columns {
+			if v, ok := m[sub.Name()]; !ok || !sub.IsValidValueType(v) {
+				return false
+			}
+		}
+		return true
+	} else {
+		return false
+	}
+}
+
+func (c *structColumn) MinimumDataByteSize() uint64 {
+	return c.minSize
+}
+
+func (c *structColumn) MaximumDataByteSize() uint64 {
+	return c.maxSize
+}
+
+func (c *structColumn) byteSizeHint(value any) (_ uint64) {
+	if m, ok := structValueMap(value); ok {
+		var size uint64
+		for _, sub := range c.columns {
+			size += sub.byteSizeHint(m[sub.Name()])
+		}
+		return size
+	} else {
+		bug.Panicf("structColumn.byteSizeHint: value type is not map[string]any (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (c *structColumn) read(decoder *byteDecoder) (value any, err error) {
+	m := make(map[string]any, len(c.columns))
+	for _, sub := range c.columns {
+		var v any
+		v, err = sub.read(decoder)
+		if err != nil {
+			return nil, err
+		}
+		m[sub.Name()] = v
+	}
+	return m, nil
+}
+
+func (c *structColumn) write(encoder *byteEncoder, value any) (err error) {
+	if m, ok := structValueMap(value); ok {
+		for _, sub := range c.columns {
+			err = sub.write(encoder, m[sub.Name()])
+			if err != nil {
+				return
+			}
+		}
+	} else {
+		bug.Panicf("structColumn.write: value type is not map[string]any (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (c *structColumn) copyValue(value any) any {
+	if m, ok := structValueMap(value); ok {
+		cp := make(map[string]any, len(c.columns))
+		for _, sub := range c.columns {
+			cp[sub.Name()] = sub.copyValue(m[sub.Name()])
+		}
+		return cp
+	} else {
+		bug.Panicf("structColumn.copyValue: value type is not map[string]any (value: %T %#v)", value, value)
+		return nil
+	}
+}
+
+// 全てのサブカラムがゼロ値のマップをゼロ値とする
+func (c *structColumn) zeroValue() (value any, err error) {
+	m := make(map[string]any, len(c.columns))
+	for _, sub := range c.columns {
+		var v any
+		v, err = sub.zeroValue()
+		if err != nil {
+			return nil, err
+		}
+		m[sub.Name()] = v
+	}
+	return m, nil
+}
+
+// サブカラム名に対応するサブカラムを返す
+// 存在しない場合はnilを返す
+func (c *structColumn) column(name string) Column {
+	for _, sub := range c.columns {
+		if sub.Name() == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// JSONのドキュメントを空白を除いた形にして保存する
+// 値はjson.RawMessageとなる
+type jsonColumn struct {
+	name string
+}
+
+// 空白を除いたJSONのドキュメントを返す
+// JSONとして不正な場合やサイズが大きすぎる場合はokはfalseとなる
+func compactJSON(doc json.RawMessage) (buf []byte, ok bool) {
+	var b bytes.Buffer
+	if json.Compact(&b, doc) != nil || b.Len() > jsonMaximumDataByteSize {
+		return nil, false
+	}
+	return b.Bytes(), true
+}
+
+func (c *jsonColumn) Name() string {
+	return c.name
+}
+
+func (*jsonColumn) Type() ColumnType {
+	return JSON
+}
+
+func (*jsonColumn) IsValidValueType(value any) bool {
+	if doc, ok := value.(json.RawMessage); ok {
+		_, ok = compactJSON(doc)
+		return ok
+	} else {
+		return false
+	}
+}
+
+func (*jsonColumn) MinimumDataByteSize() uint64 {
+	return jsonMinimumDataByteSize
+}
+
+func (*jsonColumn) MaximumDataByteSize() uint64 {
+	return jsonMaximumDataByteSize
+}
+
+func (*jsonColumn) byteSizeHint(value any) (_ uint64) {
+	if doc, ok := value.(json.RawMessage); ok {
+		buf, _ := compactJSON(doc)
+		return uint64(len(buf) + jsonByteSizeDataLength)
+	} else {
+		bug.Panicf("jsonColumn.byteSizeHint: value type is not json.RawMessage (value: %T %#v)", value, value)
+		return
+	}
+}
+
+// 長さ0のデータ(カラム追加時のゼロ値)はnullとして読み込む
+func (*jsonColumn) read(decoder *byteDecoder) (value any, err error) {
+	var size uint32
+	err = decoder.Uint32(&size)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return json.RawMessage("null"), nil
+	}
+	buf := make([]byte, size)
+	err = decoder.RawBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(buf), nil
+}
+
+func (*jsonColumn) write(encoder *byteEncoder, value any) (err error) {
+	if doc, ok := value.(json.RawMessage); ok {
+		buf, ok := compactJSON(doc)
+		if !ok {
+			bug.Panicf("jsonColumn.write: invalid JSON (value: %s)", doc)
+		}
+		err = encoder.Uint32(uint32(len(buf)))
+		if err != nil {
+			return
+		}
+		err = encoder.RawBytes(buf)
+	} else {
+		bug.Panicf("jsonColumn.write: value type is not json.RawMessage (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (*jsonColumn) copyValue(value any) any {
+	if doc, ok := value.(json.RawMessage); ok {
+		return append(json.RawMessage(nil), doc...)
+	} else {
+		bug.Panicf("jsonColumn.copyValue: value type is not json.RawMessage (value: %T %#v)", value, value)
+		return nil
+	}
+}
+
+// nullをゼロ値とする
+func (*jsonColumn) zeroValue() (value any, err error) {
+	return json.RawMessage("null"), nil
+}
+
+// 値の宣言順の序数を保存する
+// 値の数が256以下なら1バイト、それより多い場合は2バイトで保存する
+// 値はstringとなり、キーとして使う場合は宣言順に並ぶ
+type enumColumn struct {
+	name    string
+	values  []string
+	ordinal map[string]uint16
+}
+
+// 値のリストからenumColumnを生成する
+// 値が空や重複がある場合や値の数が多すぎる場合や値が長すぎる場合はokはfalseとなる
+func newEnumColumn(name string, values []string) (col *enumColumn, ok bool) {
+	if len(values) == 0 || len(values) > MaximumEnumValueCount {
+		return nil, false
+	}
+	col = &enumColumn{
+		name:    name,
+		values:  values,
+		ordinal: make(map[string]uint16, len(values)),
+	}
+	for i, v := range values {
+		if len(v) > shortStringMaximumDataByteSize {
+			return nil, false
+		}
+		if _, dup := col.ordinal[v]; dup {
+			return nil, false
+		}
+		col.ordinal[v] = uint16(i)
+	}
+	return col, true
+}
+
+func (c *enumColumn) Name() string {
+	return c.name
+}
+
+func (*enumColumn) Type() ColumnType {
+	return Enum
+}
+
+func (c *enumColumn) IsValidValueType(value any) bool {
+	if s, ok := value.(string); ok {
+		_, ok = c.ordinal[s]
+		return ok
+	} else {
+		return false
+	}
+}
+
+func (c *enumColumn) byteSize() uint64 {
+	if len(c.values) <= 1<<8 {
+		return shortEnumByteSize
+	} else {
+		return longEnumByteSize
+	}
+}
+
+func (c *enumColumn) MinimumDataByteSize() uint64 {
+	return c.byteSize()
+}
+
+func (c *enumColumn) MaximumDataByteSize() uint64 {
+	return c.byteSize()
+}
+
+func (c *enumColumn) byteSizeHint(value any) uint64 {
+	return c.byteSize()
+}
+
+func (c *enumColumn) read(decoder *byteDecoder) (value any, err error) {
+	var ordinal uint16
+	if c.byteSize() == shortEnumByteSize {
+		var v uint8
+		err = decoder.Uint8(&v)
+		ordinal = uint16(v)
+	} else {
+		err = decoder.Uint16(&ordinal)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int(ordinal) >= len(c.values) {
+		return nil, &ErrWrongFileFormat{"Invalid Enum ordinal"}
+	}
+	return c.values[ordinal], nil
+}
+
+func (c *enumColumn) write(encoder *byteEncoder, value any) (err error) {
+	if s, ok := value.(string); ok {
+		ordinal, ok := c.ordinal[s]
+		if !ok {
+			bug.Panicf("enumColumn.write: unknown value (value: %q)", s)
+		}
+		if c.byteSize() == shortEnumByteSize {
+			err = encoder.Uint8(uint8(ordinal))
+		} else {
+			err = encoder.Uint16(ordinal)
+		}
+	} else {
+		bug.Panicf("enumColumn.write: value type is not string (value: %T %#v)", value, value)
+	}
+	return
+}
+
+func (*enumColumn) copyValue(value any) any {
+	return value
+}
+
+// 最初の値をゼロ値とする
+func (c *enumColumn) zeroValue() (value any, err error) {
+	return c.values[0], nil
+}
+
+func (c *enumColumn) toKey(value any) (_ avltree.Key) {
+	if s, ok := value.(string); ok {
+		if ordinal, ok := c.ordinal[s]; ok {
+			return intKey[uint16](ordinal)
+		} else {
+			bug.Panicf("enumColumn.toKey: unknown value (value: %q)", s)
+			return
+		}
+	} else {
+		bug.Panicf("enumColumn.toKey: value type is not string (value: %T %#v)", value, value)
+		return
+	}
+}
+
+func (c *enumColumn) unwrapKey(key avltree.Key) (_ any) {
+	if k, ok := key.(*geneKey[uint16]); ok {
+		return c.values[k.value]
+	} else {
+		bug.Panic("key is not *geneKey[uint16]")
+		return
+	}
+}
+
+// RegisterColumnTypeで登録された独自のカラム型
+// ColumnCodecのEncodeで得たバイト列の長さ(uint32)の後にバイト列を保存する
+type customColumn struct {
+	name     string
+	typeName string
+	codec    ColumnCodec
+}
+
+// ColumnCodecのEncodeを呼び出す
+// エラーの場合や長すぎる場合はpanicでエラーを返す
+func (c *customColumn) encode(value any) []byte {
+	buf, err := c.codec.Encode(value)
+	if err != nil {
+		panic(err)
+	}
+	if uint64(len(buf)) > c.codec.MaximumDataByteSize() {
+		panic(ErrTooLargeData)
+	}
+	return buf
+}
+
+func (c *customColumn) Name() string {
+	return c.name
+}
+
+func (*customColumn) Type() ColumnType {
+	return Custom
+}
+
+func (c *customColumn) IsValidValueType(value any) bool {
+	return c.codec.IsValidValue(value)
+}
+
+func (*customColumn) MinimumDataByteSize() uint64 {
+	return customMinimumDataByteSize
+}
+
+func (c *customColumn) MaximumDataByteSize() uint64 {
+	return c.codec.MaximumDataByteSize()
+}
+
+// Encodeを呼び出すのでノードに書き込むデータのサイズの計算では使わない(Table.encodeCodecColumnsを参照)
+func (c *customColumn) byteSizeHint(value any) uint64 {
+	return uint64(len(c.encode(value)) + customByteSizeDataLength)
+}
+
+func (c *customColumn) read(decoder *byteDecoder) (value any, err error) {
+	var size uint32
+	err = decoder.Uint32(&size)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	err = decoder.RawBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	return c.codec.Decode(buf)
+}
+
+func (c *customColumn) write(encoder *byteEncoder, value any) (err error) {
+	buf := c.encode(value)
+	err = encoder.Uint32(uint32(len(buf)))
+	if err != nil {
+		return
+	}
+	err = encoder.RawBytes(buf)
+	return
+}
+
+// EncodeとDecodeを通してコピーする
+func (c *customColumn) copyValue(value any) any {
+	v, err := c.codec.Decode(c.encode(value))
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// 長さ0のバイト列をDecodeした値をゼロ値とする
+func (c *customColumn) zeroValue() (value any, err error) {
+	return c.codec.Decode([]byte{})
+}
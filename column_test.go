@@ -5,8 +5,10 @@ package unkodb
 
 import (
 	"bytes"
+	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/neetsdkasu/avltree"
 	"github.com/neetsdkasu/avltree/stringkey"
@@ -221,9 +223,9 @@ func TestColumn(t *testing.T) {
 					4,
 				},
 			},
-			false,
-			nil,
-			nil,
+			true,
+			float32(-0.5),
+			floatKey[float32]{value: -0.5},
 		},
 		&TestCase{
 			&floatColumn[float64]{name: "foo"},
@@ -240,9 +242,9 @@ func TestColumn(t *testing.T) {
 					8,
 				},
 			},
-			false,
-			nil,
-			nil,
+			true,
+			float64(-0.5),
+			floatKey[float64]{value: -0.5},
 		},
 		&TestCase{
 			&shortStringColumn{name: "foo"},
@@ -297,9 +299,9 @@ func TestColumn(t *testing.T) {
 					3 + 2,
 				},
 			},
-			false,
-			nil,
-			nil,
+			true,
+			"abc",
+			stringkey.StringKey("abc"),
 		},
 		&TestCase{
 			&fixedSizeLongStringColumn{name: "foo", size: 5},
@@ -392,9 +394,9 @@ func TestColumn(t *testing.T) {
 					3 + 2,
 				},
 			},
-			false,
-			nil,
-			nil,
+			true,
+			[]byte("abc"),
+			bytesKey("abc"),
 		},
 		&TestCase{
 			&fixedSizeLongBytesColumn{name: "foo", size: 5},
@@ -434,6 +436,277 @@ func TestColumn(t *testing.T) {
 			nil,
 			nil,
 		},
+		&TestCase{
+			&boolColumn{name: "foo"},
+			"foo",
+			Bool,
+			1,
+			1,
+			true,
+			uint8(1),
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					true,
+					true,
+					1,
+				},
+				&byteSizeTestCase{
+					false,
+					false,
+					1,
+				},
+			},
+			false,
+			nil,
+			nil,
+		},
+		&TestCase{
+			&timestampColumn{name: "foo"},
+			"foo",
+			Timestamp,
+			12,
+			12,
+			time.Now(),
+			int64(0),
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					time.Date(2023, 4, 5, 6, 7, 8, 123456789, time.FixedZone("JST", 9*60*60)),
+					time.Date(2023, 4, 4, 21, 7, 8, 123456789, time.UTC),
+					12,
+				},
+				&byteSizeTestCase{
+					time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+					time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+					12,
+				},
+				&byteSizeTestCase{
+					time.Date(1969, 12, 31, 23, 59, 59, 999999999, time.UTC),
+					time.Date(1969, 12, 31, 23, 59, 59, 999999999, time.UTC),
+					12,
+				},
+			},
+			true,
+			time.Date(1970, 1, 1, 9, 0, 1, 2, time.FixedZone("JST", 9*60*60)),
+			timestampKey{sec: 1, nsec: 2},
+		},
+		&TestCase{
+			&dateColumn{name: "foo"},
+			"foo",
+			Date,
+			4,
+			4,
+			time.Now(),
+			"2023-04-05",
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					time.Date(2023, 4, 5, 6, 7, 8, 9, time.FixedZone("JST", 9*60*60)),
+					time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC),
+					4,
+				},
+				&byteSizeTestCase{
+					time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC),
+					time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC),
+					4,
+				},
+			},
+			false,
+			nil,
+			nil,
+		},
+		&TestCase{
+			&decimalColumn{name: "foo", precision: 5, scale: 2},
+			"foo",
+			DecimalColumnType,
+			8,
+			8,
+			NewDecimal(-99999, 2),
+			NewDecimal(100000, 2),
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					NewDecimal(1234, 2),
+					NewDecimal(1234, 2),
+					8,
+				},
+				&byteSizeTestCase{
+					NewDecimal(-5, 0),
+					NewDecimal(-500, 2),
+					8,
+				},
+				&byteSizeTestCase{
+					NewDecimal(1230, 3),
+					NewDecimal(123, 2),
+					8,
+				},
+			},
+			true,
+			NewDecimal(15, 1),
+			intKey[int64](150),
+		},
+		&TestCase{
+			&uuidColumn{name: "foo"},
+			"foo",
+			UUIDColumnType,
+			16,
+			16,
+			UUID{},
+			[16]byte{},
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					UUID{0x12, 0x3e, 0x45, 0x67, 15: 0xff},
+					UUID{0x12, 0x3e, 0x45, 0x67, 15: 0xff},
+					16,
+				},
+			},
+			true,
+			UUID{1, 2, 3},
+			uuidKey{1, 2, 3},
+		},
+		&TestCase{
+			&int128Column{name: "foo"},
+			"foo",
+			Int128ColumnType,
+			16,
+			16,
+			Int128{},
+			Uint128{},
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					Int128FromInt64(-1),
+					Int128{Hi: -1, Lo: 1<<64 - 1},
+					16,
+				},
+				&byteSizeTestCase{
+					Int128{Hi: 1<<63 - 1, Lo: 3},
+					Int128{Hi: 1<<63 - 1, Lo: 3},
+					16,
+				},
+			},
+			true,
+			Int128{Hi: -5, Lo: 7},
+			int128Key{Hi: -5, Lo: 7},
+		},
+		&TestCase{
+			&uint128Column{name: "foo"},
+			"foo",
+			Uint128ColumnType,
+			16,
+			16,
+			Uint128{},
+			Int128{},
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					Uint128{Hi: 1<<64 - 1, Lo: 2},
+					Uint128{Hi: 1<<64 - 1, Lo: 2},
+					16,
+				},
+			},
+			true,
+			Uint128FromUint64(9),
+			uint128Key{Hi: 0, Lo: 9},
+		},
+		&TestCase{
+			&listColumn[int64]{name: "foo", elem: &intColumn[int64]{}, maxCount: 3},
+			"foo",
+			List,
+			2,
+			2 + 3*8,
+			[]int64{1, 2, 3},
+			[]int64{1, 2, 3, 4},
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					[]int64{},
+					[]int64{},
+					2,
+				},
+				&byteSizeTestCase{
+					[]int64{-1, 1 << 40},
+					[]int64{-1, 1 << 40},
+					2 + 2*8,
+				},
+			},
+			false,
+			nil,
+			nil,
+		},
+		&TestCase{
+			&listColumn[[]byte]{name: "foo", elem: &shortBytesColumn{}, maxCount: 2},
+			"foo",
+			List,
+			2,
+			2 + 2*256,
+			[][]byte{[]byte("a"), nil},
+			[][]byte{make([]byte, 256)},
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					[][]byte{[]byte("abc"), []byte{}},
+					[][]byte{[]byte("abc"), []byte{}},
+					2 + 4 + 1,
+				},
+			},
+			false,
+			nil,
+			nil,
+		},
+		&TestCase{
+			newStructColumn("foo", []Column{
+				&intColumn[int32]{name: "a"},
+				&shortStringColumn{name: "b"},
+			}),
+			"foo",
+			Struct,
+			4 + 1,
+			4 + 1 + 255,
+			map[string]any{"a": int32(1), "b": "x", "c": 3},
+			map[string]any{"a": int32(1)},
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					map[string]any{"a": int32(-7), "b": "abc"},
+					map[string]any{"a": int32(-7), "b": "abc"},
+					4 + 1 + 3,
+				},
+			},
+			false,
+			nil,
+			nil,
+		},
+		&TestCase{
+			&jsonColumn{name: "foo"},
+			"foo",
+			JSON,
+			0,
+			(1 << 30) - 1,
+			json.RawMessage(`{"a": [1, 2]}`),
+			json.RawMessage(`{"a": [1, 2]`),
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					json.RawMessage(` {"a" : [1, 2], "b": "x y"} `),
+					json.RawMessage(`{"a":[1,2],"b":"x y"}`),
+					4 + 21,
+				},
+			},
+			false,
+			nil,
+			nil,
+		},
+		&TestCase{
+			&enumColumn{name: "foo", values: []string{"a", "b", "c"}, ordinal: map[string]uint16{"a": 0, "b": 1, "c": 2}},
+			"foo",
+			Enum,
+			1,
+			1,
+			"b",
+			"d",
+			[]*byteSizeTestCase{
+				&byteSizeTestCase{
+					"c",
+					"c",
+					1,
+				},
+			},
+			true,
+			"b",
+			intKey[uint16](1),
+		},
 	}
 
 	for i, tc := range testCases {
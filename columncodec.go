@@ -0,0 +1,80 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+// 独自のカラム型の値とバイト列の変換を行うためのインターフェース。
+// RegisterColumnTypeで名前を付けて登録し、TableCreatorのCustomColumnやunkodbタグの`Custom[名前]`で使う。
+// 値はEncodeで得られたバイト列の長さ(4バイト)とバイト列がそのまま保存される。
+//
+//	type Point struct{ X, Y int32 }
+//
+//	type pointCodec struct{}
+//
+//	func (pointCodec) IsValidValue(value any) bool { _, ok := value.(Point); return ok }
+//	func (pointCodec) MaximumDataByteSize() uint64 { return 8 }
+//	func (pointCodec) Encode(value any) ([]byte, error) {
+//		p := value.(Point)
+//		return binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, uint32(p.X)), uint32(p.Y)), nil
+//	}
+//	func (pointCodec) Decode(data []byte) (any, error) {
+//		if len(data) == 0 {
+//			return Point{}, nil
+//		}
+//		return Point{int32(binary.BigEndian.Uint32(data)), int32(binary.BigEndian.Uint32(data[4:]))}, nil
+//	}
+//
+//	func init() {
+//		unkodb.RegisterColumnType("Point", pointCodec{})
+//	}
+type ColumnCodec interface {
+	// 値がこのカラム型で扱えるGoの型と値であるかを返す。
+	IsValidValue(value any) bool
+
+	// Encodeで得られるバイト列の最大の長さを返す。1以上1073741823以下である必要がある。
+	MaximumDataByteSize() uint64
+
+	// 値をバイト列にする。
+	Encode(value any) ([]byte, error)
+
+	// Encodeで得られたバイト列から値を復元する。
+	// 長さ0のバイト列に対してはゼロ値となる値を返す必要がある(EnsureTableByTaggedStructで追加したカラムの既存のデータの値に使われる)。
+	Decode(data []byte) (any, error)
+}
+
+// 登録された独自のカラム型
+var columnCodecs = make(map[string]ColumnCodec)
+
+// 独自のカラム型を名前を付けて登録する。
+// 名前はカラムの情報としてファイルに保存され、Openでファイルを開く前に同じ名前で登録しておく必要がある。
+// 並行して呼び出すことは考慮されていないので、initなどで登録すること。
+// 名前が空や長すぎる(255バイトより長い)場合やcodecがnilの場合やMaximumDataByteSizeが0や大きすぎる場合はErrInvalidColumnCodecのエラー、
+// 既に登録された名前の場合はErrColumnTypeAlreadyRegisteredのエラーが返る。
+func RegisterColumnType(name string, codec ColumnCodec) error {
+	if len(name) == 0 || len(name) > shortStringMaximumDataByteSize || codec == nil {
+		return ErrInvalidColumnCodec
+	}
+	// 最大の長さが0だと最小の長さと同じになり固定長のカラムとして長さ情報を読み飛ばさずに扱われてしまう
+	if maxSize := codec.MaximumDataByteSize(); maxSize == 0 || maxSize > customMaximumDataByteSize {
+		return ErrInvalidColumnCodec
+	}
+	if _, ok := columnCodecs[name]; ok {
+		return ErrColumnTypeAlreadyRegistered
+	}
+	columnCodecs[name] = codec
+	return nil
+}
+
+// 登録された名前の独自のカラム型のcustomColumnを生成する
+// 登録されていない名前の場合はErrUnregisteredColumnTypeのエラーを返す
+func newCustomColumn(name, typeName string) (*customColumn, error) {
+	codec, ok := columnCodecs[typeName]
+	if !ok {
+		return nil, &ErrUnregisteredColumnType{typeName}
+	}
+	return &customColumn{
+		name:     name,
+		typeName: typeName,
+		codec:    codec,
+	}, nil
+}
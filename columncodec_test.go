@@ -0,0 +1,277 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testPoint struct{ X, Y int32 }
+
+type testPointCodec struct{}
+
+func (testPointCodec) IsValidValue(value any) bool {
+	_, ok := value.(testPoint)
+	return ok
+}
+
+func (testPointCodec) MaximumDataByteSize() uint64 {
+	return 8
+}
+
+func (testPointCodec) Encode(value any) ([]byte, error) {
+	p := value.(testPoint)
+	buf := binary.BigEndian.AppendUint32(nil, uint32(p.X))
+	return binary.BigEndian.AppendUint32(buf, uint32(p.Y)), nil
+}
+
+func (testPointCodec) Decode(data []byte) (any, error) {
+	if len(data) == 0 {
+		return testPoint{}, nil
+	}
+	if len(data) != 8 {
+		return nil, fmt.Errorf("wrong point data")
+	}
+	return testPoint{int32(binary.BigEndian.Uint32(data)), int32(binary.BigEndian.Uint32(data[4:]))}, nil
+}
+
+type testTagsCodec struct{}
+
+func (testTagsCodec) IsValidValue(value any) bool {
+	_, ok := value.([]string)
+	return ok
+}
+
+func (testTagsCodec) MaximumDataByteSize() uint64 {
+	return 1000
+}
+
+func (testTagsCodec) Encode(value any) ([]byte, error) {
+	var buf []byte
+	for _, s := range value.([]string) {
+		buf = append(append(buf, s...), 0)
+	}
+	return buf, nil
+}
+
+func (testTagsCodec) Decode(data []byte) (any, error) {
+	tags := []string{}
+	for len(data) > 0 {
+		i := 0
+		for data[i] != 0 {
+			i++
+		}
+		tags = append(tags, string(data[:i]))
+		data = data[i+1:]
+	}
+	return tags, nil
+}
+
+// 最大の長さが0のColumnCodec
+type testEmptyCodec struct {
+	testPointCodec
+}
+
+func (testEmptyCodec) MaximumDataByteSize() uint64 {
+	return 0
+}
+
+// Encodeの呼び出し回数を数えるColumnCodec
+type testCountingPointCodec struct {
+	testPointCodec
+	count *int
+}
+
+func (c testCountingPointCodec) Encode(value any) ([]byte, error) {
+	*c.count++
+	return c.testPointCodec.Encode(value)
+}
+
+func TestTable_CustomColumn_encodeOnce(t *testing.T) {
+	count := 0
+	if err := RegisterColumnType("TestCountingPoint", testCountingPointCodec{count: &count}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(columnCodecs, "TestCountingPoint")
+
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("spots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.CustomColumn("pos", "TestCountingPoint")
+	tc.ShortStringColumn("name")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// サイズの計算と書き込みで1回だけEncodeが呼ばれる
+	if _, err = table.Insert(map[string]any{"pos": testPoint{1, 2}, "name": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("wrong count %d", count)
+	}
+	count = 0
+	if _, err = table.Replace(map[string]any{"id": CounterType(1), "pos": testPoint{3, 4}, "name": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("wrong count %d", count)
+	}
+
+	// 後ろのカラムだけを読み込んでもカラムの位置がずれない
+	r, err := table.FindColumns(CounterType(1), "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("name") != "b" {
+		t.Fatalf("wrong name %#v", r.Column("name"))
+	}
+}
+
+func TestRegisterColumnType(t *testing.T) {
+	defer delete(columnCodecs, "TestRegister")
+
+	if err := RegisterColumnType("", testPointCodec{}); err != ErrInvalidColumnCodec {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err := RegisterColumnType("TestRegister", nil); err != ErrInvalidColumnCodec {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err := RegisterColumnType("TestRegister", testEmptyCodec{}); err != ErrInvalidColumnCodec {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err := RegisterColumnType("TestRegister", testPointCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterColumnType("TestRegister", testTagsCodec{}); err != ErrColumnTypeAlreadyRegistered {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestTable_CustomColumn(t *testing.T) {
+	if err := RegisterColumnType("TestPoint", testPointCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(columnCodecs, "TestPoint")
+	if err := RegisterColumnType("TestTags", testTagsCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(columnCodecs, "TestTags")
+
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Place struct {
+		Id   CounterType `unkodb:"id,key@Counter"`
+		Pos  testPoint   `unkodb:"pos,Custom[TestPoint]"`
+		Tags []string    `unkodb:"tags,Custom[TestTags]"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("places", (*Place)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ColumnTypeHint(table.Column("pos")) != "Custom[TestPoint] (any)" {
+		t.Fatalf("wrong hint %s", ColumnTypeHint(table.Column("pos")))
+	}
+
+	if _, err = table.Insert(&Place{Pos: testPoint{3, -4}, Tags: []string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = table.Insert(map[string]any{"id": CounterType(0), "pos": testPoint{5, 6}, "tags": []string{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(table *Table) {
+		places, err := Typed[Place](table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		place, err := places.Find(CounterType(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if place.Pos != (testPoint{3, -4}) || fmt.Sprint(place.Tags) != "[a b]" {
+			t.Fatalf("wrong place %#v", place)
+		}
+		r, err := table.Find(CounterType(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Column("pos") != (testPoint{5, 6}) {
+			t.Fatalf("wrong pos %v", r.Column("pos"))
+		}
+		var p Place
+		if err = r.CopyTo(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Pos != (testPoint{5, 6}) || len(p.Tags) != 0 {
+			t.Fatalf("wrong place %#v", p)
+		}
+	}
+
+	check(table)
+
+	_, err = table.Insert(map[string]any{"id": CounterType(0), "pos": "3,4", "tags": []string{}})
+	if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("places")
+	check(table)
+
+	r, err := table.Update(CounterType(2), map[string]any{"tags": []string{"c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(r.Column("tags")) != "[c]" {
+		t.Fatalf("wrong tags %v", r.Column("tags"))
+	}
+
+	tc, err := db.CreateTable("wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tc.CustomColumn("c", "TestUnknown"); err == nil {
+		t.Fatal("no error")
+	} else if e, ok := err.(*ErrUnregisteredColumnType); !ok || e.TypeName != "TestUnknown" {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	delete(columnCodecs, "TestTags")
+	_, err = Open(tempfile)
+	if e, ok := err.(*ErrUnregisteredColumnType); !ok || e.TypeName != "TestTags" {
+		t.Fatalf("wrong error %v", err)
+	}
+}
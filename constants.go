@@ -31,6 +31,27 @@ const (
 	LongBytes
 	FixedSizeLongBytes
 	Blob
+	Bool
+	Timestamp
+	Date
+
+	// カラム型Decimal。Goの型のunkodb.Decimalと名前が衝突するためこの名前になっている
+	DecimalColumnType
+
+	// カラム型UUID。Goの型のunkodb.UUIDと名前が衝突するためこの名前になっている
+	UUIDColumnType
+
+	// カラム型Int128。Goの型のunkodb.Int128と名前が衝突するためこの名前になっている
+	Int128ColumnType
+
+	// カラム型Uint128。Goの型のunkodb.Uint128と名前が衝突するためこの名前になっている
+	Uint128ColumnType
+	List
+	Struct
+	JSON
+	Enum
+	Custom
+	Counter64
 )
 
 const (
@@ -41,7 +62,16 @@ const (
 	MaximumColumnNameByteSize = 255
 
 	// テーブルに設定できる最大のカラム数（このカラム数にキーは含めない）
-	MaximumColumnCountWithoutKey = 100
+	MaximumColumnCountWithoutKey = 1000
+
+	// カラム型のEnumに設定できる最大の値の数
+	MaximumEnumValueCount = (1 << 16) - 1 // 65535
+
+	// カラム型のDecimalに設定できる最大の桁数(precision)
+	MaximumDecimalPrecision = 18
+
+	// ファイルから読み込んだノードなどを操作をまたいで保持するキャッシュのメモリの上限(バイト数)の初期値
+	DefaultCacheByteSize = 1 << 20
 )
 
 const (
@@ -68,6 +98,28 @@ const (
 	blobMinimumDataByteSize = 0
 	blobMaximumDataByteSize = (1 << 30) - 1 // 2147483647
 	blobByteSizeDataLength  = 4             // == unsafe.Sizeof(uint32(0))
+
+	listByteSizeDataLength = 2 // == unsafe.Sizeof(uint16(0)) (要素数)
+
+	jsonMinimumDataByteSize = 0
+	jsonMaximumDataByteSize = (1 << 30) - 1
+	jsonByteSizeDataLength  = 4 // == unsafe.Sizeof(uint32(0))
+
+	customMinimumDataByteSize = 0
+	customMaximumDataByteSize = (1 << 30) - 1
+	customByteSizeDataLength  = 4 // == unsafe.Sizeof(uint32(0))
+
+	shortEnumByteSize = 1 // == unsafe.Sizeof(uint8(0)) (値の数が256以下の場合の序数)
+	longEnumByteSize  = 2 // == unsafe.Sizeof(uint16(0))
+
+	boolByteSize      = 1     // == unsafe.Sizeof(uint8(0))
+	timestampByteSize = 8 + 4 // == unsafe.Sizeof(int64(0)) + unsafe.Sizeof(uint32(0)) (秒とナノ秒)
+	dateByteSize      = 4     // == unsafe.Sizeof(int32(0)) (1970-01-01からの日数)
+	decimalByteSize   = 8     // == unsafe.Sizeof(int64(0)) (scaleに合わせた整数値)
+	uuidByteSize      = 16
+	int128ByteSize    = 8 + 8 // == unsafe.Sizeof(uint64(0)) * 2 (上位64ビットと下位64ビット)
+
+	secondsPerDay = 24 * 60 * 60
 )
 
 const (
@@ -146,6 +198,10 @@ const (
 	tableTreeNodeHeightLength   = 1 // == unsafe.Sizeof(uint8(0))
 
 	tableTreeNodeHeaderByteSize = tableTreeNodeHeightPosition + tableTreeNodeHeightLength
+
+	// バージョン番号を保持するテーブルの場合のみノードのヘッダの後ろに置かれる
+	tableTreeNodeRowVersionPosition = tableTreeNodeHeaderByteSize
+	tableTreeNodeRowVersionLength   = 8 // == unsafe.Sizeof(uint64(0))
 )
 
 const (
@@ -163,3 +219,17 @@ const (
 
 	tableSpecHeaderByteSize = tableSpecDataSeparationPosition + tableSpecDataSeparationLength
 )
+
+// テーブル仕様のカラム数がこの値以上の場合はこの値(uint8)の後ろにカラム数(uint16)が続く
+// (カラム数が100個までだった頃のファイルではカラム数はuint8だけで書かれている)
+const tableSpecExtendedColumnCount = 255
+
+// テーブル仕様のカラム情報の後ろに置かれるテーブルオプションの識別子
+const (
+	tableSpecOptionRowVersion    = 1
+	tableSpecOptionKeyCollation  = 2 // 後ろにCollation(uint8)が続く
+	tableSpecOptionDescendingKey = 3
+	tableSpecOptionCounterStart  = 4 // 後ろに開始値(uint64)が続く
+	tableSpecOptionCounterStep   = 5 // 後ろに増分(uint64)が続く
+	tableSpecOptionCounterHigh   = 6 // 後ろにカウンターの上位32ビット(uint32)が続く、Counter64のテーブルで常に最後に置く
+)
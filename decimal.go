@@ -0,0 +1,178 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"math"
+	"math/big"
+	"strings"
+)
+
+// 10^0から10^MaximumDecimalPrecisionまでの値
+var decimalPow10 = func() (pow [MaximumDecimalPrecision + 1]int64) {
+	pow[0] = 1
+	for i := 1; i < len(pow); i++ {
+		pow[i] = pow[i-1] * 10
+	}
+	return
+}()
+
+// カラム型のDecimalで用いるGoの型。
+// unscaled×10^(-scale)の固定小数点数を表す。
+// 例えば12.30はunscaledが1230でscaleが2となる。
+// ゼロ値は0を表す。
+//
+//	price := unkodb.NewDecimal(1230, 2) // 12.30
+//	tax, _ := unkodb.ParseDecimal("0.98")
+type Decimal struct {
+	unscaled int64
+	scale    uint8
+}
+
+// unscaled×10^(-scale)を表すDecimalを返す。
+func NewDecimal(unscaled int64, scale uint8) Decimal {
+	return Decimal{unscaled: unscaled, scale: scale}
+}
+
+// "-12.30"のような10進数の文字列からDecimalを作る。
+// scaleは小数点以下の桁数となる。
+// 不正な文字列やint64に収まらない桁数の場合はErrInvalidDecimalのエラーが返る。
+func ParseDecimal(s string) (d Decimal, err error) {
+	neg := false
+	if strings.HasPrefix(s, "-") {
+		neg = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if len(intPart) == 0 && len(fracPart) == 0 {
+		err = ErrInvalidDecimal
+		return
+	}
+	if len(fracPart) > math.MaxUint8 {
+		err = ErrInvalidDecimal
+		return
+	}
+	// 負の値はmath.MinInt64まで表せるので絶対値の上限はmath.MaxInt64+1になる
+	var limit uint64 = math.MaxInt64
+	if neg {
+		limit++
+	}
+	var v uint64
+	for _, c := range intPart + fracPart {
+		if c < '0' || '9' < c {
+			err = ErrInvalidDecimal
+			return
+		}
+		if v > (limit-uint64(c-'0'))/10 {
+			err = ErrInvalidDecimal
+			return
+		}
+		v = v*10 + uint64(c-'0')
+	}
+	if neg {
+		d.unscaled = int64(-v)
+	} else {
+		d.unscaled = int64(v)
+	}
+	d.scale = uint8(len(fracPart))
+	return
+}
+
+// scale(小数点以下の桁数)を除いた整数値を返す。
+func (d Decimal) Unscaled() int64 {
+	return d.unscaled
+}
+
+// scale(小数点以下の桁数)を返す。
+func (d Decimal) Scale() uint8 {
+	return d.scale
+}
+
+// 値をbig.Ratにして返す。
+func (d Decimal) Rat() *big.Rat {
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil)
+	return new(big.Rat).SetFrac(big.NewInt(d.unscaled), denom)
+}
+
+// dとotherの値を比較する。
+// d < otherなら-1、d == otherなら0、d > otherなら1を返す。
+// scaleが異なっていても値として比較される(1.0と1.00は等しい)。
+func (d Decimal) Cmp(other Decimal) int {
+	if d.scale == other.scale {
+		switch {
+		case d.unscaled < other.unscaled:
+			return -1
+		case d.unscaled > other.unscaled:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return d.Rat().Cmp(other.Rat())
+}
+
+// "-12.30"のような10進数の文字列にして返す。
+func (d Decimal) String() string {
+	var u uint64
+	if d.unscaled < 0 {
+		u = uint64(-(d.unscaled + 1)) + 1
+	} else {
+		u = uint64(d.unscaled)
+	}
+	digits := []byte(big.NewInt(0).SetUint64(u).String())
+	if scale := int(d.scale); scale > 0 {
+		if len(digits) <= scale {
+			digits = append([]byte(strings.Repeat("0", scale-len(digits)+1)), digits...)
+		}
+		i := len(digits) - scale
+		digits = append(digits[:i], append([]byte{'.'}, digits[i:]...)...)
+	}
+	if d.unscaled < 0 {
+		return "-" + string(digits)
+	}
+	return string(digits)
+}
+
+// scaleを変更した場合のunscaledの値を返す
+// 桁あふれする場合や小数点以下の0ではない桁が切り捨てられる場合はokはfalseとなる
+func (d Decimal) rescale(scale uint8) (unscaled int64, ok bool) {
+	unscaled = d.unscaled
+	for s := d.scale; s < scale; s++ {
+		if unscaled > math.MaxInt64/10 || unscaled < math.MinInt64/10 {
+			return 0, false
+		}
+		unscaled *= 10
+	}
+	for s := d.scale; s > scale; s-- {
+		if unscaled%10 != 0 {
+			return 0, false
+		}
+		unscaled /= 10
+	}
+	return unscaled, true
+}
+
+// big.Ratを表現できる最小のscaleのDecimalにする
+// MaximumDecimalPrecision桁以内の小数で表現できない場合はokはfalseとなる
+func decimalFromRat(r *big.Rat) (d Decimal, ok bool) {
+	num := new(big.Int).Set(r.Num())
+	denom := r.Denom()
+	ten := big.NewInt(10)
+	for scale := 0; scale <= MaximumDecimalPrecision; scale++ {
+		if new(big.Int).Rem(num, denom).Sign() == 0 {
+			q := new(big.Int).Quo(num, denom)
+			if !q.IsInt64() {
+				return
+			}
+			return Decimal{unscaled: q.Int64(), scale: uint8(scale)}, true
+		}
+		num.Mul(num, ten)
+	}
+	return
+}
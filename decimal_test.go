@@ -0,0 +1,92 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	testCases := []struct {
+		s        string
+		unscaled int64
+		scale    uint8
+		str      string
+	}{
+		{"0", 0, 0, "0"},
+		{"12.30", 1230, 2, "12.30"},
+		{"-0.05", -5, 2, "-0.05"},
+		{"+7", 7, 0, "7"},
+		{".5", 5, 1, "0.5"},
+		{"3.", 3, 0, "3"},
+		{"-9223372036854775807", -9223372036854775807, 0, "-9223372036854775807"},
+		{"-9223372036854775808", math.MinInt64, 0, "-9223372036854775808"},
+		{"-922337203685477580.8", math.MinInt64, 1, "-922337203685477580.8"},
+	}
+	for _, tc := range testCases {
+		d, err := ParseDecimal(tc.s)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.s, err)
+		}
+		if d.Unscaled() != tc.unscaled || d.Scale() != tc.scale {
+			t.Fatalf("%q: wrong decimal %#v", tc.s, d)
+		}
+		if d.String() != tc.str {
+			t.Fatalf("%q: wrong string %q", tc.s, d.String())
+		}
+	}
+
+	for _, s := range []string{"", "-", ".", "1.2.3", "1e3", "abc", "9223372036854775808", "-9223372036854775809", "-92233720368547758.080"} {
+		_, err := ParseDecimal(s)
+		if err != ErrInvalidDecimal {
+			t.Fatalf("%q: wrong error %v", s, err)
+		}
+	}
+
+	if NewDecimal(-9223372036854775808, 1).String() != "-922337203685477580.8" {
+		t.Fatalf("wrong string %s", NewDecimal(-9223372036854775808, 1))
+	}
+	for _, d := range []Decimal{NewDecimal(math.MinInt64, 0), NewDecimal(math.MaxInt64, 3)} {
+		if p, err := ParseDecimal(d.String()); err != nil || p != d {
+			t.Fatalf("%#v: wrong round trip %#v %v", d, p, err)
+		}
+	}
+
+	cmps := []struct {
+		a, b Decimal
+		cmp  int
+	}{
+		{NewDecimal(10, 1), NewDecimal(100, 2), 0},
+		{NewDecimal(-1, 0), NewDecimal(1, 3), -1},
+		{NewDecimal(123, 2), NewDecimal(1229, 3), 1},
+		{NewDecimal(5, 0), NewDecimal(4, 0), 1},
+	}
+	for _, c := range cmps {
+		if c.a.Cmp(c.b) != c.cmp || c.b.Cmp(c.a) != -c.cmp {
+			t.Fatalf("wrong cmp %s %s", c.a, c.b)
+		}
+	}
+
+	if NewDecimal(-125, 2).Rat().Cmp(big.NewRat(-5, 4)) != 0 {
+		t.Fatal("wrong rat")
+	}
+	if d, ok := decimalFromRat(big.NewRat(-5, 4)); !ok || d != NewDecimal(-125, 2) {
+		t.Fatalf("wrong decimal %#v", d)
+	}
+	if _, ok := decimalFromRat(big.NewRat(1, 3)); ok {
+		t.Fatal("1/3 is converted")
+	}
+
+	if u, ok := NewDecimal(123, 1).rescale(3); !ok || u != 12300 {
+		t.Fatalf("wrong rescale %d", u)
+	}
+	if _, ok := NewDecimal(123, 2).rescale(1); ok {
+		t.Fatal("truncated")
+	}
+	if _, ok := NewDecimal(922337203685477581, 0).rescale(1); ok {
+		t.Fatal("overflow")
+	}
+}
@@ -3,7 +3,10 @@
 
 package unkodb
 
-import "errors"
+import (
+	"errors"
+	"strconv"
+)
 
 var (
 	errNotStruct = errors.New("errNotStruct")
@@ -20,7 +23,24 @@ func (err *ErrNotFoundColumnName) Error() string {
 type ErrUnmatchColumnValueType struct{ Column }
 
 func (err *ErrUnmatchColumnValueType) Error() string {
-	return "ErrUnmatchColumnValueType: " + err.Name() + " " + ColumnTypeHint(err)
+	return "ErrUnmatchColumnValueType: " + err.Name() + " " + ColumnTypeHint(err.Column)
+}
+
+// InsertやReplaceなどでEnumのカラムに定義されていない値を書き込もうとしたときのエラー
+type ErrUnknownEnumValue struct {
+	Column
+	Value string
+}
+
+func (err *ErrUnknownEnumValue) Error() string {
+	return "ErrUnknownEnumValue: " + err.Name() + " " + strconv.Quote(err.Value)
+}
+
+// Openしたファイルやテーブル作成時にRegisterColumnTypeで登録されていない独自のカラム型が使われていたときのエラー
+type ErrUnregisteredColumnType struct{ TypeName string }
+
+func (err *ErrUnregisteredColumnType) Error() string {
+	return "ErrUnregisteredColumnType: " + strconv.Quote(err.TypeName)
 }
 
 // unkodbタグにおけるタグの記述に関するエラー
@@ -47,12 +67,22 @@ var (
 	// キーのカラム型がCounterのときに対応しないGoの型でデータが渡されたときのエラー
 	ErrKeyIsNotCounter = errors.New("ErrKeyIsNotCounter")
 
+	// CounterやCounter64のキーで付与できる値が残っていない(次の値がキーのカラム型の最大値を超える)ときのエラー
+	// カウンターの値は一周して小さい値に戻ることはない
+	ErrCounterOverflow = errors.New("ErrCounterOverflow")
+
+	// カウンターの開始値や増分やSetCounterで指定する値に不正(0やキーのカラム型の最大値を超える値)があるときのエラー
+	ErrInvalidCounterValue = errors.New("ErrInvalidCounterValue")
+
 	// FindやDeleteなどで存在しないキーが指定されたときのエラー
 	ErrNotFoundKey = errors.New("ErrNotFoundKey")
 
 	// InsertやReplaceなどで引数に受け付けない型を受け取ったときのエラー
 	ErrNotFoundData = errors.New("ErrNotFoundData")
 
+	// EnsureTableByTaggedStructでテーブルの構造をunkodbタグ付きの構造体に合わせることができないときのエラー
+	ErrIncompatibleStruct = errors.New("ErrIncompatibleStruct")
+
 	// 存在しないテーブル名を指定されたときのエラー
 	ErrNotFoundTable = errors.New("ErrNotFoundTable")
 
@@ -86,6 +116,51 @@ var (
 	// テーブル作成時に固定長タイプのカラム型でサイズに0が指定されたときのエラー
 	ErrSizeMustBePositiveValue = errors.New("ErrSizeMustBePositiveValue")
 
+	// テーブル作成時にDecimalのカラム型でprecisionやscaleに不正な値が指定されたときのエラー
+	ErrWrongDecimalPrecision = errors.New("ErrWrongDecimalPrecision")
+
+	// ParseDecimalで10進数として解釈できない文字列が渡されたときのエラー
+	ErrInvalidDecimal = errors.New("ErrInvalidDecimal")
+
+	// ParseUUIDなどでUUIDとして解釈できない文字列が渡されたときのエラー
+	ErrInvalidUUID = errors.New("ErrInvalidUUID")
+
+	// テーブル作成時にEnumのカラム型の値のリストに不正がある(空や重複がある、値の数が多すぎる、値が長すぎる)ときのエラー
+	ErrInvalidEnumValues = errors.New("ErrInvalidEnumValues")
+
+	// RegisterColumnTypeで不正な名前やColumnCodecが渡されたときのエラー
+	ErrInvalidColumnCodec = errors.New("ErrInvalidColumnCodec")
+
+	// RegisterColumnTypeで既に登録された名前を登録しようとしたときのエラー
+	ErrColumnTypeAlreadyRegistered = errors.New("ErrColumnTypeAlreadyRegistered")
+
+	// ProjectJSONやIterateWhereJSONで不正なJSONパスが指定されたときのエラー
+	ErrInvalidJSONPath = errors.New("ErrInvalidJSONPath")
+
+	// テーブル作成時に文字列ではないキーに照合順序を指定したときや不正な照合順序を指定したときのエラー
+	ErrInvalidCollation = errors.New("ErrInvalidCollation")
+
+	// テーブル作成時にListのカラム型の要素に使えないカラム型が指定されたときのエラー
+	ErrInvalidListElementType = errors.New("ErrInvalidListElementType")
+
 	// テーブル作成時にテーブルに設定できる最大カラム数を超えてカラムを作ろうとしたときのエラー
 	ErrColumnCountIsFull = errors.New("ErrColumnCountIsFull")
+
+	// テーブル作成時やカラム追加時にキーとカラムの情報(カラム名やカラム型など)の合計が65535バイトを超えて保存できないときのエラー
+	ErrTableSpecIsTooLarge = errors.New("ErrTableSpecIsTooLarge")
+
+	// Updateなどでテーブルに存在しないカラム名が指定されたときのエラー
+	ErrUnknownColumnName = errors.New("ErrUnknownColumnName")
+
+	// ReplaceIfやDeleteIfやCompareAndSwapなどで条件を満たさず変更が行われなかったときのエラー
+	ErrConditionNotSatisfied = errors.New("ErrConditionNotSatisfied")
+
+	// BulkLoadなどでデータのキーがキーの順序(降順キーの場合は降順)に並んでいないときのエラー
+	ErrNotSortedKey = errors.New("ErrNotSortedKey")
+
+	// ReplaceWithVersionやDeleteWithVersionなどでデータのバージョン番号が一致しないときのエラー
+	ErrVersionConflict = errors.New("ErrVersionConflict")
+
+	// FindColumnsなどで一部のカラムだけを読み込んだRecordに対してCopyToやMoveToを呼び出したときのエラー
+	ErrProjectedRecord = errors.New("ErrProjectedRecord")
 )
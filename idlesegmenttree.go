@@ -196,6 +196,13 @@ func (tree *idleSegmentTree) loadNode(address int) *idleSegmentTreeNode {
 	return node
 }
 
+// 木から除去されたノードのリソース管理
+// github.com/neetsdkasu/avltree.NodeReleaser.ReleaseNode() の実装
+func (tree *idleSegmentTree) ReleaseNode(node avltree.RealNode) {
+	// 除去した領域は別の用途で使われるので古いノードが書き込まれないようにキャッシュから外す
+	delete(tree.cache, unwrapIdleSegmentTreeNode(node).position())
+}
+
 // github.com/neetsdkasu/avltree.RealTree.Root() の実装
 func (tree *idleSegmentTree) Root() avltree.Node {
 	node := tree.loadNode(tree.rootAddress)
@@ -0,0 +1,120 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"math"
+	"math/big"
+)
+
+// カラム型のInt128で用いるGoの型。
+// 符号付き128ビット整数を上位64ビット(Hi)と下位64ビット(Lo)に分けて保持する(2の補数表現)。
+// 例えば-1はHiが-1でLoがmath.MaxUint64となる。
+type Int128 struct {
+	Hi int64
+	Lo uint64
+}
+
+// カラム型のUint128で用いるGoの型。
+// 符号なし128ビット整数を上位64ビット(Hi)と下位64ビット(Lo)に分けて保持する。
+type Uint128 struct {
+	Hi uint64
+	Lo uint64
+}
+
+var bigMaxUint64 = new(big.Int).SetUint64(math.MaxUint64)
+
+// int64の値からInt128を作る。
+func Int128FromInt64(v int64) Int128 {
+	if v < 0 {
+		return Int128{Hi: -1, Lo: uint64(v)}
+	}
+	return Int128{Hi: 0, Lo: uint64(v)}
+}
+
+// big.Intの値からInt128を作る。
+// 128ビットに収まらない場合はokはfalseとなる。
+func Int128FromBig(v *big.Int) (x Int128, ok bool) {
+	hi := new(big.Int).Rsh(v, 64)
+	if !hi.IsInt64() {
+		return
+	}
+	x.Hi = hi.Int64()
+	x.Lo = new(big.Int).And(v, bigMaxUint64).Uint64()
+	return x, true
+}
+
+// 値をbig.Intにして返す。
+func (x Int128) Big() *big.Int {
+	v := big.NewInt(x.Hi)
+	v.Lsh(v, 64)
+	return v.Or(v, new(big.Int).SetUint64(x.Lo))
+}
+
+// xとyの値を比較する。
+// x < yなら-1、x == yなら0、x > yなら1を返す。
+func (x Int128) Cmp(y Int128) int {
+	switch {
+	case x.Hi < y.Hi:
+		return -1
+	case x.Hi > y.Hi:
+		return 1
+	case x.Lo < y.Lo:
+		return -1
+	case x.Lo > y.Lo:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// 10進数の文字列にして返す。
+func (x Int128) String() string {
+	return x.Big().String()
+}
+
+// uint64の値からUint128を作る。
+func Uint128FromUint64(v uint64) Uint128 {
+	return Uint128{Hi: 0, Lo: v}
+}
+
+// big.Intの値からUint128を作る。
+// 負の値や128ビットに収まらない場合はokはfalseとなる。
+func Uint128FromBig(v *big.Int) (x Uint128, ok bool) {
+	if v.Sign() < 0 || v.BitLen() > 128 {
+		return
+	}
+	x.Hi = new(big.Int).Rsh(v, 64).Uint64()
+	x.Lo = new(big.Int).And(v, bigMaxUint64).Uint64()
+	return x, true
+}
+
+// 値をbig.Intにして返す。
+func (x Uint128) Big() *big.Int {
+	v := new(big.Int).SetUint64(x.Hi)
+	v.Lsh(v, 64)
+	return v.Or(v, new(big.Int).SetUint64(x.Lo))
+}
+
+// xとyの値を比較する。
+// x < yなら-1、x == yなら0、x > yなら1を返す。
+func (x Uint128) Cmp(y Uint128) int {
+	switch {
+	case x.Hi < y.Hi:
+		return -1
+	case x.Hi > y.Hi:
+		return 1
+	case x.Lo < y.Lo:
+		return -1
+	case x.Lo > y.Lo:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// 10進数の文字列にして返す。
+func (x Uint128) String() string {
+	return x.Big().String()
+}
@@ -0,0 +1,47 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestInt128(t *testing.T) {
+	maxInt128 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	minInt128 := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))
+	for _, v := range []*big.Int{big.NewInt(0), big.NewInt(-1), big.NewInt(12345), maxInt128, minInt128} {
+		x, ok := Int128FromBig(v)
+		if !ok {
+			t.Fatalf("cannot convert %s", v)
+		}
+		if x.Big().Cmp(v) != 0 || x.String() != v.String() {
+			t.Fatalf("wrong value %s %s", x, v)
+		}
+	}
+	if _, ok := Int128FromBig(new(big.Int).Add(maxInt128, big.NewInt(1))); ok {
+		t.Fatal("overflow")
+	}
+	if _, ok := Int128FromBig(new(big.Int).Sub(minInt128, big.NewInt(1))); ok {
+		t.Fatal("overflow")
+	}
+	if Int128FromInt64(-2).Cmp(Int128FromInt64(1)) != -1 || Int128FromInt64(-2).String() != "-2" {
+		t.Fatal("wrong Int128FromInt64")
+	}
+
+	maxUint128 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	x, ok := Uint128FromBig(maxUint128)
+	if !ok || x != (Uint128{Hi: 1<<64 - 1, Lo: 1<<64 - 1}) || x.String() != maxUint128.String() {
+		t.Fatalf("wrong value %s", x)
+	}
+	if _, ok := Uint128FromBig(big.NewInt(-1)); ok {
+		t.Fatal("negative")
+	}
+	if _, ok := Uint128FromBig(new(big.Int).Lsh(big.NewInt(1), 128)); ok {
+		t.Fatal("overflow")
+	}
+	if Uint128FromUint64(3).Cmp(Uint128{Hi: 1}) != -1 {
+		t.Fatal("wrong cmp")
+	}
+}
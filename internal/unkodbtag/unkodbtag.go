@@ -0,0 +1,125 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+// unkodbtagはunkodbタグ `unkodb:"カラム名,カラム型"` の書式を解析する。
+// unkodbパッケージとunkodb-genで同じ解析を使うための内部パッケージ。
+// カラム型名が存在するか、キーに使えるか、サイズが上限を超えていないかなどのカラム型ごとの確認は呼び出し側で行う。
+package unkodbtag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// カラム型の指定の解析結果
+type ColumnType struct {
+	IsKey     bool     // key@が付いているかどうか
+	Name      string   // カラム型名 (FixedSizeShortString[10]ならFixedSizeShortString、List<Int64>[5]ならList)
+	Size      uint64   // FixedSizeShortStringなどの固定長タイプのサイズ、Listの最大要素数
+	Elem      string   // Listの要素のカラム型名
+	Values    []string // Enumの値のリスト
+	TypeName  string   // Customの名前
+	Precision uint64   // Decimalのprecision
+	Scale     uint64   // Decimalのscale
+}
+
+// []で値を指定するカラム型名と[]が無い場合のエラーの説明
+var paramSyntaxes = map[string]string{
+	"FixedSizeShortString": "size",
+	"FixedSizeLongString":  "size",
+	"FixedSizeShortBytes":  "size",
+	"FixedSizeLongBytes":   "size",
+	"List":                 "max count",
+	"Enum":                 "enum values",
+	"Custom":               "custom type name",
+	"Decimal":              "precision and scale",
+}
+
+// タグの値のカラム名とカラム型を区切るカンマの位置を返す
+// Decimal[10,2]のように[]の中にあるカンマは区切りとしない
+// カラム型の指定がない場合は負の値を返す
+func SeparatorIndex(tv string) int {
+	if i := strings.LastIndex(tv, "["); i >= 0 && strings.HasSuffix(tv, "]") {
+		return strings.LastIndex(tv[:i], ",")
+	}
+	return strings.LastIndex(tv, ",")
+}
+
+// タグの値のカンマより後ろのカラム型の指定を解析する
+func ParseColumnType(s string) (ct ColumnType, err error) {
+	if strings.HasPrefix(s, "key@") {
+		ct.IsKey = true
+		s = strings.TrimPrefix(s, "key@")
+	}
+	var params string
+	hasParams := false
+	if i := strings.IndexAny(s, "<["); i < 0 {
+		ct.Name = s
+	} else if s[i] == '<' {
+		ct.Name = s[:i]
+		j := strings.Index(s, ">")
+		if j < i {
+			err = fmt.Errorf("not found element type syntax")
+			return
+		}
+		ct.Elem = s[i+1 : j]
+		s = s[j+1:]
+		if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+			err = fmt.Errorf("not found max count syntax")
+			return
+		}
+		params, hasParams = s[1:len(s)-1], true
+	} else {
+		ct.Name = s[:i]
+		if !strings.HasSuffix(s, "]") {
+			err = fmt.Errorf("not found %s syntax", paramSyntaxes[ct.Name])
+			return
+		}
+		params, hasParams = s[i+1:len(s)-1], true
+	}
+	syntax, needParams := paramSyntaxes[ct.Name]
+	if !needParams {
+		if hasParams || len(ct.Elem) > 0 {
+			err = fmt.Errorf("not found type name")
+		}
+		return
+	}
+	if (ct.Name == "List") != (len(ct.Elem) > 0) {
+		err = fmt.Errorf("not found element type syntax")
+		return
+	}
+	if !hasParams || len(params) == 0 {
+		err = fmt.Errorf("not found %s syntax", syntax)
+		return
+	}
+	switch ct.Name {
+	case "List":
+		ct.Size, err = strconv.ParseUint(params, 10, 16)
+		if err != nil || ct.Size == 0 {
+			err = fmt.Errorf("wrong max count")
+		}
+	case "Enum":
+		ct.Values = strings.Split(params, ",")
+	case "Custom":
+		ct.TypeName = params
+	case "Decimal":
+		ps := strings.Split(params, ",")
+		if len(ps) != 2 {
+			err = fmt.Errorf("wrong precision and scale")
+			return
+		}
+		var e1, e2 error
+		ct.Precision, e1 = strconv.ParseUint(strings.TrimSpace(ps[0]), 10, 8)
+		ct.Scale, e2 = strconv.ParseUint(strings.TrimSpace(ps[1]), 10, 8)
+		if e1 != nil || e2 != nil {
+			err = fmt.Errorf("wrong precision and scale")
+		}
+	default:
+		ct.Size, err = strconv.ParseUint(params, 10, 16)
+		if err != nil || ct.Size == 0 {
+			err = fmt.Errorf("wrong size")
+		}
+	}
+	return
+}
@@ -0,0 +1,67 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodbtag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeparatorIndex(t *testing.T) {
+	for tv, want := range map[string]int{
+		"id":                  -1,
+		"id,Int64":            2,
+		"cost,Decimal[10,2]":  4,
+		",Enum[a,b,c]":        0,
+		"a,b,Int8":            3,
+		"tags,List<Int8>[10]": 4,
+	} {
+		if got := SeparatorIndex(tv); got != want {
+			t.Fatalf("SeparatorIndex(%q) = %d (want %d)", tv, got, want)
+		}
+	}
+}
+
+func TestParseColumnType(t *testing.T) {
+	goods := map[string]ColumnType{
+		"Int64":                     {Name: "Int64"},
+		"key@Counter":               {IsKey: true, Name: "Counter"},
+		"FixedSizeShortString[10]":  {Name: "FixedSizeShortString", Size: 10},
+		"List<ShortString>[5]":      {Name: "List", Elem: "ShortString", Size: 5},
+		"key@Enum[food,drink]":      {IsKey: true, Name: "Enum", Values: []string{"food", "drink"}},
+		"Custom[Point]":             {Name: "Custom", TypeName: "Point"},
+		"Decimal[10, 2]":            {Name: "Decimal", Precision: 10, Scale: 2},
+		"Unknown":                   {Name: "Unknown"},
+		"key@FixedSizeLongBytes[1]": {IsKey: true, Name: "FixedSizeLongBytes", Size: 1},
+	}
+	for s, want := range goods {
+		got, err := ParseColumnType(s)
+		if err != nil {
+			t.Fatalf("ParseColumnType(%q): %v", s, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("ParseColumnType(%q) = %#v (want %#v)", s, got, want)
+		}
+	}
+	wrongs := []string{
+		"Int64[3]",
+		"List<Int64>",
+		"List<Int64>[0]",
+		"List[3]",
+		"FixedSizeShortString",
+		"FixedSizeShortString[0]",
+		"FixedSizeShortString[x]",
+		"FixedSizeShortString[10",
+		"Enum[]",
+		"Custom[]",
+		"Decimal",
+		"Decimal[10]",
+		"Decimal[10,x]",
+	}
+	for _, s := range wrongs {
+		if _, err := ParseColumnType(s); err == nil {
+			t.Fatalf("ParseColumnType(%q): no error", s)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// JSONパスの1つ分の要素
+// isIndexがtrueなら配列の添え字、falseならオブジェクトのキーを表す
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// "$.a.b[0]"や"$['a b'].c"のようなJSONパスを解析する
+// 先頭の"$"は省略でき、その場合は"a.b[0]"のようにキーから始めることもできる
+func parseJSONPath(path string) (steps []jsonPathStep, err error) {
+	s := strings.TrimPrefix(path, "$")
+	if len(s) > 0 && s[0] != '.' && s[0] != '[' {
+		if len(s) == len(path) {
+			s = "." + s
+		} else {
+			return nil, ErrInvalidJSONPath
+		}
+	}
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			i := strings.IndexAny(s, ".[]")
+			if i < 0 {
+				i = len(s)
+			}
+			if i == 0 {
+				return nil, ErrInvalidJSONPath
+			}
+			steps = append(steps, jsonPathStep{key: s[:i]})
+			s = s[i:]
+		case '[':
+			if len(s) > 1 && (s[1] == '"' || s[1] == '\'') {
+				i := strings.Index(s[2:], string(s[1])+"]")
+				if i < 0 {
+					return nil, ErrInvalidJSONPath
+				}
+				key := s[2 : 2+i]
+				if s[1] == '"' {
+					key, err = strconv.Unquote(s[1 : 3+i])
+					if err != nil {
+						return nil, ErrInvalidJSONPath
+					}
+				}
+				steps = append(steps, jsonPathStep{key: key})
+				s = s[4+i:]
+			} else {
+				i := strings.IndexByte(s, ']')
+				if i < 0 {
+					return nil, ErrInvalidJSONPath
+				}
+				index, e := strconv.Atoi(s[1:i])
+				if e != nil || index < 0 {
+					return nil, ErrInvalidJSONPath
+				}
+				steps = append(steps, jsonPathStep{index: index, isIndex: true})
+				s = s[i+1:]
+			}
+		default:
+			return nil, ErrInvalidJSONPath
+		}
+	}
+	return steps, nil
+}
+
+// JSONのドキュメントdocからstepsの位置にある値を取り出す
+// 途中のキーや添え字が存在しない場合はfoundはfalseとなる
+func projectJSON(doc json.RawMessage, steps []jsonPathStep) (value json.RawMessage, found bool, err error) {
+	value = doc
+	for _, step := range steps {
+		v := bytes.TrimLeft(value, " \t\r\n")
+		if step.isIndex {
+			if len(v) == 0 || v[0] != '[' {
+				return nil, false, nil
+			}
+			var list []json.RawMessage
+			if err = json.Unmarshal(v, &list); err != nil {
+				return nil, false, err
+			}
+			if step.index >= len(list) {
+				return nil, false, nil
+			}
+			value = list[step.index]
+		} else {
+			if len(v) == 0 || v[0] != '{' {
+				return nil, false, nil
+			}
+			var obj map[string]json.RawMessage
+			if err = json.Unmarshal(v, &obj); err != nil {
+				return nil, false, err
+			}
+			if value, found = obj[step.key]; !found {
+				return nil, false, nil
+			}
+		}
+	}
+	return value, true, nil
+}
+
+// JSONのドキュメントdocからJSONパスpathの位置にある値をjson.RawMessageとして取り出す。
+// JSONパスは"$.a.b[0]"のように"$"(ドキュメント全体)に続けて".キー"や"[添え字]"や`["キー"]`を並べて指定する("$"は省略できる)。
+// 途中のキーや添え字が存在しない場合はfoundはfalseとなる。
+// JSONパスが不正な場合はErrInvalidJSONPathのエラー、docがJSONとして不正な場合はencoding/jsonのエラーが返る。
+//
+//	r, _ := table.Find(unkodb.CounterType(1))
+//	host, found, _ := unkodb.ProjectJSON(r.Column("config").(json.RawMessage), "$.server.host")
+func ProjectJSON(doc json.RawMessage, path string) (value json.RawMessage, found bool, err error) {
+	var steps []jsonPathStep
+	steps, err = parseJSONPath(path)
+	if err != nil {
+		return
+	}
+	value, found, err = projectJSON(doc, steps)
+	return
+}
@@ -0,0 +1,48 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestProjectJSON(t *testing.T) {
+	doc := json.RawMessage(`{"server": {"host": "localhost", "ports": [80, 443]}, "a b": {"c": true}, "x": null}`)
+	testCases := []struct {
+		path  string
+		value string
+		found bool
+	}{
+		{"$", string(doc), true},
+		{"", string(doc), true},
+		{"$.server.host", `"localhost"`, true},
+		{"server.host", `"localhost"`, true},
+		{"$.server.ports[1]", `443`, true},
+		{"$.server.ports[2]", ``, false},
+		{`$["a b"].c`, `true`, true},
+		{`$['a b'].c`, `true`, true},
+		{"$.x", `null`, true},
+		{"$.server.host.name", ``, false},
+		{"$.server[0]", ``, false},
+		{"$.none", ``, false},
+	}
+	for _, tc := range testCases {
+		value, found, err := ProjectJSON(doc, tc.path)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.path, err)
+		}
+		if found != tc.found || string(value) != tc.value {
+			t.Fatalf("%q: wrong value %s %v", tc.path, value, found)
+		}
+	}
+	for _, w := range []string{"$a", "$..a", "$.a[", "$.a[-1]", "$.a[x]", `$["a]`, "$.a]"} {
+		if _, _, err := ProjectJSON(doc, w); err != ErrInvalidJSONPath {
+			t.Fatalf("%q: wrong error %v", w, err)
+		}
+	}
+	if _, _, err := ProjectJSON(json.RawMessage(`{"a": [1,}`), "$.a"); err == nil {
+		t.Fatal("no error for invalid JSON")
+	}
+}
@@ -5,6 +5,7 @@ package unkodb
 
 import (
 	"bytes"
+	"math"
 
 	"github.com/neetsdkasu/avltree"
 )
@@ -61,3 +62,184 @@ func (key bytesKey) CompareTo(other avltree.Key) (_ avltree.KeyOrdering) {
 func (key bytesKey) Copy() avltree.Key {
 	return key
 }
+
+// Timestampのキー
+// 1970-01-01 00:00:00 UTCからの秒数とナノ秒
+type timestampKey struct {
+	sec  int64
+	nsec int32
+}
+
+func (key timestampKey) CompareTo(other avltree.Key) (_ avltree.KeyOrdering) {
+	if x, ok := other.(timestampKey); ok {
+		switch {
+		case key.sec < x.sec:
+			return avltree.LessThanOtherKey
+		case key.sec > x.sec:
+			return avltree.GreaterThanOtherKey
+		case key.nsec < x.nsec:
+			return avltree.LessThanOtherKey
+		case key.nsec > x.nsec:
+			return avltree.GreaterThanOtherKey
+		default:
+			return avltree.EqualToOtherKey
+		}
+	} else {
+		bug.Panicf("invalid key type (key: %T %#v)", other, other)
+		return
+	}
+}
+
+func (key timestampKey) Copy() avltree.Key {
+	return key
+}
+
+// UUIDのキー
+// バイト列としての順序で比較する
+type uuidKey UUID
+
+func (key uuidKey) CompareTo(other avltree.Key) (_ avltree.KeyOrdering) {
+	if x, ok := other.(uuidKey); ok {
+		switch bytes.Compare(key[:], x[:]) {
+		case -1:
+			return avltree.LessThanOtherKey
+		case 1:
+			return avltree.GreaterThanOtherKey
+		default:
+			return avltree.EqualToOtherKey
+		}
+	} else {
+		bug.Panicf("invalid key type (key: %T %#v)", other, other)
+		return
+	}
+}
+
+func (key uuidKey) Copy() avltree.Key {
+	return key
+}
+
+// Int128のキー
+type int128Key Int128
+
+func (key int128Key) CompareTo(other avltree.Key) (_ avltree.KeyOrdering) {
+	if x, ok := other.(int128Key); ok {
+		switch Int128(key).Cmp(Int128(x)) {
+		case -1:
+			return avltree.LessThanOtherKey
+		case 1:
+			return avltree.GreaterThanOtherKey
+		default:
+			return avltree.EqualToOtherKey
+		}
+	} else {
+		bug.Panicf("invalid key type (key: %T %#v)", other, other)
+		return
+	}
+}
+
+func (key int128Key) Copy() avltree.Key {
+	return key
+}
+
+// Uint128のキー
+type uint128Key Uint128
+
+func (key uint128Key) CompareTo(other avltree.Key) (_ avltree.KeyOrdering) {
+	if x, ok := other.(uint128Key); ok {
+		switch Uint128(key).Cmp(Uint128(x)) {
+		case -1:
+			return avltree.LessThanOtherKey
+		case 1:
+			return avltree.GreaterThanOtherKey
+		default:
+			return avltree.EqualToOtherKey
+		}
+	} else {
+		bug.Panicf("invalid key type (key: %T %#v)", other, other)
+		return
+	}
+}
+
+func (key uint128Key) Copy() avltree.Key {
+	return key
+}
+
+// Float32とFloat64のキー
+// -0と+0やNaNも含めた全順序(IEEE 754のtotalOrder)で比較する
+// 順序は -NaN < -Inf < 負の数 < -0 < +0 < 正の数 < +Inf < +NaN となる
+// (math.NaN()は+NaNなので最大となる)
+type floatKey[T float32 | float64] struct {
+	value T
+}
+
+// 符号なし整数として比較すると全順序になるようにビット列を変換する
+func (key floatKey[T]) orderBits() (_ uint64) {
+	switch v := any(key.value).(type) {
+	case float32:
+		b := math.Float32bits(v)
+		if b&(1<<31) != 0 {
+			b = ^b
+		} else {
+			b |= 1 << 31
+		}
+		return uint64(b)
+	case float64:
+		b := math.Float64bits(v)
+		if b&(1<<63) != 0 {
+			b = ^b
+		} else {
+			b |= 1 << 63
+		}
+		return b
+	default:
+		bug.Panic("floatKey.orderBits: Unreachable")
+		return
+	}
+}
+
+func (key floatKey[T]) CompareTo(other avltree.Key) (_ avltree.KeyOrdering) {
+	if x, ok := other.(floatKey[T]); ok {
+		a, b := key.orderBits(), x.orderBits()
+		switch {
+		case a < b:
+			return avltree.LessThanOtherKey
+		case a > b:
+			return avltree.GreaterThanOtherKey
+		default:
+			return avltree.EqualToOtherKey
+		}
+	} else {
+		bug.Panicf("invalid key type (key: %T %#v)", other, other)
+		return
+	}
+}
+
+func (key floatKey[T]) Copy() avltree.Key {
+	return key
+}
+
+// 降順のキーのテーブルのキー
+// 元のキーの比較結果を逆にする
+type descendingKey struct {
+	key avltree.Key
+}
+
+func (key descendingKey) CompareTo(other avltree.Key) (_ avltree.KeyOrdering) {
+	if x, ok := other.(descendingKey); ok {
+		switch key.key.CompareTo(x.key) {
+		case avltree.LessThanOtherKey:
+			return avltree.GreaterThanOtherKey
+		case avltree.GreaterThanOtherKey:
+			return avltree.LessThanOtherKey
+		default:
+			return avltree.EqualToOtherKey
+		}
+	} else {
+		bug.Panicf("invalid key type (key: %T %#v)", other, other)
+		return
+	}
+}
+
+func (key descendingKey) Copy() avltree.Key {
+	return descendingKey{key.key.Copy()}
+}
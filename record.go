@@ -5,8 +5,11 @@ package unkodb
 
 // データのコピーを保持する。
 type Record struct {
-	table *Table
-	data  tableTreeValue
+	table   *Table
+	data    tableTreeValue
+	version uint64
+	// 一部のカラムだけを読み込んだかどうか
+	projected bool
 }
 
 // データ元のテーブルを参照する。
@@ -23,14 +26,30 @@ func (r *Record) Key() (value any) {
 	return
 }
 
+// データのバージョン番号を返す。
+// バージョン番号はデータの挿入時に1となり、置き換えるたびに1ずつ増える。
+// バージョン番号を保持しないテーブル（TableCreatorのEnableRowVersionを使わずに作成したテーブル）の場合は常に0を返す。
+//
+//	r, _ := table.Find(unkodb.CounterType(123))
+//	version := r.Version()
+//	// ... 編集 ...
+//	_, err := table.ReplaceWithVersion(edited, version)
+func (r *Record) Version() uint64 {
+	return r.version
+}
+
 // 指定カラム名のカラムの値を参照する。
 // テーブルに存在しないカラム名の場合はnilが返る。
 // キー名も指定できる。
+// Structのカラムの場合はサブカラムの値を参照する*StructValueが返る。
 //
 //	r, _ := table.Find(unkodb.CounterType(123))
 //	fmt.Println("id=", r.Column("id"), "name=", r.Column("name"))
 func (r *Record) Column(name string) any {
 	if value, ok := r.data[name]; ok {
+		if _, ok := value.(map[string]any); ok {
+			return wrapStructValue(r.table.Column(name), value)
+		}
 		return value
 	} else {
 		return nil
@@ -39,10 +58,11 @@ func (r *Record) Column(name string) any {
 
 // カラムの値を参照するリストを返す。
 // 値の順序はテーブルのカラムの順序と同じ。
+// Structのカラムの値は*StructValueとなる。
 func (r *Record) Columns() []any {
 	list := make([]any, len(r.table.columns))
 	for i, col := range r.table.columns {
-		list[i] = r.data[col.Name()]
+		list[i] = wrapStructValue(col, r.data[col.Name()])
 	}
 	return list
 }
@@ -50,12 +70,17 @@ func (r *Record) Columns() []any {
 // データの値をdstで渡した構造体に移動する。
 // dstにはunkodb.Dataかunkodbタグ付きの構造体のインスタンスを指定する。
 // データ移動後はこの*Recordは使用不可になる。
+// FindColumnsなどで一部のカラムだけを読み込んだ*Recordの場合はErrProjectedRecordのエラーが返る(dstは変更されない)。
 // 引数のdstに対応できない型などが渡された場合はエラーが返る。
 //
 //	r, _ := table.Find(unkodb.CounterType(123))
 //	var dst unkodb.Data
 //	r.MoveTo(&dst)
 func (r *Record) MoveTo(dst any) (err error) {
+	if r.projected {
+		err = ErrProjectedRecord
+		return
+	}
 	if !debugMode {
 		defer catchError(&err)
 	}
@@ -67,6 +92,7 @@ func (r *Record) MoveTo(dst any) (err error) {
 
 // データの値をdstで渡した構造体にコピーする。
 // dstにはunkodb.Dataかunkodbタグ付きの構造体のインスタンスを指定する。
+// FindColumnsなどで一部のカラムだけを読み込んだ*Recordの場合はErrProjectedRecordのエラーが返る(dstは変更されない)。
 // 引数のdstに対応できない型などが渡された場合はエラーが返る。
 //
 //	r, _ := table.Find(unkodb.CounterType(123))
@@ -74,6 +100,10 @@ func (r *Record) MoveTo(dst any) (err error) {
 //	r.CopyTo(&dst1)
 //	r.CopyTo(&dst2)
 func (r *Record) CopyTo(dst any) (err error) {
+	if r.projected {
+		err = ErrProjectedRecord
+		return
+	}
 	if !debugMode {
 		defer catchError(&err)
 	}
@@ -83,6 +113,8 @@ func (r *Record) CopyTo(dst any) (err error) {
 
 // 内部で保持してるデータを取り出す。
 // このメソッドの実行後はこの*Recordは使用不可になる。
+// FindColumnsなどで一部のカラムだけを読み込んだ*Recordの場合は読み込んだカラムとキーだけが入る(読み込まなかったカラムにnilは入らない)。
+// その場合は全てのカラムが揃っていないのでReplaceなどにそのまま渡すとErrNotFoundColumnNameのエラーになる。
 //
 //	r, _ := table.Find(unkodb.CounterType(123))
 //	fmt.Println("id=", r.Column("id"), "name=", r.Column("name"))
@@ -0,0 +1,420 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// 生成されたEncodeUnkoDBメソッドがデータをテーブルに書き込むために使う。
+// 各メソッドはカラム名と値を受け取り、テーブルのカラムと同じ形式でバイト列に書き込む(マップは経由しない)。
+// カラムはどの順番で書き込んでもよいが、テーブルのカラムの順番で書き込むと並べ替えが不要になる。
+// テーブルに存在しないカラム名の値を書き込んだ場合はErrNotFoundColumnNameのエラーが記録される。
+// 値の型がカラム型に合わない場合などのエラーは記録され、以降の書き込みは無視される。
+// 記録されたエラーはEncodeUnkoDBの終了後にInsertなどの戻り値のエラーとして返る。
+type RecordEncoder struct {
+	table   *Table
+	key     any
+	hasKey  bool
+	buf     bytes.Buffer
+	spans   []recordSpan
+	next    int
+	ordered bool
+	err     error
+}
+
+// バイト列の中のカラムのデータの位置
+// endが0の場合はまだ書き込まれていない
+type recordSpan struct {
+	start, end int
+}
+
+// 生成されたEncodeUnkoDBでテーブルのカラムの形式で書き込まれたデータ
+type encodedRecord struct {
+	key     any
+	columns []byte // テーブルのカラムの順に並んだカラムのデータ
+}
+
+func newRecordEncoder(table *Table) *RecordEncoder {
+	return &RecordEncoder{
+		table:   table,
+		spans:   make([]recordSpan, len(table.columns)),
+		ordered: true,
+	}
+}
+
+// EncoderのEncodeUnkoDBでデータを書き込む
+func encodeRecord(table *Table, enc Encoder) (*encodedRecord, error) {
+	e := newRecordEncoder(table)
+	err := enc.EncodeUnkoDB(e)
+	if err != nil {
+		return nil, err
+	}
+	return e.finish()
+}
+
+// 記録されたエラーを返す。
+func (e *RecordEncoder) Err() error {
+	return e.err
+}
+
+// nameのカラムにvalueを書き込む
+func (e *RecordEncoder) put(name string, value any) {
+	if e.err != nil {
+		return
+	}
+	table := e.table
+	if table.key.Name() == name {
+		if !table.key.IsValidValueType(value) {
+			e.err = invalidValueError(table.key, value)
+			return
+		}
+		e.key = value
+		e.hasKey = true
+		return
+	}
+	i := columnIndexOf(table.columns, name, &e.next)
+	if i < 0 {
+		e.err = &ErrNotFoundColumnName{unknownColumn(name, value)}
+		return
+	}
+	col := table.columns[i]
+	if !col.IsValidValueType(value) {
+		e.err = invalidValueError(col, value)
+		return
+	}
+	if e.spans[i].end > 0 {
+		e.err = &ErrWrongTag{fmt.Errorf(`duplicate name "%s"`, name)}
+		return
+	}
+	if e.ordered && (i == 0 || e.spans[i-1].end > 0) {
+		// 前のカラムまで全て書き込まれていれば並べ替えは不要
+	} else {
+		e.ordered = false
+	}
+	start := e.buf.Len()
+	err := col.write(newByteEncoder(&e.buf, fileByteOrder), value)
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.spans[i] = recordSpan{start, e.buf.Len()}
+}
+
+// テーブルに存在しないカラム名のカラム(ErrNotFoundColumnNameのエラーに入れるために使う)
+// カラム型は書き込もうとした値のGoの型から推定し、推定できない場合はBlobとする
+func unknownColumn(name string, value any) Column {
+	if value != nil {
+		ct, size, err := inferColumnType(reflect.TypeOf(value))
+		if err == nil && ct != Counter && ct != Counter64 && ct != Struct {
+			tc := newTableCreator(nil, "")
+			if makeColumn(tc, name, false, ct, size) == nil {
+				return tc.columns[0]
+			}
+		}
+	}
+	return &blobColumn{name: name}
+}
+
+// 書き込まれたデータをテーブルのカラムの順に並べる
+// キーや書き込まれていないカラムがある場合はErrNotFoundColumnNameのエラーを返す
+// キーがCounterやCounter64の場合はキーの値は割り当てられるので書き込まれなくてもよい
+func (e *RecordEncoder) finish() (*encodedRecord, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	table := e.table
+	if !e.hasKey && !table.hasCounterKey() {
+		return nil, &ErrNotFoundColumnName{table.key}
+	}
+	for i, span := range e.spans {
+		if span.end == 0 {
+			return nil, &ErrNotFoundColumnName{table.columns[i]}
+		}
+	}
+	rec := &encodedRecord{key: e.key}
+	if e.ordered {
+		rec.columns = e.buf.Bytes()
+	} else {
+		buf := e.buf.Bytes()
+		rec.columns = make([]byte, 0, len(buf))
+		for _, span := range e.spans {
+			rec.columns = append(rec.columns, buf[span.start:span.end]...)
+		}
+	}
+	return rec, nil
+}
+
+// カラムの値をマップにする
+func (rec *encodedRecord) toMap(table *Table) tableTreeValue {
+	m := make(tableTreeValue, len(table.columns)+1)
+	if rec.key != nil {
+		m[table.key.Name()] = rec.key
+	}
+	r := newByteDecoder(bytes.NewReader(rec.columns), fileByteOrder)
+	for _, col := range table.columns {
+		value, err := col.read(r)
+		if err != nil {
+			bug.Panicf("encodedRecord.toMap: column %#v %v", col, err)
+		}
+		m[col.Name()] = value
+	}
+	return m
+}
+
+// カラムのリストからnameのカラムの位置を返す(存在しない場合は-1を返す)
+// nextの位置から探し始め、見つかった場合はnextを次の位置にする
+func columnIndexOf(columns []Column, name string, next *int) int {
+	for k := range columns {
+		i := (*next + k) % len(columns)
+		if columns[i].Name() == name {
+			*next = i + 1
+			return i
+		}
+	}
+	return -1
+}
+
+// Int8のカラムに値を書き込む。
+func (e *RecordEncoder) Int8(name string, value int8) { e.put(name, value) }
+
+// Uint8のカラムに値を書き込む。
+func (e *RecordEncoder) Uint8(name string, value uint8) { e.put(name, value) }
+
+// Int16のカラムに値を書き込む。
+func (e *RecordEncoder) Int16(name string, value int16) { e.put(name, value) }
+
+// Uint16のカラムに値を書き込む。
+func (e *RecordEncoder) Uint16(name string, value uint16) { e.put(name, value) }
+
+// Int32のカラムに値を書き込む。
+func (e *RecordEncoder) Int32(name string, value int32) { e.put(name, value) }
+
+// Uint32やCounterのカラムに値を書き込む。
+func (e *RecordEncoder) Uint32(name string, value uint32) { e.put(name, value) }
+
+// Int64のカラムに値を書き込む。
+func (e *RecordEncoder) Int64(name string, value int64) { e.put(name, value) }
+
+// Uint64やCounter64のカラムに値を書き込む。
+func (e *RecordEncoder) Uint64(name string, value uint64) { e.put(name, value) }
+
+// Float32のカラムに値を書き込む。
+func (e *RecordEncoder) Float32(name string, value float32) { e.put(name, value) }
+
+// Float64のカラムに値を書き込む。
+func (e *RecordEncoder) Float64(name string, value float64) { e.put(name, value) }
+
+// Boolのカラムに値を書き込む。
+func (e *RecordEncoder) Bool(name string, value bool) { e.put(name, value) }
+
+// ShortStringやLongStringやTextやEnumなど文字列のカラムに値を書き込む。
+func (e *RecordEncoder) String(name string, value string) { e.put(name, value) }
+
+// ShortBytesやLongBytesやBlobなどバイト列のカラムに値を書き込む。
+// 値はバイト列に書き込まれるので、呼び出し後にvalueを変更してもデータには影響しない。
+func (e *RecordEncoder) Bytes(name string, value []byte) { e.put(name, value) }
+
+// TimestampやDateのカラムに値を書き込む。
+func (e *RecordEncoder) Time(name string, value time.Time) { e.put(name, value) }
+
+// Decimalのカラムに値を書き込む。
+func (e *RecordEncoder) Decimal(name string, value Decimal) { e.put(name, value) }
+
+// UUIDのカラムに値を書き込む。
+func (e *RecordEncoder) UUID(name string, value UUID) { e.put(name, value) }
+
+// Int128のカラムに値を書き込む。
+func (e *RecordEncoder) Int128(name string, value Int128) { e.put(name, value) }
+
+// Uint128のカラムに値を書き込む。
+func (e *RecordEncoder) Uint128(name string, value Uint128) { e.put(name, value) }
+
+// JSONのカラムに値を書き込む。
+func (e *RecordEncoder) JSON(name string, value json.RawMessage) { e.put(name, value) }
+
+// ListやStructなどそれ以外のカラムにカラム型に対応したGoの型の値を書き込む。
+func (e *RecordEncoder) Value(name string, value any) { e.put(name, value) }
+
+// 生成されたDecodeUnkoDBメソッドがテーブルのデータを読み込むために使う。
+// 各メソッドはカラム名を受け取り、カラムの値を返す。
+// TypedTableなどではノードのバイト列からテーブルのカラムと同じ形式で直接読み込む(マップは経由しない)。
+// カラムはどの順番で読み込んでもよいが、テーブルのカラムの順番で読み込むと読み飛ばしが不要になる。
+// テーブルに存在しないカラム名の場合や値の型が合わない場合はErrCannotAssignValueToFieldのエラーが記録され、
+// 以降の読み込みはゼロ値を返す。
+// 記録されたエラーはDecodeUnkoDBの終了後にMoveToなどの戻り値のエラーとして返る。
+// 読み込んだ値はデータのコピーであり、変更してもテーブルのデータには影響しない。
+type RecordDecoder struct {
+	// RecordのMoveToなどでマップから読み込む場合のマップ
+	data map[string]any
+
+	// ノードのバイト列から読み込む場合のテーブルのキーとカラム
+	key     keyColumn
+	keyData any
+	columns []Column
+	buf     []byte
+	offsets []int // offsets[i]はi番目のカラムのデータの位置(分かっている位置まで)
+	next    int
+	reader  bytes.Reader
+
+	err error
+}
+
+// マップから読み込むRecordDecoderを作る
+func newMapRecordDecoder(data map[string]any) *RecordDecoder {
+	return &RecordDecoder{data: data}
+}
+
+// ノードのバイト列から読み込むRecordDecoderを作る
+func newNodeRecordDecoder(node *tableTreeNode) *RecordDecoder {
+	table := node.tree.table
+	keyData, columns := node.openColumns(true)
+	d := &RecordDecoder{
+		key:     table.key,
+		keyData: keyData,
+		columns: table.columns,
+		buf:     columns,
+		offsets: make([]int, 1, len(table.columns)+1),
+	}
+	d.reader.Reset(columns)
+	return d
+}
+
+// DecoderのDecodeUnkoDBでデータを読み込む
+func decodeRecord(dec Decoder, d *RecordDecoder) error {
+	err := dec.DecodeUnkoDB(d)
+	if err != nil {
+		return err
+	}
+	return d.err
+}
+
+// 記録されたエラーを返す。
+func (d *RecordDecoder) Err() error {
+	return d.err
+}
+
+// nameのカラムの値を読み込む
+func (d *RecordDecoder) value(name string) (value any, ok bool) {
+	if d.data != nil {
+		value, ok = d.data[name]
+		return
+	}
+	if d.key.Name() == name {
+		return d.keyData, true
+	}
+	i := columnIndexOf(d.columns, name, &d.next)
+	if i < 0 {
+		return
+	}
+	// 読み込むカラムの位置が分かるまで前のカラムを読み飛ばす
+	r := newByteDecoder(&d.reader, fileByteOrder)
+	for len(d.offsets) <= i {
+		k := len(d.offsets) - 1
+		d.seek(d.offsets[k])
+		if err := skipColumn(d.columns[k], r); err != nil {
+			panic(&ErrWrongFileFormat{err.Error()})
+		}
+		d.offsets = append(d.offsets, len(d.buf)-d.reader.Len())
+	}
+	d.seek(d.offsets[i])
+	value, err := d.columns[i].read(r)
+	if err != nil {
+		panic(&ErrWrongFileFormat{err.Error()})
+	}
+	if len(d.offsets) == i+1 {
+		d.offsets = append(d.offsets, len(d.buf)-d.reader.Len())
+	}
+	return value, true
+}
+
+func (d *RecordDecoder) seek(offset int) {
+	if _, err := d.reader.Seek(int64(offset), io.SeekStart); err != nil {
+		bug.Panic(err)
+	}
+}
+
+// nameのカラムの値をカラム型に対応したGoの型Tで読み込む。
+// 生成されたDecodeUnkoDBではListのカラムの値([]int64など)の読み込みに使われる。
+// カラムが存在しない場合や値の型がTではない場合はErrCannotAssignValueToFieldのエラーが記録され、Tのゼロ値を返す。
+func DecodeValue[T any](d *RecordDecoder, name string) (value T) {
+	if d.err != nil {
+		return
+	}
+	v, ok := d.value(name)
+	if ok {
+		value, ok = v.(T)
+	}
+	if !ok {
+		d.err = ErrCannotAssignValueToField
+	}
+	return
+}
+
+// Int8のカラムの値を読み込む。
+func (d *RecordDecoder) Int8(name string) int8 { return DecodeValue[int8](d, name) }
+
+// Uint8のカラムの値を読み込む。
+func (d *RecordDecoder) Uint8(name string) uint8 { return DecodeValue[uint8](d, name) }
+
+// Int16のカラムの値を読み込む。
+func (d *RecordDecoder) Int16(name string) int16 { return DecodeValue[int16](d, name) }
+
+// Uint16のカラムの値を読み込む。
+func (d *RecordDecoder) Uint16(name string) uint16 { return DecodeValue[uint16](d, name) }
+
+// Int32のカラムの値を読み込む。
+func (d *RecordDecoder) Int32(name string) int32 { return DecodeValue[int32](d, name) }
+
+// Uint32やCounterのカラムの値を読み込む。
+func (d *RecordDecoder) Uint32(name string) uint32 { return DecodeValue[uint32](d, name) }
+
+// Int64のカラムの値を読み込む。
+func (d *RecordDecoder) Int64(name string) int64 { return DecodeValue[int64](d, name) }
+
+// Uint64やCounter64のカラムの値を読み込む。
+func (d *RecordDecoder) Uint64(name string) uint64 { return DecodeValue[uint64](d, name) }
+
+// Float32のカラムの値を読み込む。
+func (d *RecordDecoder) Float32(name string) float32 { return DecodeValue[float32](d, name) }
+
+// Float64のカラムの値を読み込む。
+func (d *RecordDecoder) Float64(name string) float64 { return DecodeValue[float64](d, name) }
+
+// Boolのカラムの値を読み込む。
+func (d *RecordDecoder) Bool(name string) bool { return DecodeValue[bool](d, name) }
+
+// ShortStringやLongStringやTextやEnumなど文字列のカラムの値を読み込む。
+func (d *RecordDecoder) String(name string) string { return DecodeValue[string](d, name) }
+
+// ShortBytesやLongBytesやBlobなどバイト列のカラムの値を読み込む。
+func (d *RecordDecoder) Bytes(name string) []byte { return DecodeValue[[]byte](d, name) }
+
+// TimestampやDateのカラムの値を読み込む。
+func (d *RecordDecoder) Time(name string) time.Time { return DecodeValue[time.Time](d, name) }
+
+// Decimalのカラムの値を読み込む。
+func (d *RecordDecoder) Decimal(name string) Decimal { return DecodeValue[Decimal](d, name) }
+
+// UUIDのカラムの値を読み込む。
+func (d *RecordDecoder) UUID(name string) UUID { return DecodeValue[UUID](d, name) }
+
+// Int128のカラムの値を読み込む。
+func (d *RecordDecoder) Int128(name string) Int128 { return DecodeValue[Int128](d, name) }
+
+// Uint128のカラムの値を読み込む。
+func (d *RecordDecoder) Uint128(name string) Uint128 { return DecodeValue[Uint128](d, name) }
+
+// JSONのカラムの値を読み込む。
+func (d *RecordDecoder) JSON(name string) json.RawMessage {
+	return DecodeValue[json.RawMessage](d, name)
+}
+
+// ListやStructなどそれ以外のカラムの値をカラム型に対応したGoの型で読み込む。
+func (d *RecordDecoder) Value(name string) any { return DecodeValue[any](d, name) }
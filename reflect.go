@@ -4,10 +4,15 @@
 package unkodb
 
 import (
+	"encoding"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"math/big"
 	"reflect"
-	"strconv"
-	"strings"
+	"time"
+
+	"github.com/neetsdkasu/unkodb/internal/unkodbtag"
 )
 
 // テーブルとデータをやりとりする際に使うことができる簡易データホルダー。
@@ -33,11 +38,83 @@ type Data struct {
 	Columns []any
 }
 
+// unkodbタグ付きの構造体をリフレクションを使わずにテーブルのデータに変換するためのインターフェース。
+// cmd/unkodb-genで生成されるEncodeUnkoDBメソッドが実装する。
+// EncodeUnkoDBはRecordEncoderのメソッドでキーと各カラムの値をカラム型に対応したGoの型で書き込む。
+// 値はテーブルのカラムと同じ形式でバイト列に書き込まれ、マップを経由せずにノードに書き込まれる。
+// InsertやReplaceなどでこのインターフェースを実装した構造体を渡すとタグの解析の代わりにこのメソッドが使われる。
+type Encoder interface {
+	EncodeUnkoDB(e *RecordEncoder) error
+}
+
+// テーブルのデータをリフレクションを使わずにunkodbタグ付きの構造体に設定するためのインターフェース。
+// cmd/unkodb-genで生成されるDecodeUnkoDBメソッドが実装する。
+// DecodeUnkoDBはRecordDecoderのメソッドでキーと各カラムの値をカラム型に対応したGoの型で読み込む。
+// TypedTableではノードのバイト列から直接読み込まれる。
+// RecordのMoveToやCopyToでこのインターフェースを実装した構造体を渡すとタグの解析の代わりにこのメソッドが使われる。
+type Decoder interface {
+	DecodeUnkoDB(d *RecordDecoder) error
+}
+
+// 構造体の値vがEncoderを実装していればそれを返す
+func asEncoder(v reflect.Value) (Encoder, bool) {
+	if v.CanAddr() {
+		if enc, ok := v.Addr().Interface().(Encoder); ok {
+			return enc, true
+		}
+	}
+	enc, ok := v.Interface().(Encoder)
+	return enc, ok
+}
+
+// dataがEncoderを実装した構造体(もしくはそのポインタ)であればEncoderを返す
+func encoderOf(data any) (Encoder, bool) {
+	if enc, ok := data.(Encoder); ok {
+		return enc, true
+	}
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, false
+	}
+	return asEncoder(v)
+}
+
+// 構造体の値vがDecoderを実装していればそれを返す
+func asDecoder(v reflect.Value) (Decoder, bool) {
+	if !v.CanAddr() {
+		return nil, false
+	}
+	dec, ok := v.Addr().Interface().(Decoder)
+	return dec, ok
+}
+
 var simpleColumnTypes = make(map[string]ColumnType)
 
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decimalType = reflect.TypeOf(Decimal{})
+	ratType     = reflect.TypeOf(big.Rat{})
+	uuidType    = reflect.TypeOf(UUID{})
+	int128Type  = reflect.TypeOf(Int128{})
+	uint128Type = reflect.TypeOf(Uint128{})
+	bigIntType  = reflect.TypeOf(big.Int{})
+	mapType     = reflect.TypeOf(map[string]any(nil))
+	jsonType    = reflect.TypeOf(json.RawMessage(nil))
+
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
 func init() {
 	cts := []ColumnType{
 		Counter,
+		Counter64,
 		Int8,
 		Uint8,
 		Int16,
@@ -54,6 +131,14 @@ func init() {
 		ShortBytes,
 		LongBytes,
 		Blob,
+		Bool,
+		Timestamp,
+		Date,
+		UUIDColumnType,
+		Int128ColumnType,
+		Uint128ColumnType,
+		Struct,
+		JSON,
 	}
 	for _, ct := range cts {
 		simpleColumnTypes[ct.String()] = ct
@@ -143,7 +228,7 @@ func fillData(r *Record, dst any) (err error) {
 		} else {
 			m[r.table.key.Name()] = r.table.key.copyValue(r.Key())
 			for _, col := range r.table.columns {
-				m[col.Name()] = col.copyValue(r.Column(col.Name()))
+				m[col.Name()] = col.copyValue(r.data[col.Name()])
 			}
 		}
 		return
@@ -243,7 +328,7 @@ func moveDataToDataStruct(r *Record, st any) error {
 		d.Columns = d.Columns[:0]
 	}
 	for _, col := range r.table.columns {
-		d.Columns = append(d.Columns, r.Column(col.Name()))
+		d.Columns = append(d.Columns, r.data[col.Name()])
 	}
 	return nil
 }
@@ -288,7 +373,7 @@ func fillDataToDataStruct(r *Record, st any) error {
 		d.Columns = d.Columns[:0]
 	}
 	for _, col := range r.table.columns {
-		cv := col.copyValue(r.Column(col.Name()))
+		cv := col.copyValue(r.data[col.Name()])
 		d.Columns = append(d.Columns, cv)
 	}
 	return nil
@@ -322,6 +407,9 @@ func moveDataToTaggedStruct(r *Record, st any) error {
 	if v.Kind() != reflect.Struct {
 		return errNotStruct
 	}
+	if dec, ok := asDecoder(v); ok {
+		return decodeRecord(dec, newMapRecordDecoder(r.data))
+	}
 	for _, f := range reflect.VisibleFields(v.Type()) {
 		tv, ok := f.Tag.Lookup(structTagKey)
 		if !ok {
@@ -331,7 +419,7 @@ func moveDataToTaggedStruct(r *Record, st any) error {
 		for ft.Kind() == reflect.Pointer {
 			ft = ft.Elem()
 		}
-		index := strings.LastIndex(tv, ",")
+		index := unkodbtag.SeparatorIndex(tv)
 		mKey := tv
 		var (
 			err  error
@@ -356,7 +444,7 @@ func moveDataToTaggedStruct(r *Record, st any) error {
 		if len(mKey) == 0 {
 			mKey = f.Name
 		}
-		rv := r.Column(mKey)
+		rv := r.data[mKey]
 		if rv == nil {
 			return &ErrWrongTag{fmt.Errorf(`not found column "%s" (field: %s)`, mKey, f.Name)}
 		}
@@ -376,7 +464,7 @@ func moveDataToTaggedStruct(r *Record, st any) error {
 			if col.Type() != ct {
 				return &ErrWrongTag{fmt.Errorf("umatch column type (field: %s)", f.Name)}
 			}
-			if size > 0 && size != col.MaximumDataByteSize() {
+			if size > 0 && size != columnSizeParam(col) {
 				return &ErrWrongTag{fmt.Errorf("umatch column type (field: %s)", f.Name)}
 			}
 		}
@@ -404,6 +492,13 @@ func fillDataToTaggedStruct(r *Record, st any) error {
 	if v.Kind() != reflect.Struct {
 		return errNotStruct
 	}
+	if dec, ok := asDecoder(v); ok {
+		data := make(tableTreeValue, len(r.data))
+		for name, value := range r.data {
+			data[name] = r.table.Column(name).copyValue(value)
+		}
+		return decodeRecord(dec, newMapRecordDecoder(data))
+	}
 	for _, f := range reflect.VisibleFields(v.Type()) {
 		tv, ok := f.Tag.Lookup(structTagKey)
 		if !ok {
@@ -413,7 +508,7 @@ func fillDataToTaggedStruct(r *Record, st any) error {
 		for ft.Kind() == reflect.Pointer {
 			ft = ft.Elem()
 		}
-		index := strings.LastIndex(tv, ",")
+		index := unkodbtag.SeparatorIndex(tv)
 		mKey := tv
 		var (
 			err  error
@@ -438,7 +533,7 @@ func fillDataToTaggedStruct(r *Record, st any) error {
 		if len(mKey) == 0 {
 			mKey = f.Name
 		}
-		rv := r.Column(mKey)
+		rv := r.data[mKey]
 		if rv == nil {
 			return &ErrWrongTag{fmt.Errorf(`not found column "%s" (field: %s)`, mKey, f.Name)}
 		}
@@ -458,7 +553,7 @@ func fillDataToTaggedStruct(r *Record, st any) error {
 			if col.Type() != ct {
 				return &ErrWrongTag{fmt.Errorf("umatch column type (field: %s)", f.Name)}
 			}
-			if size > 0 && size != col.MaximumDataByteSize() {
+			if size > 0 && size != columnSizeParam(col) {
 				return &ErrWrongTag{fmt.Errorf("umatch column type (field: %s)", f.Name)}
 			}
 		}
@@ -484,7 +579,7 @@ func tryMoveDataValue(fv reflect.Value, rv any, col Column) error {
 	switch col.Type() {
 	default:
 		bug.Panic("UNREACHABLE")
-	case Counter, Int8, Uint8, Int16, Uint16, Int32, Uint32, Int64, Uint64, Float32, Float64:
+	case Counter, Counter64, Int8, Uint8, Int16, Uint16, Int32, Uint32, Int64, Uint64, Float32, Float64:
 		value := reflect.ValueOf(rv)
 		if fv.Kind() == value.Kind() {
 			fv.Set(value)
@@ -499,6 +594,12 @@ func tryMoveDataValue(fv reflect.Value, rv any, col Column) error {
 		} else {
 			return ErrCannotAssignValueToField
 		}
+	case Enum:
+		if fv.Kind() == reflect.String {
+			fv.SetString(rv.(string))
+		} else {
+			return ErrCannotAssignValueToField
+		}
 	case ShortBytes, FixedSizeShortBytes, LongBytes, FixedSizeLongBytes, Blob:
 		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8 {
 			fv.Set(reflect.ValueOf(rv))
@@ -512,6 +613,90 @@ func tryMoveDataValue(fv reflect.Value, rv any, col Column) error {
 		} else {
 			return ErrCannotAssignValueToField
 		}
+	case Bool:
+		if fv.Kind() == reflect.Bool {
+			fv.SetBool(rv.(bool))
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case Timestamp, Date:
+		value := reflect.ValueOf(rv)
+		if value.CanConvert(fv.Type()) {
+			fv.Set(value.Convert(fv.Type()))
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case DecimalColumnType:
+		if fv.Type() == ratType {
+			fv.Addr().Interface().(*big.Rat).Set(rv.(Decimal).Rat())
+		} else if fv.Kind() == reflect.String {
+			fv.SetString(rv.(Decimal).String())
+		} else if value := reflect.ValueOf(rv); value.CanConvert(fv.Type()) {
+			fv.Set(value.Convert(fv.Type()))
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case UUIDColumnType:
+		if fv.Kind() == reflect.Array && uuidType.ConvertibleTo(fv.Type()) {
+			fv.Set(reflect.ValueOf(rv).Convert(fv.Type()))
+		} else if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if u.UnmarshalText([]byte(rv.(UUID).String())) != nil {
+				return ErrCannotAssignValueToField
+			}
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case Int128ColumnType:
+		if fv.Type() == bigIntType {
+			fv.Addr().Interface().(*big.Int).Set(rv.(Int128).Big())
+		} else if value := reflect.ValueOf(rv); value.CanConvert(fv.Type()) {
+			fv.Set(value.Convert(fv.Type()))
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case Uint128ColumnType:
+		if fv.Type() == bigIntType {
+			fv.Addr().Interface().(*big.Int).Set(rv.(Uint128).Big())
+		} else if value := reflect.ValueOf(rv); value.CanConvert(fv.Type()) {
+			fv.Set(value.Convert(fv.Type()))
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case List:
+		value := reflect.ValueOf(rv)
+		if fv.Type() == value.Type() {
+			fv.Set(value)
+		} else if fv.Kind() == reflect.Slice {
+			list := reflect.MakeSlice(fv.Type(), value.Len(), value.Len())
+			for i := 0; i < value.Len(); i++ {
+				if e := value.Index(i); e.CanConvert(fv.Type().Elem()) {
+					list.Index(i).Set(e.Convert(fv.Type().Elem()))
+				} else {
+					return ErrCannotAssignValueToField
+				}
+			}
+			fv.Set(list)
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case Struct:
+		if fv.Type() == mapType {
+			fv.Set(reflect.ValueOf(rv))
+		} else if fv.Kind() == reflect.Struct {
+			return setStructFields(fv, rv.(map[string]any), col.(*structColumn), false)
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case JSON:
+		if fv.Kind() == reflect.String {
+			fv.SetString(string(rv.(json.RawMessage)))
+		} else if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8 {
+			fv.Set(reflect.ValueOf(rv).Convert(fv.Type()))
+		} else if json.Unmarshal(rv.(json.RawMessage), fv.Addr().Interface()) != nil {
+			return ErrCannotAssignValueToField
+		}
+	case Custom:
+		return setCustomValue(fv, rv)
 	}
 	return nil
 }
@@ -529,7 +714,7 @@ func tryFillDataValue(fv reflect.Value, rv any, col Column) error {
 	switch col.Type() {
 	default:
 		bug.Panic("UNREACHABLE")
-	case Counter, Int8, Uint8, Int16, Uint16, Int32, Uint32, Int64, Uint64, Float32, Float64:
+	case Counter, Counter64, Int8, Uint8, Int16, Uint16, Int32, Uint32, Int64, Uint64, Float32, Float64:
 		value := reflect.ValueOf(rv)
 		if fv.Kind() == value.Kind() {
 			fv.Set(value)
@@ -544,6 +729,12 @@ func tryFillDataValue(fv reflect.Value, rv any, col Column) error {
 		} else {
 			return ErrCannotAssignValueToField
 		}
+	case Enum:
+		if fv.Kind() == reflect.String {
+			fv.SetString(rv.(string))
+		} else {
+			return ErrCannotAssignValueToField
+		}
 	case ShortBytes, FixedSizeShortBytes, LongBytes, FixedSizeLongBytes, Blob:
 		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8 {
 			// 本当にこれコピーする必要あるの？これ無駄処理ぽそう
@@ -559,6 +750,171 @@ func tryFillDataValue(fv reflect.Value, rv any, col Column) error {
 		} else {
 			return ErrCannotAssignValueToField
 		}
+	case Bool:
+		if fv.Kind() == reflect.Bool {
+			fv.SetBool(rv.(bool))
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case Timestamp, Date:
+		value := reflect.ValueOf(rv)
+		if value.CanConvert(fv.Type()) {
+			fv.Set(value.Convert(fv.Type()))
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case DecimalColumnType:
+		if fv.Type() == ratType {
+			fv.Addr().Interface().(*big.Rat).Set(rv.(Decimal).Rat())
+		} else if fv.Kind() == reflect.String {
+			fv.SetString(rv.(Decimal).String())
+		} else if value := reflect.ValueOf(rv); value.CanConvert(fv.Type()) {
+			fv.Set(value.Convert(fv.Type()))
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case UUIDColumnType:
+		if fv.Kind() == reflect.Array && uuidType.ConvertibleTo(fv.Type()) {
+			fv.Set(reflect.ValueOf(rv).Convert(fv.Type()))
+		} else if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if u.UnmarshalText([]byte(rv.(UUID).String())) != nil {
+				return ErrCannotAssignValueToField
+			}
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case Int128ColumnType:
+		if fv.Type() == bigIntType {
+			fv.Addr().Interface().(*big.Int).Set(rv.(Int128).Big())
+		} else if value := reflect.ValueOf(rv); value.CanConvert(fv.Type()) {
+			fv.Set(value.Convert(fv.Type()))
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case Uint128ColumnType:
+		if fv.Type() == bigIntType {
+			fv.Addr().Interface().(*big.Int).Set(rv.(Uint128).Big())
+		} else if value := reflect.ValueOf(rv); value.CanConvert(fv.Type()) {
+			fv.Set(value.Convert(fv.Type()))
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case List:
+		// 要素の[]byteも含めてコピーしたものを設定する
+		value := reflect.ValueOf(col.copyValue(rv))
+		if fv.Type() == value.Type() {
+			fv.Set(value)
+		} else if fv.Kind() == reflect.Slice {
+			list := reflect.MakeSlice(fv.Type(), value.Len(), value.Len())
+			for i := 0; i < value.Len(); i++ {
+				if e := value.Index(i); e.CanConvert(fv.Type().Elem()) {
+					list.Index(i).Set(e.Convert(fv.Type().Elem()))
+				} else {
+					return ErrCannotAssignValueToField
+				}
+			}
+			fv.Set(list)
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case Struct:
+		if fv.Type() == mapType {
+			fv.Set(reflect.ValueOf(col.copyValue(rv)))
+		} else if fv.Kind() == reflect.Struct {
+			return setStructFields(fv, rv.(map[string]any), col.(*structColumn), true)
+		} else {
+			return ErrCannotAssignValueToField
+		}
+	case JSON:
+		if fv.Kind() == reflect.String {
+			fv.SetString(string(rv.(json.RawMessage)))
+		} else if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8 {
+			fv.Set(reflect.ValueOf(col.copyValue(rv)).Convert(fv.Type()))
+		} else if json.Unmarshal(rv.(json.RawMessage), fv.Addr().Interface()) != nil {
+			return ErrCannotAssignValueToField
+		}
+	case Custom:
+		return setCustomValue(fv, col.copyValue(rv))
+	}
+	return nil
+}
+
+// 独自のカラム型の値rvをfvに設定する
+// ColumnCodecのDecodeが返した値の型がfvの型に代入か型変換できる必要がある
+func setCustomValue(fv reflect.Value, rv any) error {
+	value := reflect.ValueOf(rv)
+	if !value.IsValid() {
+		fv.Set(reflect.Zero(fv.Type()))
+	} else if value.Type().AssignableTo(fv.Type()) {
+		fv.Set(value)
+	} else if value.CanConvert(fv.Type()) {
+		fv.Set(value.Convert(fv.Type()))
+	} else {
+		return ErrCannotAssignValueToField
+	}
+	return nil
+}
+
+// Structのカラムの値mをunkodbタグ付きの構造体の値fvのフィールドに設定する
+// fillがtrueの場合はコピーした値を設定する
+func setStructFields(fv reflect.Value, m map[string]any, col *structColumn, fill bool) error {
+	if dec, ok := asDecoder(fv); ok {
+		if fill {
+			m = col.copyValue(m).(map[string]any)
+		}
+		return decodeRecord(dec, newMapRecordDecoder(m))
+	}
+	for _, f := range reflect.VisibleFields(fv.Type()) {
+		tv, ok := f.Tag.Lookup(structTagKey)
+		if !ok {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		index := unkodbtag.SeparatorIndex(tv)
+		mKey := tv
+		var (
+			err  error
+			ct   ColumnType = invalidColumnType
+			size uint64     = 0
+		)
+		if index < 0 {
+			_, _, err = inferColumnType(ft)
+			if err != nil {
+				return fmt.Errorf("%w (field: %s)", err, f.Name)
+			}
+		} else {
+			mKey = tv[:index]
+			_, ct, size, err = parseTagColumnType(tv[index+1:])
+			if err != nil {
+				return fmt.Errorf("%w (field: %s)", err, f.Name)
+			}
+			if !canConvertToColumnType(ft, ct, size) {
+				return fmt.Errorf("cannot convert type %s to %s (field: %s)", ft, ct.GoTypeHint(), f.Name)
+			}
+		}
+		if len(mKey) == 0 {
+			mKey = f.Name
+		}
+		sub := col.column(mKey)
+		if sub == nil {
+			return fmt.Errorf(`not found column "%s" (field: %s)`, mKey, f.Name)
+		}
+		if ct != invalidColumnType {
+			if sub.Type() != ct || (size > 0 && size != columnSizeParam(sub)) {
+				return fmt.Errorf("umatch column type (field: %s)", f.Name)
+			}
+		}
+		if fill {
+			err = tryFillDataValue(fv.FieldByIndex(f.Index), m[mKey], sub)
+		} else {
+			err = tryMoveDataValue(fv.FieldByIndex(f.Index), m[mKey], sub)
+		}
+		if err != nil {
+			return fmt.Errorf("%w (field: %s)", err, f.Name)
+		}
 	}
 	return nil
 }
@@ -571,7 +927,38 @@ func createTableByTaggedStruct(tc *TableCreator, st any) error {
 	if t.Kind() != reflect.Struct {
 		return errNotStruct
 	}
-	hasKey := false
+	hasKey, err := addColumnsByTaggedStruct(tc, t)
+	if err != nil {
+		return err
+	}
+	if !hasKey {
+		return ErrNotFoundKey
+	}
+	return nil
+}
+
+// Structのカラムのサブカラムをunkodbタグ付きの構造体の型tから作る
+// キーの指定があるタグはエラーとする
+func createStructSubColumns(t reflect.Type) ([]Column, error) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || !isTaggedStruct(t) {
+		return nil, &ErrWrongTag{fmt.Errorf("%s is not tagged struct", t)}
+	}
+	sub := newTableCreator(nil, "")
+	hasKey, err := addColumnsByTaggedStruct(sub, t)
+	if err != nil {
+		return nil, err
+	}
+	if hasKey {
+		return nil, &ErrWrongTag{fmt.Errorf("cannot use key in Struct column (type: %s)", t)}
+	}
+	return sub.columns, nil
+}
+
+// unkodbタグ付きの構造体の型tのフィールドに対応するキーとカラムをtcに設定する
+func addColumnsByTaggedStruct(tc *TableCreator, t reflect.Type) (hasKey bool, err error) {
 	m := make(map[string]bool)
 	for _, f := range reflect.VisibleFields(t) {
 		tv, ok := f.Tag.Lookup(structTagKey)
@@ -582,51 +969,74 @@ func createTableByTaggedStruct(tc *TableCreator, st any) error {
 		for ft.Kind() == reflect.Pointer {
 			ft = ft.Elem()
 		}
-		index := strings.LastIndex(tv, ",")
+		index := unkodbtag.SeparatorIndex(tv)
 		mKey := tv
 		var (
 			isKey bool
 			ct    ColumnType
 			size  uint64
-			err   error
 		)
 		if index < 0 {
 			ct, size, err = inferColumnType(ft)
 			if err != nil {
-				return &ErrWrongTag{fmt.Errorf("%w (field: %s)", err, f.Name)}
+				err = &ErrWrongTag{fmt.Errorf("%w (field: %s)", err, f.Name)}
+				return
 			}
 		} else {
 			mKey = tv[:index]
 			isKey, ct, size, err = parseTagColumnType(tv[index+1:])
 			if err != nil {
-				return &ErrWrongTag{fmt.Errorf("%w (field: %s)", err, f.Name)}
+				err = &ErrWrongTag{fmt.Errorf("%w (field: %s)", err, f.Name)}
+				return
 			}
 			if isKey {
 				if hasKey {
-					return &ErrWrongTag{fmt.Errorf("duplicate key (field: %s)", f.Name)}
+					err = &ErrWrongTag{fmt.Errorf("duplicate key (field: %s)", f.Name)}
+					return
 				}
 				hasKey = true
 			}
 			if !canConvertToColumnType(ft, ct, size) {
-				return &ErrWrongTag{fmt.Errorf("cannot convert type %s to %s (field: %s)", ft, ct.GoTypeHint(), f.Name)}
+				err = &ErrWrongTag{fmt.Errorf("cannot convert type %s to %s (field: %s)", ft, ct.GoTypeHint(), f.Name)}
+				return
 			}
 		}
 		if len(mKey) == 0 {
 			mKey = f.Name
 		}
 		if _, ok = m[mKey]; ok {
-			return &ErrWrongTag{fmt.Errorf(`duplicate name "%s" (field: %s)`, mKey, f.Name)}
+			err = &ErrWrongTag{fmt.Errorf(`duplicate name "%s" (field: %s)`, mKey, f.Name)}
+			return
 		}
 		m[mKey] = true
-		err = makeColumn(tc, mKey, isKey, ct, size)
+		if ct == Struct {
+			var columns []Column
+			columns, err = createStructSubColumns(ft)
+			if wt, ok := err.(*ErrWrongTag); ok {
+				err = &ErrWrongTag{fmt.Errorf("%w (field: %s)", wt.inner, f.Name)}
+			}
+			if err != nil {
+				return
+			}
+			err = tc.addColumn(newStructColumn(mKey, columns))
+		} else if ct == Custom {
+			tct, _ := unkodbtag.ParseColumnType(tv[index+1:])
+			err = tc.CustomColumn(mKey, tct.TypeName)
+		} else if ct == Enum {
+			tct, _ := unkodbtag.ParseColumnType(tv[index+1:])
+			if isKey {
+				err = tc.EnumKey(mKey, tct.Values...)
+			} else {
+				err = tc.EnumColumn(mKey, tct.Values...)
+			}
+		} else {
+			err = makeColumn(tc, mKey, isKey, ct, size)
+		}
 		if err != nil {
-			return err
+			return
 		}
 	}
-	if !hasKey {
-		return ErrNotFoundKey
-	}
-	return nil
+	return
 }
 
 func makeColumn(tc *TableCreator, mKey string, isKey bool, ct ColumnType, size uint64) (err error) {
@@ -639,6 +1049,12 @@ func makeColumn(tc *TableCreator, mKey string, isKey bool, ct ColumnType, size u
 		} else {
 			bug.Panic("UNREACHABLE")
 		}
+	case Counter64:
+		if isKey {
+			err = tc.Counter64Key(mKey)
+		} else {
+			bug.Panic("UNREACHABLE")
+		}
 	case Int8:
 		if isKey {
 			err = tc.Int8Key(mKey)
@@ -689,13 +1105,13 @@ func makeColumn(tc *TableCreator, mKey string, isKey bool, ct ColumnType, size u
 		}
 	case Float32:
 		if isKey {
-			bug.Panic("UNREACHABLE")
+			err = tc.Float32Key(mKey)
 		} else {
 			err = tc.Float32Column(mKey)
 		}
 	case Float64:
 		if isKey {
-			bug.Panic("UNREACHABLE")
+			err = tc.Float64Key(mKey)
 		} else {
 			err = tc.Float64Column(mKey)
 		}
@@ -717,7 +1133,7 @@ func makeColumn(tc *TableCreator, mKey string, isKey bool, ct ColumnType, size u
 		}
 	case LongString:
 		if isKey {
-			bug.Panic("UNREACHABLE")
+			err = tc.LongStringKey(mKey)
 		} else {
 			err = tc.LongStringColumn(mKey)
 		}
@@ -755,7 +1171,7 @@ func makeColumn(tc *TableCreator, mKey string, isKey bool, ct ColumnType, size u
 		}
 	case LongBytes:
 		if isKey {
-			bug.Panic("UNREACHABLE")
+			err = tc.LongBytesKey(mKey)
 		} else {
 			err = tc.LongBytesColumn(mKey)
 		}
@@ -775,6 +1191,61 @@ func makeColumn(tc *TableCreator, mKey string, isKey bool, ct ColumnType, size u
 		} else {
 			err = tc.BlobColumn(mKey)
 		}
+	case Bool:
+		if isKey {
+			bug.Panic("UNREACHABLE")
+		} else {
+			err = tc.BoolColumn(mKey)
+		}
+	case Timestamp:
+		if isKey {
+			err = tc.TimestampKey(mKey)
+		} else {
+			err = tc.TimestampColumn(mKey)
+		}
+	case Date:
+		if isKey {
+			bug.Panic("UNREACHABLE")
+		} else {
+			err = tc.DateColumn(mKey)
+		}
+	case DecimalColumnType:
+		precision, scale := uint8(size>>8), uint8(size)
+		if isKey {
+			err = tc.DecimalKey(mKey, precision, scale)
+		} else {
+			err = tc.DecimalColumn(mKey, precision, scale)
+		}
+	case UUIDColumnType:
+		if isKey {
+			err = tc.UUIDKey(mKey)
+		} else {
+			err = tc.UUIDColumn(mKey)
+		}
+	case Int128ColumnType:
+		if isKey {
+			err = tc.Int128Key(mKey)
+		} else {
+			err = tc.Int128Column(mKey)
+		}
+	case Uint128ColumnType:
+		if isKey {
+			err = tc.Uint128Key(mKey)
+		} else {
+			err = tc.Uint128Column(mKey)
+		}
+	case List:
+		if isKey {
+			bug.Panic("UNREACHABLE")
+		} else {
+			err = tc.ListColumn(mKey, ColumnType(size>>16), uint16(size))
+		}
+	case JSON:
+		if isKey {
+			bug.Panic("UNREACHABLE")
+		} else {
+			err = tc.JSONColumn(mKey)
+		}
 	}
 	return
 }
@@ -783,9 +1254,29 @@ func inferColumnType(t reflect.Type) (ct ColumnType, size uint64, err error) {
 	for t.Kind() == reflect.Pointer {
 		t = t.Elem()
 	}
+	switch t {
+	case timeType:
+		ct = Timestamp
+		return
+	case uuidType:
+		ct = UUIDColumnType
+		return
+	case int128Type:
+		ct = Int128ColumnType
+		return
+	case uint128Type:
+		ct = Uint128ColumnType
+		return
+	}
+	if isTaggedStruct(t) {
+		ct = Struct
+		return
+	}
 	switch t.Kind() {
 	default:
 		err = fmt.Errorf("cannot convert to column type")
+	case reflect.Bool:
+		ct = Bool
 	case reflect.Int8:
 		ct = Int8
 	case reflect.Int16:
@@ -862,7 +1353,7 @@ func canConvertToColumnType(t reflect.Type, ct ColumnType, size uint64) (_ bool)
 	case Int64:
 		return t.Kind() == reflect.Int64 ||
 			t.ConvertibleTo(reflect.TypeOf(int64(0)))
-	case Uint64:
+	case Counter64, Uint64:
 		return t.Kind() == reflect.Uint64 ||
 			t.ConvertibleTo(reflect.TypeOf(uint64(0)))
 	case Float32:
@@ -871,7 +1362,7 @@ func canConvertToColumnType(t reflect.Type, ct ColumnType, size uint64) (_ bool)
 	case Float64:
 		return t.Kind() == reflect.Float64 ||
 			t.ConvertibleTo(reflect.TypeOf(float64(0)))
-	case ShortString, FixedSizeShortString, LongString, FixedSizeLongString, Text:
+	case ShortString, FixedSizeShortString, LongString, FixedSizeLongString, Text, Enum:
 		return t.Kind() == reflect.String
 	case ShortBytes:
 		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
@@ -903,6 +1394,36 @@ func canConvertToColumnType(t reflect.Type, ct ColumnType, size uint64) (_ bool)
 		} else if t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8 {
 			return t.Len() <= blobMaximumDataByteSize
 		}
+	case Bool:
+		return t.Kind() == reflect.Bool
+	case Timestamp, Date:
+		return t.ConvertibleTo(timeType)
+	case DecimalColumnType:
+		return t == ratType || t.Kind() == reflect.String || t.ConvertibleTo(decimalType)
+	case UUIDColumnType:
+		if t.Kind() == reflect.Array && t.ConvertibleTo(uuidType) {
+			return true
+		}
+		pt := reflect.PointerTo(t)
+		return pt.Implements(textMarshalerType) && pt.Implements(textUnmarshalerType)
+	case Int128ColumnType:
+		return t == bigIntType || t.ConvertibleTo(int128Type)
+	case Uint128ColumnType:
+		return t == bigIntType || t.ConvertibleTo(uint128Type)
+	case List:
+		return t.Kind() == reflect.Slice && canConvertToColumnType(t.Elem(), ColumnType(size>>16), 0)
+	case Struct:
+		return t == mapType || isTaggedStruct(t)
+	case JSON:
+		// string、[]byteはJSONのテキストとして、それ以外はencoding/jsonで変換して扱う
+		switch t.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+			return false
+		}
+		return true
+	case Custom:
+		// Goの型の確認はColumnCodecのIsValidValueで行う
+		return true
 	}
 	return
 }
@@ -917,6 +1438,15 @@ func parseData(table *Table, data any) (tableTreeValue, error) {
 	if m := parseDataStruct(table, data); m != nil {
 		return m, nil
 	}
+	if table != nil {
+		if enc, ok := encoderOf(data); ok {
+			rec, err := encodeRecord(table, enc)
+			if err != nil {
+				return nil, err
+			}
+			return rec.toMap(table), nil
+		}
+	}
 	if m, err := parseTaggedStruct(data); err != errNotStruct {
 		return m, err
 	}
@@ -972,13 +1502,41 @@ func parseDataStruct(table *Table, data any) tableTreeValue {
 }
 
 func parseTagColumnType(s string) (isKey bool, ct ColumnType, size uint64, err error) {
-	if strings.HasPrefix(s, "key@") {
-		isKey = true
-		s = strings.TrimPrefix(s, "key@")
+	tct, err := unkodbtag.ParseColumnType(s)
+	if err != nil {
+		return
 	}
-	if tmp, ok := simpleColumnTypes[s]; ok {
-		if tmp == Counter && !isKey {
-			err = fmt.Errorf(`Counter type need prefix "key@"`)
+	isKey = tct.IsKey
+	switch tct.Name {
+	case List.String():
+		return parseTagListType(tct)
+	case Enum.String():
+		if _, ok := newEnumColumn("", tct.Values); !ok {
+			err = fmt.Errorf("wrong enum values")
+			return
+		}
+		return isKey, Enum, stringsHash(tct.Values), nil
+	case Custom.String():
+		if isKey {
+			err = fmt.Errorf("invalid key type")
+			return
+		}
+		if _, ok := columnCodecs[tct.TypeName]; !ok {
+			err = fmt.Errorf("unregistered column type %q", tct.TypeName)
+			return
+		}
+		return false, Custom, stringsHash([]string{tct.TypeName}), nil
+	case DecimalColumnType.String():
+		// sizeにはprecisionとscaleをprecision<<8|scaleの形でまとめて返す
+		if tct.Precision < 1 || MaximumDecimalPrecision < tct.Precision || tct.Precision < tct.Scale {
+			err = fmt.Errorf("wrong precision and scale")
+			return
+		}
+		return isKey, DecimalColumnType, tct.Precision<<8 | tct.Scale, nil
+	}
+	if tmp, ok := simpleColumnTypes[tct.Name]; ok {
+		if (tmp == Counter || tmp == Counter64) && !isKey {
+			err = fmt.Errorf(`%s type need prefix "key@"`, tmp)
 			return
 		}
 		if isKey && !tmp.keyColumnType() {
@@ -988,46 +1546,89 @@ func parseTagColumnType(s string) (isKey bool, ct ColumnType, size uint64, err e
 		}
 		return
 	}
-	type ft struct {
-		ct  ColumnType
-		max uint64
-	}
-	var fts = [4]ft{
-		ft{FixedSizeShortString, shortStringMaximumDataByteSize},
-		ft{FixedSizeLongString, longStringMaximumDataByteSize},
-		ft{FixedSizeShortBytes, shortBytesMaximumDataByteSize},
-		ft{FixedSizeLongBytes, longBytesMaximumDataByteSize},
-	}
-	ct = invalidColumnType
-	for _, x := range fts {
-		p := x.ct.String()
-		if strings.HasPrefix(s, p) {
-			s = strings.TrimPrefix(s, p)
-			ct = x.ct
-			size = x.max
-			break
-		}
+	var maxSize = map[ColumnType]uint64{
+		FixedSizeShortString: shortStringMaximumDataByteSize,
+		FixedSizeLongString:  longStringMaximumDataByteSize,
+		FixedSizeShortBytes:  shortBytesMaximumDataByteSize,
+		FixedSizeLongBytes:   longBytesMaximumDataByteSize,
 	}
-	if ct == invalidColumnType {
-		err = fmt.Errorf("not found type name")
-		return
+	for fct, max := range maxSize {
+		if fct.String() != tct.Name {
+			continue
+		}
+		if isKey && !fct.keyColumnType() {
+			err = fmt.Errorf("invalid key type")
+			return
+		}
+		if tct.Size > max {
+			err = fmt.Errorf("wrong size")
+			return
+		}
+		return isKey, fct, tct.Size, nil
 	}
-	if isKey && !ct.keyColumnType() {
+	err = fmt.Errorf("not found type name")
+	return
+}
+
+// 文字列のリストのハッシュ値
+// unkodbタグのEnumの値の指定やCustomの名前の指定とカラムの定義が一致するかの確認に使う
+func stringsHash(values []string) uint64 {
+	h := fnv.New64a()
+	for _, v := range values {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// List<要素のカラム型>[最大要素数]の要素のカラム型を確認する
+// sizeには要素のカラム型と最大要素数を要素のカラム型<<16|最大要素数の形でまとめて返す
+func parseTagListType(tct unkodbtag.ColumnType) (_ bool, ct ColumnType, size uint64, err error) {
+	if tct.IsKey {
 		err = fmt.Errorf("invalid key type")
 		return
 	}
-	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
-		err = fmt.Errorf("not found size syntax")
+	elemType, ok := simpleColumnTypes[tct.Elem]
+	if !ok {
+		err = fmt.Errorf("invalid element type")
 		return
 	}
-	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
-	tmp, e := strconv.ParseUint(s, 10, 16)
-	if e != nil || tmp == 0 || tmp > size {
-		err = fmt.Errorf("wrong size")
+	if _, ok = newListColumn("", elemType, 1); !ok {
+		err = fmt.Errorf("invalid element type")
 		return
 	}
-	size = tmp
-	return
+	return false, List, uint64(elemType)<<16 | tct.Size, nil
+}
+
+// unkodbタグのカラム型の[]内で指定する値に相当する値を返す
+// 固定長タイプのカラム型ならサイズ、Decimalならprecision<<8|scale、
+// Listなら要素のカラム型<<16|最大要素数、Enumなら値のリストのハッシュ値、
+// Customなら名前のハッシュ値、それ以外はMaximumDataByteSize
+func columnSizeParam(col Column) uint64 {
+	switch c := col.(type) {
+	case *enumColumn:
+		return stringsHash(c.values)
+	case *customColumn:
+		return stringsHash([]string{c.typeName})
+	case *decimalColumn:
+		return uint64(c.precision)<<8 | uint64(c.scale)
+	case listColumnSpec:
+		elem, maxCount := c.listSpec()
+		return uint64(elem.Type())<<16 | uint64(maxCount)
+	}
+	return col.MaximumDataByteSize()
+}
+
+// vのポインタをinterfaceにして返す
+// アドレスを取れない値の場合はコピーのポインタとなる
+// ポインタレシーバのメソッドを持つ型(big.Intなど)を扱うために使う
+func addrInterface(v reflect.Value) any {
+	if !v.CanAddr() {
+		tmp := reflect.New(v.Type())
+		tmp.Elem().Set(v)
+		v = tmp.Elem()
+	}
+	return v.Addr().Interface()
 }
 
 func tryConvertToColumnValue(v reflect.Value, ct ColumnType, size uint64) (r reflect.Value, ok bool) {
@@ -1101,7 +1702,7 @@ func tryConvertToColumnValue(v reflect.Value, ct ColumnType, size uint64) (r ref
 			r = v.Convert(reflect.TypeOf(int64(0)))
 			ok = true
 		}
-	case Uint64:
+	case Counter64, Uint64:
 		if v.Kind() == reflect.Uint64 {
 			r = v
 			ok = true
@@ -1133,6 +1734,11 @@ func tryConvertToColumnValue(v reflect.Value, ct ColumnType, size uint64) (r ref
 			r = v
 			ok = true
 		}
+	case Enum:
+		if v.Kind() == reflect.String {
+			r = reflect.ValueOf(v.String())
+			ok = true
+		}
 	case ShortBytes, FixedSizeShortBytes, LongBytes, FixedSizeLongBytes, Blob:
 		// スライスの長さを適性に変更するまではしなくていいか･･･？
 		if v.Kind() == reflect.Slice {
@@ -1147,6 +1753,127 @@ func tryConvertToColumnValue(v reflect.Value, ct ColumnType, size uint64) (r ref
 				ok = true
 			}
 		}
+	case Bool:
+		if v.Kind() == reflect.Bool {
+			r = v.Convert(reflect.TypeOf(false))
+			ok = true
+		}
+	case Timestamp, Date:
+		if v.CanConvert(timeType) {
+			r = v.Convert(timeType)
+			ok = true
+		}
+	case DecimalColumnType:
+		// Decimalに変換できない値はそのまま渡してカラム型の検査でエラーにする
+		if v.Type() == ratType {
+			if d, ok := decimalFromRat(addrInterface(v).(*big.Rat)); ok {
+				r = reflect.ValueOf(d)
+			} else {
+				r = v
+			}
+			ok = true
+		} else if v.Kind() == reflect.String {
+			if d, err := ParseDecimal(v.String()); err == nil {
+				r = reflect.ValueOf(d)
+			} else {
+				r = v
+			}
+			ok = true
+		} else if v.CanConvert(decimalType) {
+			r = v.Convert(decimalType)
+			ok = true
+		}
+	case UUIDColumnType:
+		// UUIDに変換できない値はそのまま渡してカラム型の検査でエラーにする
+		if v.Kind() == reflect.Array && v.CanConvert(uuidType) {
+			r = v.Convert(uuidType)
+			ok = true
+		} else if m, isMarshaler := addrInterface(v).(encoding.TextMarshaler); isMarshaler {
+			r = v
+			if text, err := m.MarshalText(); err == nil {
+				if u, err := ParseUUID(string(text)); err == nil {
+					r = reflect.ValueOf(u)
+				}
+			}
+			ok = true
+		}
+	case Int128ColumnType:
+		// Int128に変換できない値はそのまま渡してカラム型の検査でエラーにする
+		if v.Type() == bigIntType {
+			r = v
+			if x, isInt128 := Int128FromBig(addrInterface(v).(*big.Int)); isInt128 {
+				r = reflect.ValueOf(x)
+			}
+			ok = true
+		} else if v.CanConvert(int128Type) {
+			r = v.Convert(int128Type)
+			ok = true
+		}
+	case Uint128ColumnType:
+		// Uint128に変換できない値はそのまま渡してカラム型の検査でエラーにする
+		if v.Type() == bigIntType {
+			r = v
+			if x, isUint128 := Uint128FromBig(addrInterface(v).(*big.Int)); isUint128 {
+				r = reflect.ValueOf(x)
+			}
+			ok = true
+		} else if v.CanConvert(uint128Type) {
+			r = v.Convert(uint128Type)
+			ok = true
+		}
+	case List:
+		if v.Kind() != reflect.Slice {
+			break
+		}
+		elemType := ColumnType(size >> 16)
+		col, _ := newListColumn("", elemType, uint16(size))
+		listType := reflect.TypeOf(col.(listColumnSpec).emptyList())
+		if v.Type() == listType {
+			r = v
+			ok = true
+			break
+		}
+		list := reflect.MakeSlice(listType, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, eok := tryConvertToColumnValue(v.Index(i), elemType, 0)
+			if !eok {
+				return
+			}
+			if e.Type() != listType.Elem() {
+				if !e.CanConvert(listType.Elem()) {
+					return
+				}
+				e = e.Convert(listType.Elem())
+			}
+			list.Index(i).Set(e)
+		}
+		r = list
+		ok = true
+	case Struct:
+		if v.Type() == mapType {
+			r = v
+			ok = true
+		} else if isTaggedStruct(v.Type()) {
+			if m, err := parseTaggedStruct(addrInterface(v)); err == nil {
+				r = reflect.ValueOf(m)
+				ok = true
+			}
+		}
+	case JSON:
+		// JSONに変換できない値はそのまま渡してカラム型の検査でエラーにする
+		if v.Kind() == reflect.String {
+			r = reflect.ValueOf(json.RawMessage(v.String()))
+		} else if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			r = v.Convert(jsonType)
+		} else if doc, err := json.Marshal(addrInterface(v)); err == nil {
+			r = reflect.ValueOf(json.RawMessage(doc))
+		} else {
+			r = v
+		}
+		ok = true
+	case Custom:
+		r = v
+		ok = true
 	}
 	return
 }
@@ -1177,12 +1904,17 @@ func parseTaggedStruct(st any) (tableTreeValue, error) {
 			}
 			value = value.Elem()
 		}
-		index := strings.LastIndex(tv, ",")
+		index := unkodbtag.SeparatorIndex(tv)
 		mKey := tv
 		if index < 0 {
-			if value.Kind() == reflect.Array {
+			if value.Kind() == reflect.Array && value.Type() != uuidType {
 				sl := value.Len()
 				value = value.Slice(0, sl)
+			} else if isTaggedStruct(value.Type()) {
+				value, ok = tryConvertToColumnValue(value, Struct, 0)
+				if !ok {
+					return nil, &ErrWrongTag{fmt.Errorf("cannot convert type %s to %s (field: %s)", f.Type, Struct.GoTypeHint(), f.Name)}
+				}
 			}
 		} else {
 			isKey, ct, size, e := parseTagColumnType(tv[index+1:])
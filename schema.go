@@ -0,0 +1,315 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/neetsdkasu/avltree"
+)
+
+// unkodbタグ付きの構造体とテーブルの構造の違い。
+// TableのCheckStructで取得する。
+// カラム名はキーも含む。
+type StructDiff struct {
+	// テーブルにあるが構造体に対応するフィールドがないカラムの名前(テーブルでの順番)
+	MissingColumns []string
+
+	// 構造体にあるがテーブルに存在しないカラムの名前(構造体のフィールドの順番)
+	ExtraColumns []string
+
+	// 構造体とテーブルでカラム型(固定長の場合はサイズも)やキーであるかどうかが一致しないカラムの名前(テーブルでの順番)
+	UnmatchColumns []string
+}
+
+// 構造体とテーブルの構造が一致している場合にtrueを返す。
+func (diff *StructDiff) IsEmpty() bool {
+	return len(diff.MissingColumns) == 0 && len(diff.ExtraColumns) == 0 && len(diff.UnmatchColumns) == 0
+}
+
+func (diff *StructDiff) String() string {
+	if diff.IsEmpty() {
+		return "no difference"
+	}
+	var list []string
+	if len(diff.MissingColumns) > 0 {
+		list = append(list, "missing: "+strings.Join(diff.MissingColumns, ", "))
+	}
+	if len(diff.ExtraColumns) > 0 {
+		list = append(list, "extra: "+strings.Join(diff.ExtraColumns, ", "))
+	}
+	if len(diff.UnmatchColumns) > 0 {
+		list = append(list, "unmatch: "+strings.Join(diff.UnmatchColumns, ", "))
+	}
+	return strings.Join(list, "; ")
+}
+
+// unkodbタグ付きの構造体とテーブルのキーやカラムの構造を比較してその違いを返す。
+// 構造体はCreateTableByTaggedStructと同じ方法で解釈される。
+// タグの指定に不正がある場合はErrWrongTagなどのエラーが返る。
+//
+//	diff, _ := table.CheckStruct((*Book)(nil))
+//	if !diff.IsEmpty() {
+//		log.Fatal("Book does not match the table: ", diff)
+//	}
+func (table *Table) CheckStruct(taggedStruct any) (diff *StructDiff, err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var tc *TableCreator
+	tc, err = parseStructSchema(table.name, taggedStruct)
+	if err != nil {
+		return
+	}
+	diff = table.diffStruct(tc)
+	return
+}
+
+// parseStructSchemaで組み立てた構造体のキーとカラムとテーブルのキーとカラムを比較する
+func (table *Table) diffStruct(tc *TableCreator) (diff *StructDiff) {
+	diff = &StructDiff{}
+	stKey := tc.key
+	stColumns := make(map[string]Column)
+	stColumns[stKey.Name()] = stKey
+	for _, col := range tc.columns {
+		stColumns[col.Name()] = col
+	}
+	checkColumn := func(col Column, isKey bool) {
+		stCol, ok := stColumns[col.Name()]
+		if !ok {
+			diff.MissingColumns = append(diff.MissingColumns, col.Name())
+			return
+		}
+		if (stCol == stKey) != isKey || !sameColumnType(col, stCol) {
+			diff.UnmatchColumns = append(diff.UnmatchColumns, col.Name())
+		}
+	}
+	checkColumn(table.key, true)
+	for _, col := range table.columns {
+		checkColumn(col, false)
+	}
+	if table.Column(stKey.Name()) == nil {
+		diff.ExtraColumns = append(diff.ExtraColumns, stKey.Name())
+	}
+	for _, col := range tc.columns {
+		if table.Column(col.Name()) == nil {
+			diff.ExtraColumns = append(diff.ExtraColumns, col.Name())
+		}
+	}
+	return
+}
+
+// 指定した名前のテーブルをunkodbタグ付きの構造体に合うようにして返す。
+// テーブルが存在しない場合はCreateTableByTaggedStructと同じようにテーブルを作成する。
+// テーブルが存在して構造体にだけあるカラムがある場合は、そのカラムをテーブルに追加する。
+// 既存のデータの追加したカラムにはゼロ値(数値なら0、可変長の文字列やバイト列なら空、固定長ならバイトが0で埋められた値)が入る。
+// カラムの追加では全てのデータを読み込んでテーブルを組み直すため、データ量に応じたメモリと時間が必要になる。
+// 組み直したデータは別の領域に書き込んでから切り替えるため、途中でエラーになった場合も既存のデータは失われない（一時的に既存のデータと同程度のファイル領域が追加で必要になる）。
+// 追加するカラムが独自のカラム型の場合、ColumnCodecのDecodeが長さ0のバイト列に対してエラーを返すとそのエラーが返る(テーブルは変更されない)。
+// 戻り値のテーブルは既存の*Tableと同じものであり、追加したカラムはそのまま使えるようになる。
+// テーブルのカラムで構造体にないものがある場合や、キーやカラム型が一致しない場合はErrIncompatibleStructのエラーが返る(テーブルは変更されない)。
+// タグの指定に不正がある場合はErrWrongTagなどのエラーが返る。
+// それ以外のエラー（IOエラーなど）がある場合にも戻り値エラーはnil以外が返る。(たいていプログラムの実行にとって致命的エラー)。
+//
+//	type Book struct {
+//		Id     unkodb.CounterType `unkodb:"id,key@Counter"`
+//		Title  string             `unkodb:"title,ShortString"`
+//		Author string             `unkodb:"author,ShortString"`
+//		Price  int64              `unkodb:"price,Int64"` // 後から追加したフィールド
+//	}
+//	table, _ := db.EnsureTableByTaggedStruct("my_book_table", (*Book)(nil))
+func (db *UnkoDB) EnsureTableByTaggedStruct(tableName string, taggedStruct any) (table *Table, err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	table = db.Table(tableName)
+	if table == nil {
+		table, err = db.CreateTableByTaggedStruct(tableName, taggedStruct)
+		return
+	}
+	var tc *TableCreator
+	tc, err = parseStructSchema(tableName, taggedStruct)
+	if err != nil {
+		table = nil
+		return
+	}
+	diff := table.diffStruct(tc)
+	if diff.IsEmpty() {
+		return
+	}
+	if len(diff.MissingColumns) > 0 || len(diff.UnmatchColumns) > 0 {
+		table = nil
+		err = ErrIncompatibleStruct
+		return
+	}
+	// 既存のカラムの順番は変えずに末尾に追加する
+	columns := append([]Column{}, table.columns...)
+	for _, name := range diff.ExtraColumns {
+		for _, col := range tc.columns {
+			if col.Name() == name {
+				columns = append(columns, col)
+				break
+			}
+		}
+	}
+	err = table.migrate(columns)
+	if err != nil {
+		table = nil
+	}
+	return
+}
+
+// unkodbタグ付きの構造体からテーブルのキーとカラムを組み立てる
+func parseStructSchema(tableName string, taggedStruct any) (*TableCreator, error) {
+	tc := newTableCreator(nil, tableName)
+	err := createTableByTaggedStruct(tc, taggedStruct)
+	if err == errNotStruct {
+		return nil, &ErrWrongTag{fmt.Errorf("%T is not tagged struct", taggedStruct)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tc, nil
+}
+
+func sameColumnType(col1, col2 Column) bool {
+	if s1, ok := col1.(*structColumn); ok {
+		s2, ok := col2.(*structColumn)
+		if !ok || len(s1.columns) != len(s2.columns) {
+			return false
+		}
+		for i, sub := range s1.columns {
+			if sub.Name() != s2.columns[i].Name() || !sameColumnType(sub, s2.columns[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if e1, ok := col1.(*enumColumn); ok {
+		e2, ok := col2.(*enumColumn)
+		if !ok || len(e1.values) != len(e2.values) {
+			return false
+		}
+		for i, v := range e1.values {
+			if v != e2.values[i] {
+				return false
+			}
+		}
+		return true
+	}
+	if c1, ok := col1.(*customColumn); ok {
+		c2, ok := col2.(*customColumn)
+		return ok && c1.typeName == c2.typeName
+	}
+	return col1.Type() == col2.Type() &&
+		col1.MinimumDataByteSize() == col2.MinimumDataByteSize() &&
+		col1.MaximumDataByteSize() == col2.MaximumDataByteSize() &&
+		columnSizeParam(col1) == columnSizeParam(col2)
+}
+
+// テーブルのカラムを置き換えて全てのデータを組み直す
+// キーは変更しない
+// columnsに追加されたカラムの値はゼロ値とし、columnsにないカラムの値は捨てる
+// 新しいカラムのデータで組み直した木を別の領域に書き込んでから、テーブル情報の書き込みで新しい木に切り替え、
+// その後に古い木の領域を解放する
+// 切り替えより前に失敗した場合は元のデータは残る(組み直しの途中で失敗した場合はそれまでに割り当てた領域はbuildBalancedTreeで解放される)
+func (table *Table) migrate(columns []Column) (err error) {
+	if table.isIterating() {
+		err = ErrInvalidOperation
+		return
+	}
+	// TableCreatorと同じ規則でカラム数やカラム名を確認する
+	tc := newTableCreator(nil, table.name)
+	if err = tc.setKey(table.key); err != nil {
+		return
+	}
+	for _, col := range columns {
+		if err = tc.addColumn(col); err != nil {
+			return
+		}
+	}
+	zeroValues := make(map[string]any)
+	for _, col := range columns {
+		if table.Column(col.Name()) != nil {
+			continue
+		}
+		zeroValues[col.Name()], err = col.zeroValue()
+		if err != nil {
+			return
+		}
+	}
+	next := *table
+	next.rootAccessor = &next
+	next.columns = columns
+	next.dataSeparation = dataSeparationStateOf(columns)
+	next.rootAddress = nullAddress
+	// データを組み直す前に新しいテーブル仕様が保存できる大きさかを確認しておく
+	next.columnsSpecBuf, err = next.encodeSpec()
+	if err != nil {
+		return
+	}
+	var (
+		records  []tableTreeValue
+		keys     []avltree.Key
+		versions []uint64
+	)
+	err = table.IterateAll(func(r *Record) (_ bool) {
+		record := make(tableTreeValue, len(columns)+1)
+		record[table.key.Name()] = r.data[table.key.Name()]
+		for _, col := range columns {
+			if value, ok := r.data[col.Name()]; ok {
+				record[col.Name()] = value
+			} else {
+				record[col.Name()] = col.copyValue(zeroValues[col.Name()])
+			}
+		}
+		records = append(records, record)
+		keys = append(keys, table.getKey(record))
+		versions = append(versions, r.version)
+		return
+	})
+	if err != nil {
+		return
+	}
+	if len(records) > 0 {
+		// 組み立てたノードはすぐに書き込むのでキャッシュを使わない木にする
+		var tree *tableTree
+		tree, err = newTableTree(&next, true)
+		if err != nil {
+			return
+		}
+		var root *tableTreeNode
+		root, err = tree.buildBalancedTree(keys, records, versions)
+		if err != nil {
+			return
+		}
+		next.rootAddress = root.position()
+		next.nodeCount = len(records)
+	}
+	// ここまでは元の木の領域には何も書き込んでいない
+	prev := *table
+	table.columns = next.columns
+	table.dataSeparation = next.dataSeparation
+	table.columnsSpecBuf = next.columnsSpecBuf
+	table.rootAddress = next.rootAddress
+	table.nodeCount = next.nodeCount
+	err = table.flush()
+	if err != nil {
+		*table = prev
+		return
+	}
+	// 新しい木に切り替わったので古い木のノードの領域を解放する
+	old := prev
+	old.rootAccessor = &old
+	var oldTree *tableTree
+	oldTree, err = newTableTree(&old, false)
+	if err != nil {
+		return
+	}
+	defer oldTree.invalidateCacheOnAbort(&err)
+	avltree.Clear(oldTree)
+	err = oldTree.flush()
+	return
+}
@@ -0,0 +1,488 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTable_CheckStruct(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Book struct {
+		Id     CounterType `unkodb:"id,key@Counter"`
+		Title  string      `unkodb:"title,ShortString"`
+		Author string      `unkodb:"author,ShortString"`
+		Code   [4]byte     `unkodb:"code,FixedSizeShortBytes[4]"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("books", (*Book)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := table.CheckStruct((*Book)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.IsEmpty() {
+		t.Fatalf("wrong diff %v", diff)
+	}
+
+	type ChangedBook struct {
+		Id     CounterType `unkodb:"id,key@Counter"`
+		Title  string      `unkodb:"title,LongString"`
+		Price  int64       `unkodb:"price,Int64"`
+		Code   [8]byte     `unkodb:"code,FixedSizeShortBytes[8]"`
+		Genre  string      `unkodb:"genre,ShortString"`
+		Unused string
+	}
+
+	diff, err = table.CheckStruct(ChangedBook{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &StructDiff{
+		MissingColumns: []string{"author"},
+		ExtraColumns:   []string{"price", "genre"},
+		UnmatchColumns: []string{"title", "code"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Fatalf("unmatch %v %v", diff, expected)
+	}
+
+	type KeyChangedBook struct {
+		Id     int64       `unkodb:"id,Int64"`
+		Number CounterType `unkodb:"number,key@Counter"`
+		Title  string      `unkodb:"title,ShortString"`
+		Author string      `unkodb:"author,ShortString"`
+		Code   [4]byte     `unkodb:"code,FixedSizeShortBytes[4]"`
+	}
+
+	diff, err = table.CheckStruct((*KeyChangedBook)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = &StructDiff{
+		ExtraColumns:   []string{"number"},
+		UnmatchColumns: []string{"id"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Fatalf("unmatch %v %v", diff, expected)
+	}
+
+	_, err = table.CheckStruct(123)
+	if _, ok := err.(*ErrWrongTag); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	type NoKey struct {
+		Title string `unkodb:"title,ShortString"`
+	}
+	_, err = table.CheckStruct((*NoKey)(nil))
+	if err != ErrNotFoundKey {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestUnkoDB_EnsureTableByTaggedStruct(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Book struct {
+		Id    CounterType `unkodb:"id,key@Counter"`
+		Title string      `unkodb:"title,ShortString"`
+	}
+
+	table, err := db.EnsureTableByTaggedStruct("books", (*Book)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Table("books") != table {
+		t.Fatal("table is not created")
+	}
+
+	const N = 50
+	for i := 1; i <= N; i++ {
+		_, err = table.Insert(&Book{Title: fmt.Sprint("book", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = table.Delete(CounterType(N))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	same, err := db.EnsureTableByTaggedStruct("books", (*Book)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same != table {
+		t.Fatal("wrong table")
+	}
+
+	type NewBook struct {
+		Id    CounterType `unkodb:"id,key@Counter"`
+		Title string      `unkodb:"title,ShortString"`
+		Price int64       `unkodb:"price,Int64"`
+		Memo  string      `unkodb:"memo,Text"`
+		Code  [4]byte     `unkodb:"code,FixedSizeShortBytes[4]"`
+	}
+
+	migrated, err := db.EnsureTableByTaggedStruct("books", (*NewBook)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != table {
+		t.Fatal("wrong table")
+	}
+
+	check := func(table *Table, newKey CounterType) {
+		if table.Count() != N-1 {
+			t.Fatalf("wrong count %d", table.Count())
+		}
+		id := 1
+		err := table.IterateAll(func(r *Record) (_ bool) {
+			var book NewBook
+			if err := r.MoveTo(&book); err != nil {
+				t.Fatal(err)
+			}
+			if book.Id != CounterType(id) || book.Title != fmt.Sprint("book", id) ||
+				book.Price != 0 || book.Memo != "" || book.Code != [4]byte{} {
+				t.Fatalf("wrong book %#v", book)
+			}
+			id++
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 削除済みのキーは再利用されない
+		r, err := table.Insert(&NewBook{Title: "new book", Price: 1000, Memo: strings.Repeat("memo", 100)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Key() != any(newKey) {
+			t.Fatalf("wrong key %#v", r.Key())
+		}
+		err = table.Delete(r.Key())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check(table, N+1)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("books")
+	diff, err := table.CheckStruct((*NewBook)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.IsEmpty() {
+		t.Fatalf("wrong diff %v", diff)
+	}
+	check(table, N+2)
+
+	type IncompatibleBook struct {
+		Id    CounterType `unkodb:"id,key@Counter"`
+		Title string      `unkodb:"title,ShortString"`
+		Price int64       `unkodb:"price,Int64"`
+	}
+	_, err = db.EnsureTableByTaggedStruct("books", (*IncompatibleBook)(nil))
+	if err != ErrIncompatibleStruct {
+		t.Fatalf("wrong error %v", err)
+	}
+	if table.Count() != N-1 {
+		t.Fatalf("wrong count %d", table.Count())
+	}
+}
+
+func TestUnkoDB_EnsureTableByTaggedStruct_rowVersion(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Int64Key("id")
+	tc.ShortStringColumn("body")
+	tc.EnableRowVersion()
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10; i++ {
+		_, err = table.Insert(map[string]any{"id": int64(i), "body": "note"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k := 1; k < i; k++ {
+			_, err = table.Replace(map[string]any{"id": int64(i), "body": "note"})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	type Note struct {
+		Id    int64  `unkodb:"id,key@Int64"`
+		Body  string `unkodb:"body,ShortString"`
+		Score uint16 `unkodb:"score,Uint16"`
+	}
+	_, err = db.EnsureTableByTaggedStruct("notes", (*Note)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !table.RowVersionEnabled() {
+		t.Fatal("row version is disabled")
+	}
+	err = table.IterateAll(func(r *Record) (_ bool) {
+		if r.Version() != uint64(r.Key().(int64)) {
+			t.Fatalf("wrong version %d (key: %v)", r.Version(), r.Key())
+		}
+		if r.Column("score") != any(uint16(0)) {
+			t.Fatalf("wrong score %#v", r.Column("score"))
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 指定した回数を超えてEncodeが呼ばれるとエラーを返すColumnCodec
+type testFailingPointCodec struct {
+	testPointCodec
+	remaining *int
+}
+
+func (c testFailingPointCodec) Encode(value any) ([]byte, error) {
+	if *c.remaining <= 0 {
+		return nil, fmt.Errorf("injected failure")
+	}
+	*c.remaining--
+	return c.testPointCodec.Encode(value)
+}
+
+// 長さ0のバイト列のDecodeでエラーを返すColumnCodec
+type testNoZeroPointCodec struct {
+	testPointCodec
+}
+
+func (testNoZeroPointCodec) Decode(data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no zero value")
+	}
+	return testPointCodec{}.Decode(data)
+}
+
+func TestUnkoDB_EnsureTableByTaggedStruct_failure(t *testing.T) {
+	remaining := 0
+	if err := RegisterColumnType("TestFailingPoint", testFailingPointCodec{remaining: &remaining}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(columnCodecs, "TestFailingPoint")
+	if err := RegisterColumnType("TestNoZeroPoint", testNoZeroPointCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(columnCodecs, "TestNoZeroPoint")
+
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Spot struct {
+		Id   int64  `unkodb:"id,key@Int64"`
+		Name string `unkodb:"name,ShortString"`
+	}
+	table, err := db.CreateTableByTaggedStruct("spots", (*Spot)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const N = 20
+	for i := 1; i <= N; i++ {
+		_, err = table.Insert(&Spot{Id: int64(i), Name: fmt.Sprint("spot", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(table *Table) {
+		if len(table.Columns()) != 1 {
+			t.Fatalf("wrong columns %v", table.Columns())
+		}
+		if table.Count() != N {
+			t.Fatalf("wrong count %d", table.Count())
+		}
+		for i := 1; i <= N; i++ {
+			r, err := table.Find(int64(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r == nil || r.Column("name") != fmt.Sprint("spot", i) {
+				t.Fatalf("wrong record %v (id: %d)", r, i)
+			}
+		}
+	}
+
+	type FailingSpot struct {
+		Id   int64     `unkodb:"id,key@Int64"`
+		Name string    `unkodb:"name,ShortString"`
+		Pos  testPoint `unkodb:"pos,Custom[TestFailingPoint]"`
+	}
+	// 既存のデータの読み込み(ゼロ値のコピー)は通し、木の組み直しの途中でEncodeを失敗させる
+	remaining = N + N/2
+	_, err = db.EnsureTableByTaggedStruct("spots", (*FailingSpot)(nil))
+	if err == nil || err.Error() != "injected failure" {
+		t.Fatalf("wrong error %v", err)
+	}
+	check(table)
+
+	// 組み直しの途中で割り当てた領域は解放されているので同じ失敗を繰り返してもファイルは大きくならない
+	next := db.segManager.file.NextNewSegmentAddress()
+	remaining = N + N/2
+	_, err = db.EnsureTableByTaggedStruct("spots", (*FailingSpot)(nil))
+	if err == nil || err.Error() != "injected failure" {
+		t.Fatalf("wrong error %v", err)
+	}
+	check(table)
+	if db.segManager.file.NextNewSegmentAddress() != next {
+		t.Fatalf("wrong NextNewSegmentAddress %d (want %d)", db.segManager.file.NextNewSegmentAddress(), next)
+	}
+
+	type NoZeroSpot struct {
+		Id   int64     `unkodb:"id,key@Int64"`
+		Name string    `unkodb:"name,ShortString"`
+		Pos  testPoint `unkodb:"pos,Custom[TestNoZeroPoint]"`
+	}
+	_, err = db.EnsureTableByTaggedStruct("spots", (*NoZeroSpot)(nil))
+	if err == nil || err.Error() != "no zero value" {
+		t.Fatalf("wrong error %v", err)
+	}
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("spots")
+	check(table)
+
+	remaining = math.MaxInt
+	table, err = db.EnsureTableByTaggedStruct("spots", (*FailingSpot)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.IterateAll(func(r *Record) (_ bool) {
+		if r.Column("pos") != (testPoint{}) {
+			t.Fatalf("wrong pos %v", r.Column("pos"))
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Count() != N {
+		t.Fatalf("wrong count %d", table.Count())
+	}
+}
+
+func TestTable_migrate_invalidColumns(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.ShortStringColumn("name")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = table.Insert(map[string]any{"name": "foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	name := table.Column("name")
+	if err = table.migrate([]Column{name, &intColumn[int8]{name: "name"}}); err != ErrColumnNameAlreadyExists {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = table.migrate([]Column{name, &intColumn[int8]{name: "id"}}); err != ErrColumnNameAlreadyExists {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = table.migrate([]Column{name, &intColumn[int8]{name: ""}}); err != ErrNeedColumnName {
+		t.Fatalf("wrong error %v", err)
+	}
+	columns := []Column{name}
+	for i := 0; i < MaximumColumnCountWithoutKey; i++ {
+		columns = append(columns, &intColumn[int8]{name: fmt.Sprint("c", i)})
+	}
+	if err = table.migrate(columns); err != ErrColumnCountIsFull {
+		t.Fatalf("wrong error %v", err)
+	}
+	if len(table.Columns()) != 1 {
+		t.Fatalf("wrong columns %v", table.Columns())
+	}
+	r, err := table.Find(CounterType(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil || r.Column("name") != "foo" {
+		t.Fatalf("wrong record %v", r)
+	}
+}
@@ -36,6 +36,14 @@ func (seg *segmentBuffer) Clear() {
 	fillBytes(seg.Buffer(), 0)
 }
 
+// 同じ内容の別のインスタンスを返す
+func (seg *segmentBuffer) Clone() *segmentBuffer {
+	other := *seg
+	other.buffer = make([]byte, len(seg.buffer))
+	copy(other.buffer, seg.buffer)
+	return &other
+}
+
 // bufferの内容をファイルに書き込む
 func (seg *segmentBuffer) Flush() error {
 	err := seg.file.Write(seg.position, seg.buffer)
@@ -0,0 +1,137 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"container/list"
+
+	"github.com/neetsdkasu/avltree"
+)
+
+// ファイルから読み込んだセグメントとデコード済みのテーブルのノードを操作をまたいで保持するLRUキャッシュ
+// キャッシュにはファイルの内容と一致するセグメントだけを保持する
+// キャッシュのセグメントは読み込み専用の木で共有し、書き換える木では複製して使う
+// 書き換えたセグメントはファイルへの書き込みに成功した後に複製をキャッシュに入れ直す
+// セグメントが解放されたり再割り当てされたり、書き込みが中断された場合はキャッシュから除去する必要がある
+type segmentCache struct {
+	budget   int
+	byteSize int
+	order    *list.List
+	entries  map[int]*list.Element
+}
+
+type segmentCacheEntry struct {
+	seg      *segmentBuffer
+	node     *cachedTableNode
+	byteSize int
+}
+
+// キャッシュに保持するデコード済みのテーブルのノードのヘッダとキー
+type cachedTableNode struct {
+	table                 *Table
+	key                   avltree.Key
+	leftChildAddress      int
+	rightChildAddress     int
+	height                int
+	separationDataAddress int
+	version               uint64
+}
+
+// デコード済みのノードのメモリの使用量の目安(バイト数)
+const cachedTableNodeByteSize = 64
+
+func newSegmentCache(budget int) *segmentCache {
+	return &segmentCache{
+		budget:   budget,
+		byteSize: 0,
+		order:    list.New(),
+		entries:  make(map[int]*list.Element),
+	}
+}
+
+func (cache *segmentCache) Get(position int) (*segmentBuffer, bool) {
+	elem, ok := cache.entries[position]
+	if !ok {
+		return nil, false
+	}
+	cache.order.MoveToFront(elem)
+	return elem.Value.(*segmentCacheEntry).seg, true
+}
+
+// デコード済みのノードも保持している場合はそれも返す(ない場合はnil)
+func (cache *segmentCache) GetNode(position int) (*segmentBuffer, *cachedTableNode, bool) {
+	elem, ok := cache.entries[position]
+	if !ok {
+		return nil, nil, false
+	}
+	cache.order.MoveToFront(elem)
+	entry := elem.Value.(*segmentCacheEntry)
+	return entry.seg, entry.node, true
+}
+
+// キャッシュにあるセグメントにデコード済みのノードを付ける
+func (cache *segmentCache) SetNode(position int, node *cachedTableNode) {
+	elem, ok := cache.entries[position]
+	if !ok {
+		return
+	}
+	entry := elem.Value.(*segmentCacheEntry)
+	if entry.node == nil {
+		entry.byteSize += cachedTableNodeByteSize
+		cache.byteSize += cachedTableNodeByteSize
+	}
+	entry.node = node
+	cache.shrink()
+}
+
+func (cache *segmentCache) Add(seg *segmentBuffer) {
+	if seg.partial {
+		bug.Panic("segmentCache.Add: partial segment")
+	}
+	// 古い内容のセグメントが残らないように上限を超える場合も除去はしておく
+	cache.Remove(seg.Position())
+	byteSize := len(seg.buffer)
+	if byteSize > cache.budget {
+		return
+	}
+	entry := &segmentCacheEntry{
+		seg:      seg,
+		byteSize: byteSize,
+	}
+	cache.entries[seg.Position()] = cache.order.PushFront(entry)
+	cache.byteSize += byteSize
+	cache.shrink()
+}
+
+func (cache *segmentCache) Remove(position int) {
+	elem, ok := cache.entries[position]
+	if !ok {
+		return
+	}
+	cache.order.Remove(elem)
+	delete(cache.entries, position)
+	cache.byteSize -= elem.Value.(*segmentCacheEntry).byteSize
+}
+
+func (cache *segmentCache) SetBudget(budget int) {
+	cache.budget = budget
+	cache.shrink()
+}
+
+func (cache *segmentCache) Clear() {
+	cache.byteSize = 0
+	cache.order.Init()
+	cache.entries = make(map[int]*list.Element)
+}
+
+// 上限を超えている間、最も古く参照されたセグメントから除去する
+func (cache *segmentCache) shrink() {
+	for cache.byteSize > cache.budget {
+		elem := cache.order.Back()
+		if elem == nil {
+			bug.Panic("segmentCache.shrink: broken byteSize")
+		}
+		cache.Remove(elem.Value.(*segmentCacheEntry).seg.Position())
+	}
+}
@@ -0,0 +1,257 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSegmentCache(t *testing.T) {
+	newSeg := func(position, size int) *segmentBuffer {
+		return &segmentBuffer{
+			position:    position,
+			buffer:      make([]byte, size),
+			segmentSize: size,
+		}
+	}
+
+	cache := newSegmentCache(100)
+
+	cache.Add(newSeg(10, 40))
+	cache.Add(newSeg(50, 40))
+	if _, ok := cache.Get(10); !ok {
+		t.Fatal("not found 10")
+	}
+
+	// 最も古く参照された50が除去される
+	cache.Add(newSeg(90, 40))
+	if _, ok := cache.Get(50); ok {
+		t.Fatal("found 50")
+	}
+	if _, ok := cache.Get(10); !ok {
+		t.Fatal("not found 10")
+	}
+	if cache.byteSize != 80 {
+		t.Fatalf("wrong byteSize %d", cache.byteSize)
+	}
+
+	// 上限より大きいセグメントはキャッシュしない
+	cache.Add(newSeg(200, 101))
+	if _, ok := cache.Get(200); ok {
+		t.Fatal("found 200")
+	}
+
+	cache.Remove(10)
+	if _, ok := cache.Get(10); ok {
+		t.Fatal("found 10")
+	}
+	if cache.byteSize != 40 {
+		t.Fatalf("wrong byteSize %d", cache.byteSize)
+	}
+
+	cache.SetBudget(0)
+	if len(cache.entries) != 0 || cache.byteSize != 0 {
+		t.Fatalf("wrong cache %d %d", len(cache.entries), cache.byteSize)
+	}
+}
+
+func TestUnkoDB_SetCacheByteSize(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.LongStringColumn("body")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const N = 200
+	for i := 1; i <= N; i++ {
+		_, err = table.Insert(map[string]any{"body": fmt.Sprint("note", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i <= N; i++ {
+		_, err = table.Find(CounterType(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(db.segManager.cache.entries) == 0 {
+		t.Fatal("no cached segment")
+	}
+
+	// キャッシュしたセグメントの書き換えや解放が反映されること
+	for i := 1; i <= N; i++ {
+		if i%3 == 0 {
+			err = table.Delete(CounterType(i))
+		} else {
+			_, err = table.Update(CounterType(i), map[string]any{"body": fmt.Sprint("updated note", i)})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i <= N/3; i++ {
+		_, err = table.Insert(map[string]any{"body": fmt.Sprint("new note", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	collect := func(table *Table) (list []string) {
+		err := table.IterateAll(func(r *Record) (_ bool) {
+			list = append(list, fmt.Sprint(r.Key(), r.Column("body")))
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected := collect(table)
+	if len(expected) != N {
+		t.Fatalf("wrong count %d", len(expected))
+	}
+
+	db.SetCacheByteSize(0)
+	if len(db.segManager.cache.entries) != 0 {
+		t.Fatal("cache is not cleared")
+	}
+	if len(db.segManager.tree.cache) != 0 {
+		t.Fatal("idle tree cache is not cleared")
+	}
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := collect(db.Table("notes"))
+	if fmt.Sprint(expected) != fmt.Sprint(actual) {
+		t.Fatalf("unmatch\n%v\n%v", expected, actual)
+	}
+}
+
+func TestSegmentCache_tableNode(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.LongStringColumn("body")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const N = 50
+	for i := 1; i <= N; i++ {
+		_, err = table.Insert(map[string]any{"body": fmt.Sprint("note", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 書き込んだノードはデコード済みのノードとしてもキャッシュされる
+	root, err := table.getRootAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg, cached, ok := db.segManager.cache.GetNode(root)
+	if !ok || cached == nil || cached.table != table {
+		t.Fatalf("not cached root node %v %#v", ok, cached)
+	}
+
+	// 書き換える木はキャッシュのセグメントの複製を使う
+	tree, err := newTableTree(table, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := tree.loadNode(root)
+	if node.seg == seg {
+		t.Fatal("writable tree shares cached segment")
+	}
+	if node.key.CompareTo(cached.key) != 0 || node.height != cached.height {
+		t.Fatalf("wrong node %#v %#v", node, cached)
+	}
+
+	// 書き込みが中断された場合は触れたノードのセグメントがキャッシュから除去される
+	node.updated = true
+	var noErr error
+	tree.invalidateCacheOnAbort(&noErr)
+	if _, _, ok := db.segManager.cache.GetNode(root); ok {
+		t.Fatal("not invalidated root node")
+	}
+	if _, err := table.Find(CounterType(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := db.segManager.cache.GetNode(root); !ok {
+		t.Fatal("not cached root node")
+	}
+	tree, err = newTableTree(table, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.loadNode(root)
+	abortErr := ErrNotFoundKey
+	tree.invalidateCacheOnAbort(&abortErr)
+	if _, _, ok := db.segManager.cache.GetNode(root); ok {
+		t.Fatal("not invalidated root node")
+	}
+
+	// Batchの中の書き込み前の変更はキャッシュを経由してTableのFindに見えない
+	err = table.Batch(func(b *Batch) error {
+		_, err := b.Replace(map[string]any{"id": CounterType(10), "body": "replaced"})
+		if err != nil {
+			return err
+		}
+		r, err := table.Find(CounterType(10))
+		if err != nil {
+			return err
+		}
+		if r.Column("body") != "note10" {
+			return fmt.Errorf("wrong body %#v", r.Column("body"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := table.Find(CounterType(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("body") != "replaced" {
+		t.Fatalf("wrong body %#v", r.Column("body"))
+	}
+}
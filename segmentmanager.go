@@ -8,26 +8,86 @@ import (
 )
 
 type segmentManager struct {
-	file *fileAccessor
-	tree *idleSegmentTree
+	file  *fileAccessor
+	tree  *idleSegmentTree
+	cache *segmentCache
+	// Batchの間は空き領域の木の書き込みを終了時まで遅らせる
+	batching int
 }
 
 func newSegmentManager(file *fileAccessor) *segmentManager {
 	manager := &segmentManager{
-		file: file,
-		tree: newIdleSegmentTree(file),
+		file:  file,
+		tree:  newIdleSegmentTree(file),
+		cache: newSegmentCache(DefaultCacheByteSize),
 	}
 	return manager
 }
 
 func (manager *segmentManager) LoadSegment(addr int) (*segmentBuffer, error) {
-	return manager.file.ReadSegment(addr)
+	if seg, ok := manager.cache.Get(addr); ok {
+		return seg, nil
+	}
+	seg, err := manager.file.ReadSegment(addr)
+	if err != nil {
+		return nil, err
+	}
+	manager.cache.Add(seg)
+	return seg, nil
+}
+
+// 書き換えるためにセグメントを読み込む
+// キャッシュのセグメントはファイルの内容と一致させておくためキャッシュにある場合は複製を返す
+func (manager *segmentManager) LoadWritableSegment(addr int) (*segmentBuffer, error) {
+	seg, err := manager.LoadSegment(addr)
+	if err != nil {
+		return nil, err
+	}
+	return seg.Clone(), nil
 }
 
+// 書き換えるためにセグメントの一部を読み込む
+// キャッシュにある場合は全体の複製を返す
 func (manager *segmentManager) LoadPartialSegment(addr int, size int) (*segmentBuffer, error) {
+	if seg, ok := manager.cache.Get(addr); ok {
+		return seg.Clone(), nil
+	}
 	return manager.file.ReadPartialSegment(addr, size)
 }
 
+// ファイルに書き込んだセグメントの複製をキャッシュに入れる
+// nodeがnilでない場合はデコード済みのノードとしてセグメントに付ける
+func (manager *segmentManager) CacheFlushedSegment(seg *segmentBuffer, node *cachedTableNode) {
+	if seg.partial {
+		// 一部しか読み込んでいないセグメントはキャッシュしない
+		manager.cache.Remove(seg.Position())
+		return
+	}
+	manager.cache.Add(seg.Clone())
+	if node != nil {
+		manager.cache.SetNode(seg.Position(), node)
+	}
+}
+
+// ファイルの内容と一致しなくなった可能性のあるセグメントをキャッシュから除去する
+func (manager *segmentManager) InvalidateSegment(addr int) {
+	manager.cache.Remove(addr)
+}
+
+// キャッシュに使うメモリの上限(バイト数)を設定する
+func (manager *segmentManager) SetCacheByteSize(byteSize int) {
+	manager.cache.SetBudget(maxValue(byteSize, 0))
+	if !manager.keepIdleTreeCache() && manager.batching == 0 {
+		manager.tree.clearCache()
+	}
+}
+
+// 空き領域の木のノードのキャッシュを残しておけるか
+func (manager *segmentManager) keepIdleTreeCache() bool {
+	const nodeByteSize = segmentHeaderByteSize + idleSegmentTreeNodeDataByteSize
+	return len(manager.tree.cache)*nodeByteSize <= manager.cache.budget
+}
+
 func (manager *segmentManager) EmptySegment(byteSize uint64) (*segmentBuffer, error) {
 	byteSize = (byteSize + 3) &^ 3
 	if byteSize < minimumSegmentByteSize {
@@ -67,6 +127,7 @@ func (manager *segmentManager) EmptySegment(byteSize uint64) (*segmentBuffer, er
 		if !ok {
 			bug.Panicf("segmentManager.Request: not segmentBuffer %T %#v", nodes[0], nodes[0])
 		}
+		manager.cache.Remove(seg.Position())
 		other, err := seg.Split(int(byteSize))
 		if err != nil {
 			// どこからも参照のない迷子セグメントになる・・・？
@@ -86,19 +147,20 @@ func (manager *segmentManager) EmptySegment(byteSize uint64) (*segmentBuffer, er
 	if !ok {
 		bug.Panicf("segmentManager.Request: not segmentBuffer %T %#v", nodes[0], nodes[0])
 	}
+	manager.cache.Remove(seg.Position())
 	err := seg.LoadFullSegment()
 	if err != nil {
 		return nil, err
 	}
-	err = manager.tree.flush()
+	err = manager.flushIdleTree()
 	if err != nil {
 		return nil, err
 	}
-	manager.tree.clearCache()
 	return seg, nil
 }
 
 func (manager *segmentManager) ReleaseSegmentByAddress(segmentAddress int) error {
+	manager.cache.Remove(segmentAddress)
 	seg, err := manager.file.ReadPartialSegment(segmentAddress, idleSegmentTreeNodeDataByteSize)
 	if err != nil {
 		return err
@@ -108,11 +170,27 @@ func (manager *segmentManager) ReleaseSegmentByAddress(segmentAddress int) error
 	if !ok {
 		bug.Panic("segmentManager.Release: cann not insert free segment")
 	}
-	err = manager.tree.flush()
+	return manager.flushIdleTree()
+}
+
+// 複数のセグメントを空き領域にしてから空き領域の木をまとめて書き込む
+func (manager *segmentManager) ReleaseSegmentsByAddress(addresses []int) error {
+	err := manager.releaseSegmentsByAddress(addresses)
 	if err != nil {
 		return err
 	}
-	manager.tree.clearCache()
+	return manager.flushIdleTree()
+}
+
+func (manager *segmentManager) releaseSegmentsByAddress(addresses []int) error {
+	manager.beginBatch()
+	defer manager.endBatch()
+	for _, addr := range addresses {
+		err := manager.ReleaseSegmentByAddress(addr)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -120,15 +198,34 @@ func (manager *segmentManager) ReleaseSegment(seg *segmentBuffer) error {
 	if len(seg.Buffer()) < idleSegmentTreeNodeDataByteSize {
 		bug.Panic("segmentManager.Release: invalid segment size")
 	}
+	manager.cache.Remove(seg.Position())
 	key := idleSegmentTreeKey(int32(seg.Size()))
 	_, ok := avltree.Insert(manager.tree, false, key, seg)
 	if !ok {
 		bug.Panic("segmentManager.Release: cann not insert free segment")
 	}
+	return manager.flushIdleTree()
+}
+
+func (manager *segmentManager) flushIdleTree() error {
+	if manager.batching > 0 {
+		return nil
+	}
 	err := manager.tree.flush()
 	if err != nil {
 		return err
 	}
-	manager.tree.clearCache()
+	// 木の上の方のノードを読み直さずに済むように、上限に収まる間はキャッシュを残しておく
+	if !manager.keepIdleTreeCache() {
+		manager.tree.clearCache()
+	}
 	return nil
 }
+
+func (manager *segmentManager) beginBatch() {
+	manager.batching++
+}
+
+func (manager *segmentManager) endBatch() {
+	manager.batching--
+}
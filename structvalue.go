@@ -0,0 +1,105 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"reflect"
+)
+
+// Structのカラムの値を参照する。
+// RecordのColumnやColumnsでStructのカラムの値として返される。
+// InsertやReplaceなどのデータのStructのカラムの値としてそのまま渡すこともできる。
+type StructValue struct {
+	col  *structColumn
+	data map[string]any
+}
+
+// Structのカラムの値のマップをStructValueにする(それ以外のカラムの値はそのまま返す)
+func wrapStructValue(col Column, value any) any {
+	if m, ok := value.(map[string]any); ok {
+		if c, ok := col.(*structColumn); ok {
+			return &StructValue{col: c, data: m}
+		}
+	}
+	return value
+}
+
+// Structのカラムの値のマップを取り出す(StructValueの場合は保持しているマップを返す)
+func structValueMap(value any) (m map[string]any, ok bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		m, ok = v, v != nil
+	case *StructValue:
+		if v != nil {
+			m, ok = v.data, true
+		}
+	}
+	return
+}
+
+// 指定サブカラム名のサブカラムの値を参照する。
+// 存在しないサブカラム名の場合はnilが返る。
+// サブカラムがStructの場合はStructValueが返る。
+//
+//	r, _ := table.Find(unkodb.CounterType(123))
+//	address := r.Column("address").(*unkodb.StructValue)
+//	fmt.Println("city=", address.Column("city"))
+func (v *StructValue) Column(name string) any {
+	if value, ok := v.data[name]; ok {
+		return wrapStructValue(v.col.column(name), value)
+	} else {
+		return nil
+	}
+}
+
+// サブカラムの値を参照するリストを返す。
+// 値の順序はサブカラムの定義の順序と同じ。
+func (v *StructValue) Columns() []any {
+	list := make([]any, len(v.col.columns))
+	for i, sub := range v.col.columns {
+		list[i] = wrapStructValue(sub, v.data[sub.Name()])
+	}
+	return list
+}
+
+// サブカラムの値をdstにコピーする。
+// dstにはmap[string]anyか、サブカラムに対応したunkodbタグ付きの構造体のポインタを指定する。
+// 引数のdstに対応できない型などが渡された場合はエラーが返る。
+//
+//	r, _ := table.Find(unkodb.CounterType(123))
+//	var address Address
+//	r.Column("address").(*unkodb.StructValue).CopyTo(&address)
+func (v *StructValue) CopyTo(dst any) (err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	switch d := dst.(type) {
+	case map[string]any:
+		if d == nil {
+			// TODO 適切なエラーに直す
+			err = ErrNotFoundData
+			return
+		}
+		for name, value := range v.col.copyValue(v.data).(map[string]any) {
+			d[name] = value
+		}
+		return
+	case *map[string]any:
+		if d == nil {
+			// TODO 適切なエラーに直す
+			err = ErrNotFoundData
+			return
+		}
+		*d = v.col.copyValue(v.data).(map[string]any)
+		return
+	}
+	fv := reflect.ValueOf(dst)
+	if fv.Kind() != reflect.Pointer || fv.IsNil() || fv.Elem().Kind() != reflect.Struct {
+		// TODO 適切なエラーに直す
+		err = ErrNotFoundData
+		return
+	}
+	err = setStructFields(fv.Elem(), v.data, v.col, true)
+	return
+}
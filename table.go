@@ -4,6 +4,14 @@
 package unkodb
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+
 	"github.com/neetsdkasu/avltree"
 )
 
@@ -25,18 +33,143 @@ func (dss dataSeparationState) Enabled() bool {
 	return dss == dataSeparationEnabled
 }
 
+// テーブル作成時に指定できる追加の設定
+// テーブル仕様のカラム情報の後ろに設定されたものだけが書き込まれる
+type tableOptions struct {
+	rowVersion    bool
+	keyCollation  Collation
+	descendingKey bool
+	counterStart  uint64 // 0の場合は1とする
+	counterStep   uint64 // 0の場合は1とする
+	counterHigh   uint32 // 読み込み時のみ使う(書き込みはencodeSpecで行う)
+}
+
+// カウンターの開始値
+func (options *tableOptions) counterStartValue() uint64 {
+	if options.counterStart == 0 {
+		return 1
+	}
+	return options.counterStart
+}
+
+// カウンターの増分
+func (options *tableOptions) counterStepValue() uint64 {
+	if options.counterStep == 0 {
+		return 1
+	}
+	return options.counterStep
+}
+
+func (options *tableOptions) write(w *byteEncoder) (err error) {
+	if options.rowVersion {
+		err = w.Uint8(tableSpecOptionRowVersion)
+		if err != nil {
+			return
+		}
+	}
+	if options.descendingKey {
+		err = w.Uint8(tableSpecOptionDescendingKey)
+		if err != nil {
+			return
+		}
+	}
+	if options.keyCollation != BinaryCollation {
+		err = w.Uint8(tableSpecOptionKeyCollation)
+		if err != nil {
+			return
+		}
+		err = w.Uint8(uint8(options.keyCollation))
+		if err != nil {
+			return
+		}
+	}
+	if options.counterStartValue() != 1 {
+		err = w.Uint8(tableSpecOptionCounterStart)
+		if err != nil {
+			return
+		}
+		err = w.Uint64(options.counterStart)
+		if err != nil {
+			return
+		}
+	}
+	if options.counterStepValue() != 1 {
+		err = w.Uint8(tableSpecOptionCounterStep)
+		if err != nil {
+			return
+		}
+		err = w.Uint64(options.counterStep)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func readTableOptions(r *byteDecoder) (options tableOptions, err error) {
+	for {
+		var optionId uint8
+		err = r.Uint8(&optionId)
+		if err == io.EOF {
+			// 古いファイルではオプションの情報自体が存在しない
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		switch optionId {
+		default:
+			err = &ErrWrongFileFormat{fmt.Sprintf("unknown table option (%d)", optionId)}
+			return
+		case tableSpecOptionRowVersion:
+			options.rowVersion = true
+		case tableSpecOptionDescendingKey:
+			options.descendingKey = true
+		case tableSpecOptionCounterStart:
+			err = r.Uint64(&options.counterStart)
+			if err != nil {
+				return
+			}
+		case tableSpecOptionCounterStep:
+			err = r.Uint64(&options.counterStep)
+			if err != nil {
+				return
+			}
+		case tableSpecOptionCounterHigh:
+			err = r.Uint32(&options.counterHigh)
+			if err != nil {
+				return
+			}
+		case tableSpecOptionKeyCollation:
+			var collation uint8
+			err = r.Uint8(&collation)
+			if err != nil {
+				return
+			}
+			options.keyCollation = Collation(collation)
+			if !options.keyCollation.isValid() {
+				err = &ErrWrongFileFormat{fmt.Sprintf("unknown key collation (%d)", collation)}
+				return
+			}
+		}
+	}
+}
+
 type Table struct {
 	db             *UnkoDB
 	name           string
 	key            keyColumn
 	columns        []Column
 	nodeCount      int
-	counter        uint
+	counter        uint64 // 次に付与するキーの値-1(付与できる値がない場合はキーのカラム型の最大値)
 	columnsSpecBuf []byte
 	rootAddress    int
 	rootAccessor   rootAddressAccessor
 	dataSeparation dataSeparationState
+	options        tableOptions
 	iterating      int
+	batching       bool
 }
 
 // テーブル名を返す。
@@ -72,6 +205,30 @@ func (table *Table) Columns() []Column {
 	return columns
 }
 
+// データごとにバージョン番号を保持するテーブルかどうかを返す。
+func (table *Table) RowVersionEnabled() bool {
+	return table.options.rowVersion
+}
+
+// キーの照合順序を返す。
+// TableCreatorのSetKeyCollationを使わずに作成したテーブルの場合はBinaryCollationを返す。
+func (table *Table) KeyCollation() Collation {
+	return table.options.keyCollation
+}
+
+// キーの降順でデータを並べるテーブルかどうかを返す。
+func (table *Table) KeyDescending() bool {
+	return table.options.descendingKey
+}
+
+func (table *Table) nodeHeaderByteSize() int {
+	if table.options.rowVersion {
+		return tableTreeNodeHeaderByteSize + tableTreeNodeRowVersionLength
+	} else {
+		return tableTreeNodeHeaderByteSize
+	}
+}
+
 func (table *Table) getRootAddress() (addr int, err error) {
 	addr = table.rootAddress
 	return
@@ -86,6 +243,10 @@ func (table *Table) flush() (err error) {
 		// TODO たぶん tableList （バグチェックのために確認する処理あったほうがいいかも）
 		return
 	}
+	if table.batching {
+		// Batchの終了時にまとめて書き込む
+		return
+	}
 	buf := table.columnsSpecBuf[:tableSpecHeaderByteSize]
 	w := newByteEncoder(newByteSliceWriter(buf), fileByteOrder)
 	err = w.Int32(int32(table.rootAddress))
@@ -104,6 +265,15 @@ func (table *Table) flush() (err error) {
 	if err != nil {
 		return
 	}
+	if table.key.Type() == Counter64 {
+		// カウンターの上位32ビットはテーブルオプションの最後に置かれている
+		buf = table.columnsSpecBuf[len(table.columnsSpecBuf)-tableSpecCounterLength:]
+		w = newByteEncoder(newByteSliceWriter(buf), fileByteOrder)
+		err = w.Uint32(uint32(table.counter >> 32))
+		if err != nil {
+			return
+		}
+	}
 	data := make(map[string]any)
 	data[tableListKeyName] = table.name
 	data[tableListColumnName] = table.columnsSpecBuf
@@ -111,6 +281,79 @@ func (table *Table) flush() (err error) {
 	return
 }
 
+// テーブル仕様(ヘッダ、キーとカラムの情報、追加の設定)をバイト列にする
+func (table *Table) encodeSpec() ([]byte, error) {
+	var b bytes.Buffer
+	w := newByteEncoder(&b, fileByteOrder)
+	// tableSpecHeader
+	{
+		err := w.Int32(int32(table.rootAddress))
+		if err != nil {
+			return nil, err
+		}
+		err = w.Int32(int32(table.nodeCount))
+		if err != nil {
+			return nil, err
+		}
+		err = w.Uint32(uint32(table.counter))
+		if err != nil {
+			return nil, err
+		}
+		err = w.Uint8(uint8(table.dataSeparation))
+		if err != nil {
+			return nil, err
+		}
+	}
+	// tableSpecKeyAndColumns
+	{
+		err := w.WriteColumnSpec(table.key)
+		if err != nil {
+			return nil, err
+		}
+		if len(table.columns) < tableSpecExtendedColumnCount {
+			err = w.Uint8(uint8(len(table.columns)))
+		} else {
+			err = w.Uint8(tableSpecExtendedColumnCount)
+			if err != nil {
+				return nil, err
+			}
+			err = w.Uint16(uint16(len(table.columns)))
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range table.columns {
+			err = w.WriteColumnSpec(col)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	// tableSpecOptions
+	{
+		err := table.options.write(w)
+		if err != nil {
+			return nil, err
+		}
+		if table.key.Type() == Counter64 {
+			// flushで書き換えるので常に最後に置く
+			err = w.Uint8(tableSpecOptionCounterHigh)
+			if err != nil {
+				return nil, err
+			}
+			err = w.Uint32(uint32(table.counter >> 32))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	// テーブルリストのテーブルのカラム(LongBytes)に収める必要がある
+	if b.Len() > longBytesMaximumDataByteSize {
+		return nil, ErrTableSpecIsTooLarge
+	}
+	return b.Bytes(), nil
+}
+
 // InsertやReplaceに渡すデータにおいて各カラムのデータの型に問題にないかを確認をする(カラム情報のIsValidValueTypeメソッドで確認する)。
 // 引数のdataにはmap[string]anyもしくはunkodb.Dataもしくはunkodbタグを付けた構造体のインスタンスを渡す。
 // データ型に問題がある場合はErrUnmatchColumnValueTypeが返る。それ以外の問題がある場合はErrWrongTagなどのエラーが返る。
@@ -129,83 +372,967 @@ func (table *Table) CheckData(data any) (err error) {
 	if keyValue, ok := mdata[table.key.Name()]; !ok {
 		return &ErrNotFoundColumnName{table.key}
 	} else if !table.key.IsValidValueType(keyValue) {
-		return &ErrUnmatchColumnValueType{table.key}
+		return invalidValueError(table.key, keyValue)
 	}
 	for _, col := range table.columns {
 		if colValue, ok := mdata[col.Name()]; !ok {
 			return &ErrNotFoundColumnName{col}
 		} else if !col.IsValidValueType(colValue) {
-			return &ErrUnmatchColumnValueType{col}
+			return invalidValueError(col, colValue)
+		}
+	}
+	return nil
+}
+
+// IsValidValueTypeを満たさない値に対するエラーを返す
+// Enumのカラムに定義されていない文字列の場合はErrUnknownEnumValue、それ以外はErrUnmatchColumnValueTypeとなる
+func invalidValueError(col Column, value any) error {
+	if _, ok := col.(*enumColumn); ok {
+		if s, ok := value.(string); ok {
+			return &ErrUnknownEnumValue{col, s}
+		}
+	}
+	return &ErrUnmatchColumnValueType{col}
+}
+
+// キーの値をAVL木のキーにする
+// 降順のキーのテーブルでは順序を逆にしたキーにする
+func (table *Table) toKey(value any) avltree.Key {
+	key := table.key.toKey(value)
+	if table.options.descendingKey {
+		return descendingKey{key}
+	}
+	return key
+}
+
+// AVL木のキーからキーの値を取り出す
+func (table *Table) unwrapKey(key avltree.Key) any {
+	if k, ok := key.(descendingKey); ok {
+		key = k.key
+	}
+	return table.key.unwrapKey(key)
+}
+
+// キーの値の範囲の下限と上限をAVL木の順序での範囲にする
+// 降順のキーのテーブルでは下限と上限が入れ替わる
+func (table *Table) treeRange(lKey, rKey avltree.Key) (avltree.Key, avltree.Key) {
+	if table.options.descendingKey {
+		return rKey, lKey
+	}
+	return lKey, rKey
+}
+
+func (table *Table) getKey(data map[string]any) avltree.Key {
+	return table.toKey(data[table.key.Name()])
+}
+
+func equalColumnValue(a, b any) bool {
+	if x, ok := a.([]byte); ok {
+		if y, ok := b.([]byte); ok {
+			return bytes.Equal(x, y)
+		}
+		return false
+	}
+	if x, ok := a.(time.Time); ok {
+		if y, ok := b.(time.Time); ok {
+			return x.Equal(y)
+		}
+		return false
+	}
+	if x, ok := a.(Decimal); ok {
+		if y, ok := b.(Decimal); ok {
+			return x.Cmp(y) == 0
+		}
+		return false
+	}
+	// JSONは空白を除いた形で比較する
+	if x, ok := a.(json.RawMessage); ok {
+		if y, ok := b.(json.RawMessage); ok {
+			cx, _ := compactJSON(x)
+			cy, _ := compactJSON(y)
+			return bytes.Equal(cx, cy)
+		}
+		return false
+	}
+	if x, ok := structValueMap(a); ok {
+		if y, ok := structValueMap(b); ok && len(x) == len(y) {
+			for name, v := range x {
+				if w, ok := y[name]; !ok || !equalColumnValue(v, w) {
+					return false
+				}
+			}
+			return true
+		}
+		return false
+	}
+	// Listの値のスライスは==で比較できない(panicになる)ので要素ごとに比較する
+	if x := reflect.ValueOf(a); x.Kind() == reflect.Slice {
+		y := reflect.ValueOf(b)
+		if y.Kind() != reflect.Slice || x.Type() != y.Type() || x.Len() != y.Len() {
+			return false
+		}
+		for i := 0; i < x.Len(); i++ {
+			if !equalColumnValue(x.Index(i).Interface(), y.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	}
+	// 独自のカラム型の値など==で比較できない値はreflect.DeepEqualで比較する
+	if t := reflect.TypeOf(a); t != nil && !t.Comparable() {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
+
+// 指定したキーに対応するデータを取得する。
+// キーのカラム型に対応したGoの型で渡す必要がある。
+// 指定したキーに対応するデータが存在しない場合には戻り値は全てnilとなる。
+// キーの型が不正な場合は対応するエラーが返る。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+func (table *Table) Find(key any) (r *Record, err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var node *tableTreeNode
+	node, err = table.findNode(key)
+	if node != nil {
+		r = node.toRecord()
+	}
+	return
+}
+
+// Findの処理を行い見つかったノードを返す
+func (table *Table) findNode(key any) (node *tableTreeNode, err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var tree *tableTree
+	tree, err = newTableTree(table, true)
+	if err != nil {
+		return
+	}
+	node, err = table.findInTree(tree, key)
+	return
+}
+
+func (table *Table) find(tree *tableTree, key any) (r *Record, err error) {
+	var node *tableTreeNode
+	node, err = table.findInTree(tree, key)
+	if node != nil {
+		r = node.toRecord()
+	}
+	return
+}
+
+func (table *Table) findInTree(tree *tableTree, key any) (node *tableTreeNode, err error) {
+	key = table.normalizeKey(key)
+	if !table.key.IsValidValueType(key) {
+		err = &ErrUnmatchColumnValueType{table.key}
+		return
+	}
+	node = unwrapTableTreeNode(avltree.Find(tree, table.toKey(key)))
+	return
+}
+
+// FindやDeleteに渡されたキーをキーのカラム型に対応したGoの型の値にする
+// キーの値がそのまま渡された場合はparseData(リフレクション)を行わない
+func (table *Table) normalizeKey(key any) any {
+	if table.key.IsValidValueType(key) {
+		return key
+	}
+	// parseDataするのコスト高すぎる
+	if mdata, e := parseData(table, key); e == nil {
+		if k, ok := mdata[table.key.Name()]; ok {
+			return k
+		}
+	}
+	return key
+}
+
+// キーのカラム型に対応したGoの型の値のキーでデータを取得する
+// parseDataによる変換を行わない
+func (table *Table) findByKey(key any) (r *Record, err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	if !table.key.IsValidValueType(key) {
+		err = &ErrUnmatchColumnValueType{table.key}
+		return
+	}
+	var tree *tableTree
+	tree, err = newTableTree(table, true)
+	if err != nil {
+		return
+	}
+	node := avltree.Find(tree, table.toKey(key))
+	if node == nil {
+		return
+	}
+	r = unwrapTableTreeNode(node).toRecord()
+	return
+}
+
+// キーがCounterのテーブルでキーに対応するデータを取得する。
+// Findと異なり構造体などからキーを取り出す処理を行わないためFindより速い。
+// キーがCounterではないテーブルの場合はErrUnmatchColumnValueTypeのエラーが返る。
+// それ以外の戻り値やエラーはFindと同じ。
+//
+//	r, _ := table.FindCounter(123)
+func (table *Table) FindCounter(key CounterType) (r *Record, err error) {
+	return table.findByKey(key)
+}
+
+// キーが文字列型(ShortStringなど)のテーブルでキーに対応するデータを取得する。
+// Findと異なり構造体などからキーを取り出す処理を行わないためFindより速い。
+// キーが文字列型ではないテーブルの場合はErrUnmatchColumnValueTypeのエラーが返る。
+// それ以外の戻り値やエラーはFindと同じ。
+//
+//	r, _ := table.FindString("unkodb")
+func (table *Table) FindString(key string) (r *Record, err error) {
+	return table.findByKey(key)
+}
+
+// キーがInt64のテーブルでキーに対応するデータを取得する。
+// Findと異なり構造体などからキーを取り出す処理を行わないためFindより速い。
+// キーがInt64ではないテーブルの場合はErrUnmatchColumnValueTypeのエラーが返る。
+// それ以外の戻り値やエラーはFindと同じ。
+//
+//	r, _ := table.FindInt64(-123)
+func (table *Table) FindInt64(key int64) (r *Record, err error) {
+	return table.findByKey(key)
+}
+
+// FindKeyのキーに使えるGoの型
+type KeyTypes interface {
+	integerTypes | float32 | float64 | string | []byte | time.Time | Decimal | UUID | Int128 | Uint128
+}
+
+// キーのカラム型に対応したGoの型の値でキーに対応するデータを取得する。
+// TableのFindと異なり構造体などからキーを取り出す処理を行わないためFindより速い。
+// キーのカラム型とKの型が対応しない場合はErrUnmatchColumnValueTypeのエラーが返る。
+// それ以外の戻り値やエラーはFindと同じ。
+//
+//	r, _ := unkodb.FindKey(table, int32(123))
+//	r, _ = unkodb.FindKey[unkodb.CounterType](table, 123)
+func FindKey[K KeyTypes](table *Table, key K) (r *Record, err error) {
+	return table.findByKey(key)
+}
+
+// キーに対応するデータのうち指定したカラムだけを取得する。
+// 指定しなかったカラムはファイルから読み込まず、RecordのColumnではnilが返る（キーは常に取得される）。
+// キーだけが必要な場合はcolumnNamesを空にする（データ分離されたテーブルでもデータ領域の読み込みは行われない）。
+// キーのカラム型に対応したGoの型で渡す必要がある。
+// 対応するデータが存在しない場合は戻り値の*Recordもエラーもnilとなる。
+// テーブルに存在しないカラム名を指定した場合はErrUnknownColumnNameのエラーが返る。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+//
+//	r, _ := table.FindColumns(unkodb.CounterType(123), "title")
+//	fmt.Println("id=", r.Key(), "title=", r.Column("title"))
+func (table *Table) FindColumns(key any, columnNames ...string) (r *Record, err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var wanted map[string]bool
+	wanted, err = table.projection(columnNames)
+	if err != nil {
+		return
+	}
+	if !table.key.IsValidValueType(key) {
+		err = &ErrUnmatchColumnValueType{table.key}
+		return
+	}
+	var tree *tableTree
+	tree, err = newTableTree(table, true)
+	if err != nil {
+		return
+	}
+	node := avltree.Find(tree, table.toKey(key))
+	if node == nil {
+		return
+	}
+	r = unwrapTableTreeNode(node).toProjectedRecord(wanted)
+	return
+}
+
+// 読み込むカラム名の集合を作る
+func (table *Table) projection(columnNames []string) (wanted map[string]bool, err error) {
+	wanted = make(map[string]bool, len(columnNames))
+	for _, name := range columnNames {
+		if table.Column(name) == nil {
+			err = ErrUnknownColumnName
+			return
 		}
+		wanted[name] = true
+	}
+	return
+}
+
+func (table *Table) deleteAll() (err error) {
+	if table.isIterating() {
+		err = ErrInvalidOperation
+		return
+	}
+	var tree *tableTree
+	tree, err = newTableTree(table, false)
+	if err != nil {
+		return
+	}
+	defer tree.invalidateCacheOnAbort(&err)
+	avltree.Clear(tree)
+	err = tree.flush()
+	if err != nil {
+		return
+	}
+	table.counter = table.options.counterStartValue() - 1
+	table.nodeCount = 0
+	err = table.flush()
+	return
+}
+
+// 指定したキーに対応するデータとキーを削除する。
+// キーのカラム型に対応したGoの型で渡す必要がある。
+// 指定したキーに対応するデータが存在しない場合には戻り値のエラーはErrNotFoundKeyとなる。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+func (table *Table) Delete(key any) (err error) {
+	if table.isIterating() {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var tree *tableTree
+	tree, err = newTableTree(table, false)
+	if err != nil {
+		return
+	}
+	defer tree.invalidateCacheOnAbort(&err)
+	err = table.delete(tree, key)
+	return
+}
+
+func (table *Table) delete(tree *tableTree, key any) (err error) {
+	key = table.normalizeKey(key)
+	if !table.key.IsValidValueType(key) {
+		err = &ErrUnmatchColumnValueType{table.key}
+		return
+	}
+	_, node := avltree.Delete(tree, table.toKey(key))
+	if node == nil {
+		err = ErrNotFoundKey
+		return
+	}
+	err = tree.flush()
+	if err != nil {
+		return
+	}
+	table.nodeCount--
+	err = table.flush()
+	return
+}
+
+// 条件を満たす場合に指定したキーに対応するデータとキーを削除する。
+// conditionには削除前のデータのコピーが渡され、trueを返した場合にのみ削除が行われる。
+// 条件の確認と削除は同じ木の探索の中で行われる。
+// キーのカラム型に対応したGoの型で渡す必要がある。
+// 指定したキーに対応するデータが存在しない場合には戻り値のエラーはErrNotFoundKeyとなる。
+// conditionがfalseを返した場合は戻り値のエラーはErrConditionNotSatisfiedとなる。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+//
+//	err := table.DeleteIf(unkodb.CounterType(123), func(old *unkodb.Record) bool {
+//		return old.Column("stock").(int32) == 0
+//	})
+func (table *Table) DeleteIf(key any, condition func(old *Record) bool) (err error) {
+	if table.isIterating() {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	key = table.normalizeKey(key)
+	if !table.key.IsValidValueType(key) {
+		err = &ErrUnmatchColumnValueType{table.key}
+		return
+	}
+	var tree *tableTree
+	tree, err = newTableTree(table, false)
+	if err != nil {
+		return
+	}
+	defer tree.invalidateCacheOnAbort(&err)
+	found := false
+	_, _, ok := avltree.Alter(tree, table.toKey(key), func(node avltree.AlterNode) avltree.AlterRequest {
+		found = true
+		old := tree.callbackRecord(node.Key(), node.Value())
+		if condition(old) {
+			return node.Delete()
+		} else {
+			return node.Keep()
+		}
+	})
+	if !ok {
+		if found {
+			err = ErrConditionNotSatisfied
+		} else {
+			err = ErrNotFoundKey
+		}
+		return
+	}
+	err = tree.flush()
+	if err != nil {
+		return
+	}
+	table.nodeCount--
+	err = table.flush()
+	return
+}
+
+// データのバージョン番号がexpectedVersionと一致する場合に指定したキーに対応するデータとキーを削除する。
+// バージョン番号を保持するテーブル（TableCreatorのEnableRowVersionで作成したテーブル）でのみ使用できる。
+// バージョン番号の確認と削除は同じ木の探索の中で行われる。
+// バージョン番号が一致しない場合はErrVersionConflictのエラーが返る。
+// 対応するキーが存在しない場合はErrNotFoundKeyのエラーが返る。
+// バージョン番号を保持しないテーブルの場合はErrInvalidOperationのエラーが返る。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+func (table *Table) DeleteWithVersion(key any, expectedVersion uint64) (err error) {
+	if !table.options.rowVersion {
+		err = ErrInvalidOperation
+		return
+	}
+	err = table.DeleteIf(key, func(old *Record) bool {
+		return old.Version() == expectedVersion
+	})
+	if err == ErrConditionNotSatisfied {
+		err = ErrVersionConflict
+	}
+	return
+}
+
+// テーブルに存在するキーの数を返す。
+func (table *Table) Count() int {
+	return table.nodeCount
+}
+
+// キーのカラム型をCounterにしている場合に次にInsertするときに付与されるキーの値を取得できる。
+// キーのカラム型がCounterではない場合はErrKeyIsNotCounterのエラーが返る。
+// 付与できるキーの値が残っていない場合はErrCounterOverflowのエラーが返る。
+func (table *Table) NextCounterID() (CounterType, error) {
+	if table.key.Type() != Counter {
+		return 0, ErrKeyIsNotCounter
+	}
+	id, err := table.nextCounterID(table.counter)
+	return CounterType(id), err
+}
+
+// キーのカラム型をCounterかCounter64にしている場合に次にInsertするときに付与されるキーの値を取得できる。
+// キーのカラム型がCounterでもCounter64でもない場合はErrKeyIsNotCounterのエラーが返る。
+// 付与できるキーの値が残っていない場合はErrCounterOverflowのエラーが返る。
+func (table *Table) NextCounter64ID() (Counter64Type, error) {
+	if !table.hasCounterKey() {
+		return 0, ErrKeyIsNotCounter
+	}
+	return table.nextCounterID(table.counter)
+}
+
+// キーのカラム型をCounterかCounter64にしている場合に次にInsertするときに付与されるキーの値をnにする。
+// 既存のキー以下の値にした場合はInsertでキーが重複してErrKeyAlreadyExistsのエラーになることがあるので注意。
+// キーのカラム型がCounterでもCounter64でもない場合はErrKeyIsNotCounterのエラー、
+// nが0やキーのカラム型の最大値を超える場合はErrInvalidCounterValueのエラーが返る。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+//
+//	table.SetCounter(1000)
+//	r, _ := table.Insert(data)
+//	fmt.Println(r.Key()) // 1000
+func (table *Table) SetCounter(n uint64) (err error) {
+	if table.isIterating() {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	if !table.hasCounterKey() {
+		err = ErrKeyIsNotCounter
+		return
+	}
+	if n == 0 || n > table.counterMaximum() {
+		err = ErrInvalidCounterValue
+		return
+	}
+	table.counter = n - 1
+	err = table.flush()
+	return
+}
+
+// キーのカラム型がCounterかCounter64かどうか
+func (table *Table) hasCounterKey() bool {
+	return table.key.Type() == Counter || table.key.Type() == Counter64
+}
+
+// カウンターで付与できるキーの最大値
+func (table *Table) counterMaximum() uint64 {
+	if table.key.Type() == Counter64 {
+		return math.MaxUint64
+	}
+	return math.MaxUint32
+}
+
+// カウンターの値counterのときに付与するキーの値を返す
+// 付与できる値が残っていない場合はErrCounterOverflowを返す
+func (table *Table) nextCounterID(counter uint64) (uint64, error) {
+	if counter >= table.counterMaximum() {
+		return 0, ErrCounterOverflow
+	}
+	return counter + 1, nil
+}
+
+// キーの値idを付与した後のカウンターの値を返す
+// 次の値がキーのカラム型の最大値を超える場合は付与できる値が残っていない状態にする
+func (table *Table) counterAfter(id uint64) uint64 {
+	step := table.options.counterStepValue()
+	if step-1 > table.counterMaximum()-id {
+		return table.counterMaximum()
+	}
+	return id + step - 1
+}
+
+// カウンターで付与するキーの値をキーのカラム型に合ったGoの型にする
+func (table *Table) counterKeyValue(id uint64) any {
+	if table.key.Type() == Counter64 {
+		return id
+	}
+	return uint32(id)
+}
+
+// テーブルにデータを挿入する。
+// 引数のdataにはmap[string]anyもしくはunkodb.Dataもしくはunkodbタグを付けた構造体のインスタンスを渡す。
+// dataにはキーとカラムの全ての値をセットしておく必要がある。
+// キーのカラム型がCounterの場合はセットされたキーの値は無視される。
+// 戻り値の*Recordには挿入されたデータのコピーが入る。
+// キーのカラム型がCounterの場合は戻り値の*Recordにキーがセットされるのでキーの確認ができる。
+// キーが既にテーブルに存在する場合はKeyAlreadyExistsのエラーが返る。
+// 引数のdataに不正がある場合は対応したエラーが返る。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+//
+//	data := map[string]any{
+//		"id":     unkodb.CounterType(0),
+//		"title":  "プログラミング入門の本",
+//		"author": "プログラマーのティーチャー",
+//		"genre":  "技術書",
+//	}
+//	r, _ := table.Insert(data)
+//	fmt.Println("idは", r.Key(), "になりました")
+func (table *Table) Insert(data any) (r *Record, err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var node *tableTreeNode
+	node, err = table.insertNode(data)
+	if node != nil {
+		r = node.toRecord()
+	}
+	return
+}
+
+// Insertの処理を行い挿入したノードを返す
+func (table *Table) insertNode(data any) (node *tableTreeNode, err error) {
+	if table.isIterating() {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var record any
+	record, err = table.parseRecord(data)
+	if err != nil {
+		return
+	}
+	var tree *tableTree
+	tree, err = newTableTree(table, false)
+	if err != nil {
+		return
+	}
+	defer tree.invalidateCacheOnAbort(&err)
+	node, err = table.insertRecord(tree, record)
+	return
+}
+
+// InsertやReplaceに渡されたデータをノードに書き込むデータにする
+// Encoderを実装した構造体の場合はマップを経由せずにカラムの形式で書き込んだデータ(*encodedRecord)にする
+// それ以外の場合はparseDataでマップ(tableTreeValue)にする
+func (table *Table) parseRecord(data any) (record any, err error) {
+	if enc, ok := encoderOf(data); ok {
+		return encodeRecord(table, enc)
+	}
+	return parseData(table, data)
+}
+
+func (table *Table) insert(tree *tableTree, record any) (r *Record, err error) {
+	var node *tableTreeNode
+	node, err = table.insertRecord(tree, record)
+	if node != nil {
+		r = node.toRecord()
+	}
+	return
+}
+
+// recordはマップ(tableTreeValue)もしくはEncoderで書き込まれたデータ(*encodedRecord)
+func (table *Table) insertRecord(tree *tableTree, record any) (node *tableTreeNode, err error) {
+	var id uint64
+	if table.hasCounterKey() {
+		id, err = table.nextCounterID(table.counter)
+		if err != nil {
+			return
+		}
+		switch rec := record.(type) {
+		case tableTreeValue:
+			if oldKey, ok := rec[table.key.Name()]; ok {
+				defer func() {
+					rec[table.key.Name()] = oldKey
+				}()
+			} else {
+				defer func() {
+					delete(rec, table.key.Name())
+				}()
+			}
+			rec[table.key.Name()] = table.counterKeyValue(id)
+		case *encodedRecord:
+			rec.key = table.counterKeyValue(id)
+		}
+	}
+	if mdata, ok := record.(tableTreeValue); ok {
+		// Encoderで書き込まれたデータは書き込み時に確認済み
+		err = table.CheckData(mdata)
+		if err != nil {
+			return
+		}
+	}
+	keyValue, _ := table.recordKeyValue(record)
+	key := table.toKey(keyValue)
+	_, ok := avltree.Insert(tree, false, key, record)
+	if !ok {
+		err = ErrKeyAlreadyExists // duplicate key error
+		return
+	}
+	err = tree.flush()
+	if err != nil {
+		return
+	}
+	table.nodeCount += 1
+	if table.hasCounterKey() {
+		table.counter = table.counterAfter(id)
+	}
+	err = table.flush()
+	node = unwrapTableTreeNode(avltree.Find(tree, key))
+	if node == nil {
+		bug.Panic("why? not found node")
+	}
+	return
+}
+
+// 空のテーブルにデータをまとめて挿入する。
+// iterは挿入するデータをキーの順序(降順キーの場合は降順)で1つずつ返し、データが尽きたらokにfalseを返す関数にする。
+// データにはmap[string]anyもしくはunkodb.Dataもしくはunkodbタグを付けた構造体のインスタンスを渡す。
+// キーがCounterの場合はInsertと同様にキーの値は無視され、渡された順に新しいキーが割り当てられる。
+// Insertを繰り返すのとは異なり、全てのデータを受け取ってから平衡の取れた木を下から順に組み立てるため
+// 木の回転やテーブル情報の書き込みは発生しない（テーブル情報は最後に1回だけ書き込まれる）。
+// 全てのデータを一旦メモリ上に保持するので注意。
+// 戻り値のcountには挿入したデータの数が返る。
+// テーブルが空ではない場合はErrInvalidOperationのエラーが返る。
+// キーがキーの順序に並んでいない場合はErrNotSortedKeyのエラーが返り、キーが重複している場合はErrKeyAlreadyExistsのエラーが返る。
+// これらのエラーの場合はテーブルは空のままとなる。
+// データに不正がある場合は対応したエラーが返る。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+//
+//	list := []*Food{ /* キーの順序に並んだデータ */ }
+//	i := 0
+//	count, err := table.BulkLoad(func() (data any, ok bool) {
+//		if i < len(list) {
+//			data, ok = list[i], true
+//			i++
+//		}
+//		return
+//	})
+func (table *Table) BulkLoad(iter func() (data any, ok bool)) (count int, err error) {
+	if table.isIterating() {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	if table.nodeCount != 0 {
+		err = ErrInvalidOperation
+		return
+	}
+	var (
+		records []tableTreeValue
+		keys    []avltree.Key
+		counter = table.counter
+	)
+	for {
+		data, ok := iter()
+		if !ok {
+			break
+		}
+		var mdata tableTreeValue
+		mdata, err = parseData(table, data)
+		if err != nil {
+			return
+		}
+		// iterが同じmapを使いまわす場合もあるのでコピーして保持する
+		record := make(tableTreeValue, len(mdata))
+		for name, value := range mdata {
+			record[name] = value
+		}
+		if table.hasCounterKey() {
+			var id uint64
+			id, err = table.nextCounterID(counter)
+			if err != nil {
+				return
+			}
+			record[table.key.Name()] = table.counterKeyValue(id)
+			counter = table.counterAfter(id)
+		}
+		err = table.CheckData(record)
+		if err != nil {
+			return
+		}
+		key := table.getKey(record)
+		if len(keys) > 0 {
+			switch key.CompareTo(keys[len(keys)-1]) {
+			case avltree.EqualToOtherKey:
+				err = ErrKeyAlreadyExists
+				return
+			case avltree.LessThanOtherKey:
+				err = ErrNotSortedKey
+				return
+			}
+		}
+		records = append(records, record)
+		keys = append(keys, key)
+	}
+	if len(records) == 0 {
+		return
+	}
+	// 組み立てたノードはすぐに書き込むのでキャッシュを使わない木にする
+	var tree *tableTree
+	tree, err = newTableTree(table, true)
+	if err != nil {
+		return
+	}
+	var root *tableTreeNode
+	root, err = tree.buildBalancedTree(keys, records, nil)
+	if err != nil {
+		return
+	}
+	err = table.rootAccessor.setRootAddress(root.position())
+	if err != nil {
+		return
+	}
+	table.nodeCount = len(records)
+	table.counter = counter
+	err = table.flush()
+	if err != nil {
+		return
+	}
+	count = len(records)
+	return
+}
+
+// キーに対応するデータを置き換える。
+// 引数のdataにはmap[string]anyもしくはunkodb.Dataもしくはunkodbタグを付けた構造体のインスタンスを渡す。
+// dataにはキーとカラムの全てをセットしておく必要がある。
+// dataのキーに対応するデータを置き換えることになる。
+// 戻り値の*Recordには置換後のデータのコピーが入る。
+// 対応するキーが存在しない場合はErrNotFoundKeyのエラーが返る。
+// 引数のdataに不正がある場合は対応したエラーが返る。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+//
+//	r, _ := table.Find(unkodb.CounterType(123))
+//	m := r.Take()
+//	m["value"] = m["value"].(int32) + 99
+//	table.Replace(m)
+func (table *Table) Replace(data any) (r *Record, err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var node *tableTreeNode
+	node, err = table.replaceNode(data, nil)
+	if node != nil {
+		r = node.toRecord()
+	}
+	return
+}
+
+// ReplaceやReplaceIfの処理を行い置き換えたノードを返す
+// conditionがnilの場合は無条件に置き換える
+func (table *Table) replaceNode(data any, condition func(old *Record) bool) (node *tableTreeNode, err error) {
+	if table.isIterating() {
+		err = ErrInvalidOperation
+		return
+	}
+	if !debugMode {
+		defer catchError(&err)
+	}
+	var record any
+	record, err = table.parseRecord(data)
+	if err != nil {
+		return
+	}
+	var tree *tableTree
+	tree, err = newTableTree(table, false)
+	if err != nil {
+		return
 	}
-	return nil
+	defer tree.invalidateCacheOnAbort(&err)
+	node, err = table.replaceRecord(tree, record, condition)
+	return
 }
 
-func (table *Table) getKey(data map[string]any) avltree.Key {
-	return table.key.toKey(data[table.key.Name()])
+func (table *Table) replace(tree *tableTree, record any) (r *Record, err error) {
+	r, err = table.replaceIf(tree, record, nil)
+	return
 }
 
-// 指定したキーに対応するデータを取得する。
-// キーのカラム型に対応したGoの型で渡す必要がある。
-// 指定したキーに対応するデータが存在しない場合には戻り値は全てnilとなる。
-// キーの型が不正な場合は対応するエラーが返る。
-// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
-func (table *Table) Find(key any) (r *Record, err error) {
-	if !debugMode {
-		defer catchError(&err)
+// conditionがnilの場合は無条件に置き換える
+func (table *Table) replaceIf(tree *tableTree, record any, condition func(old *Record) bool) (r *Record, err error) {
+	var node *tableTreeNode
+	node, err = table.replaceRecord(tree, record, condition)
+	if node != nil {
+		r = node.toRecord()
 	}
-	if mdata, e := parseData(table, key); e == nil {
-		// parseDataするのコスト高すぎる
-		if k, ok := mdata[table.key.Name()]; ok {
-			key = k
+	return
+}
+
+// recordはマップ(tableTreeValue)もしくはEncoderで書き込まれたデータ(*encodedRecord)
+// conditionがnilの場合は無条件に置き換える
+func (table *Table) replaceRecord(tree *tableTree, record any, condition func(old *Record) bool) (node *tableTreeNode, err error) {
+	keyValue, ok := table.recordKeyValue(record)
+	if mdata, isMap := record.(tableTreeValue); isMap {
+		// Encoderで書き込まれたデータは書き込み時に確認済み
+		err = table.CheckData(mdata)
+		if err != nil {
+			return
 		}
+	} else if !ok {
+		err = &ErrNotFoundColumnName{table.key}
+		return
 	}
-	if !table.key.IsValidValueType(key) {
-		err = &ErrUnmatchColumnValueType{table.key}
+	key := table.toKey(keyValue)
+	found := false
+	_, ok = avltree.Update(tree, key, func(key avltree.Key, oldValue any) (newValue any, keepOldValue bool) {
+		found = true
+		if condition != nil {
+			old := tree.callbackRecord(key, oldValue)
+			if !condition(old) {
+				keepOldValue = true
+				return
+			}
+		}
+		newValue = record
+		return
+	})
+	if !ok {
+		if found {
+			err = ErrConditionNotSatisfied
+		} else {
+			err = ErrNotFoundKey
+		}
 		return
 	}
-	var tree *tableTree
-	tree, err = newTableTree(table, true)
+	err = tree.flush()
 	if err != nil {
 		return
 	}
-	node := avltree.Find(tree, table.key.toKey(key))
+	node = unwrapTableTreeNode(avltree.Find(tree, key))
 	if node == nil {
-		return
+		bug.Panic("why? not found node")
+	}
+	return
+}
+
+// 条件を満たす場合にキーに対応するデータを置き換える。
+// conditionには置き換え前のデータのコピーが渡され、trueを返した場合にのみ置き換えが行われる。
+// 条件の確認と置き換えは同じ木の探索の中で行われる。
+// 引数のdataにはmap[string]anyもしくはunkodb.Dataもしくはunkodbタグを付けた構造体のインスタンスを渡す。
+// dataにはキーとカラムの全てをセットしておく必要がある。
+// 戻り値の*Recordには置換後のデータのコピーが入る。
+// 対応するキーが存在しない場合はErrNotFoundKeyのエラーが返る。
+// conditionがfalseを返した場合はErrConditionNotSatisfiedのエラーが返る。
+// 引数のdataに不正がある場合は対応したエラーが返る。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+//
+//	r, _ := table.Find(unkodb.CounterType(123))
+//	m := r.Take()
+//	m["stock"] = m["stock"].(int32) - 1
+//	_, err := table.ReplaceIf(m, func(old *unkodb.Record) bool {
+//		return old.Column("stock").(int32) > 0
+//	})
+func (table *Table) ReplaceIf(data any, condition func(old *Record) bool) (r *Record, err error) {
+	if !debugMode {
+		defer catchError(&err)
 	}
-	r = &Record{
-		table: table,
-		data:  node.Value().(tableTreeValue),
+	var node *tableTreeNode
+	node, err = table.replaceNode(data, condition)
+	if node != nil {
+		r = node.toRecord()
 	}
 	return
 }
 
-func (table *Table) deleteAll() (err error) {
-	if table.isIterating() {
+// データのバージョン番号がexpectedVersionと一致する場合にキーに対応するデータを置き換える。
+// バージョン番号を保持するテーブル（TableCreatorのEnableRowVersionで作成したテーブル）でのみ使用できる。
+// バージョン番号の確認と置き換えは同じ木の探索の中で行われ、置き換えるとバージョン番号は1増える。
+// 引数のdataにはmap[string]anyもしくはunkodb.Dataもしくはunkodbタグを付けた構造体のインスタンスを渡す。
+// 戻り値の*Recordには置換後のデータのコピーが入る。
+// バージョン番号が一致しない場合はErrVersionConflictのエラーが返る。
+// 対応するキーが存在しない場合はErrNotFoundKeyのエラーが返る。
+// バージョン番号を保持しないテーブルの場合はErrInvalidOperationのエラーが返る。
+// それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+//
+//	r, _ := table.Find(unkodb.CounterType(123))
+//	version := r.Version()
+//	m := r.Take()
+//	m["title"] = "新しいタイトル"
+//	_, err := table.ReplaceWithVersion(m, version)
+//	if err == unkodb.ErrVersionConflict {
+//		// 他で更新されていた
+//	}
+func (table *Table) ReplaceWithVersion(data any, expectedVersion uint64) (r *Record, err error) {
+	if !table.options.rowVersion {
 		err = ErrInvalidOperation
 		return
 	}
-	var tree *tableTree
-	tree, err = newTableTree(table, false)
-	if err != nil {
-		return
-	}
-	avltree.Clear(tree)
-	err = tree.flush()
-	if err != nil {
-		return
+	r, err = table.ReplaceIf(data, func(old *Record) bool {
+		return old.Version() == expectedVersion
+	})
+	if err == ErrConditionNotSatisfied {
+		err = ErrVersionConflict
 	}
-	table.counter = 0
-	table.nodeCount = 0
-	err = table.flush()
 	return
 }
 
-// 指定したキーに対応するデータとキーを削除する。
-// キーのカラム型に対応したGoの型で渡す必要がある。
-// 指定したキーに対応するデータが存在しない場合には戻り値のエラーはErrNotFoundKeyとなる。
+// キーに対応するデータの各カラムの値がexpectedColumnsで指定した値と一致する場合にnewDataで置き換える。
+// expectedColumnsには比較するカラムのカラム名と値を指定する。指定しなかったカラムは比較されない。
+// 値の比較と置き換えは同じ木の探索の中で行われる。
+// newDataにはmap[string]anyもしくはunkodb.Dataもしくはunkodbタグを付けた構造体のインスタンスを渡す。
+// newDataにはキーとカラムの全てをセットしておく必要があり、キーの値はkeyと同じである必要がある。
+// 戻り値の*Recordには置換後のデータのコピーが入る。
+// 対応するキーが存在しない場合はErrNotFoundKeyのエラーが返る。
+// 値が一致しなかった場合はErrConditionNotSatisfiedのエラーが返る。
+// テーブルに存在しないカラム名が指定された場合はErrUnknownColumnNameのエラーが返る。
 // それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
-func (table *Table) Delete(key any) (err error) {
+//
+//	r, _ := table.Find(unkodb.CounterType(123))
+//	m := r.Take()
+//	oldStock := m["stock"]
+//	m["stock"] = oldStock.(int32) - 1
+//	_, err := table.CompareAndSwap(unkodb.CounterType(123), map[string]any{"stock": oldStock}, m)
+func (table *Table) CompareAndSwap(key any, expectedColumns map[string]any, newData any) (r *Record, err error) {
 	if table.isIterating() {
 		err = ErrInvalidOperation
 		return
@@ -213,68 +1340,67 @@ func (table *Table) Delete(key any) (err error) {
 	if !debugMode {
 		defer catchError(&err)
 	}
-	// parseDataするのコスト高すぎる
-	if mdata, e := parseData(table, key); e == nil {
-		if k, ok := mdata[table.key.Name()]; ok {
-			key = k
-		}
-	}
+	key = table.normalizeKey(key)
 	if !table.key.IsValidValueType(key) {
 		err = &ErrUnmatchColumnValueType{table.key}
 		return
 	}
-	var tree *tableTree
-	tree, err = newTableTree(table, false)
+	for name, value := range expectedColumns {
+		col := table.Column(name)
+		if col == nil {
+			err = ErrUnknownColumnName
+			return
+		}
+		if !col.IsValidValueType(value) {
+			err = &ErrUnmatchColumnValueType{col}
+			return
+		}
+	}
+	var mdata tableTreeValue
+	mdata, err = parseData(table, newData)
 	if err != nil {
 		return
 	}
-	_, node := avltree.Delete(tree, table.key.toKey(key))
-	if node == nil {
-		err = ErrNotFoundKey
+	err = table.CheckData(mdata)
+	if err != nil {
 		return
 	}
-	err = tree.flush()
+	if table.toKey(key).CompareTo(table.getKey(mdata)) != avltree.EqualToOtherKey {
+		err = ErrInvalidOperation
+		return
+	}
+	var tree *tableTree
+	tree, err = newTableTree(table, false)
 	if err != nil {
 		return
 	}
-	table.nodeCount--
-	err = table.flush()
+	defer tree.invalidateCacheOnAbort(&err)
+	r, err = table.replaceIf(tree, mdata, func(old *Record) bool {
+		for name, value := range expectedColumns {
+			if !equalColumnValue(old.data[name], value) {
+				return false
+			}
+		}
+		return true
+	})
 	return
 }
 
-// テーブルに存在するキーの数を返す。
-func (table *Table) Count() int {
-	return table.nodeCount
-}
-
-// キーのカラム型をCounterにしている場合に次にInsertするときに付与されるキーの値を取得できる。
-// キーのカラム型がCounterではない場合はErrKeyIsNotCounterのエラーが返る。
-func (table *Table) NextCounterID() (CounterType, error) {
-	if table.key.Type() != Counter {
-		return 0, ErrKeyIsNotCounter
-	}
-	return CounterType(table.counter + 1), nil
-}
-
-// テーブルにデータを挿入する。
+// キーに対応するデータが存在する場合は置き換え、存在しない場合は挿入する。
 // 引数のdataにはmap[string]anyもしくはunkodb.Dataもしくはunkodbタグを付けた構造体のインスタンスを渡す。
 // dataにはキーとカラムの全ての値をセットしておく必要がある。
-// キーのカラム型がCounterの場合はセットされたキーの値は無視される。
-// 戻り値の*Recordには挿入されたデータのコピーが入る。
-// キーのカラム型がCounterの場合は戻り値の*Recordにキーがセットされるのでキーの確認ができる。
-// キーが既にテーブルに存在する場合はKeyAlreadyExistsのエラーが返る。
+// キーのカラム型がCounterの場合、セットされたキーの値がテーブルに存在しなければInsertと同じく新しいキーの値が付与される。
+// 戻り値の*Recordには挿入後あるいは置換後のデータのコピーが入る。
 // 引数のdataに不正がある場合は対応したエラーが返る。
 // それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
 //
 //	data := map[string]any{
-//		"id":     unkodb.CounterType(0),
 //		"title":  "プログラミング入門の本",
 //		"author": "プログラマーのティーチャー",
-//		"genre":  "技術書",
+//		"price":  int64(2980),
 //	}
-//	r, _ := table.Insert(data)
-//	fmt.Println("idは", r.Key(), "になりました")
-func (table *Table) Insert(data any) (r *Record, err error) {
+//	r, _ := table.Upsert(data)
+func (table *Table) Upsert(data any) (r *Record, err error) {
 	if table.isIterating() {
 		err = ErrInvalidOperation
 		return
@@ -282,24 +1408,8 @@ func (table *Table) Insert(data any) (r *Record, err error) {
 	if !debugMode {
 		defer catchError(&err)
 	}
-	var mdata tableTreeValue
-	mdata, err = parseData(table, data)
-	if err != nil {
-		return
-	}
-	if table.key.Type() == Counter {
-		if oldKey, ok := mdata[table.key.Name()]; ok {
-			defer func() {
-				mdata[table.key.Name()] = oldKey
-			}()
-		} else {
-			defer func() {
-				delete(mdata, table.key.Name())
-			}()
-		}
-		mdata[table.key.Name()] = uint32(table.counter) + 1
-	}
-	err = table.CheckData(mdata)
+	var record any
+	record, err = table.parseRecord(data)
 	if err != nil {
 		return
 	}
@@ -308,46 +1418,33 @@ func (table *Table) Insert(data any) (r *Record, err error) {
 	if err != nil {
 		return
 	}
-	key := table.getKey(mdata)
-	_, ok := avltree.Insert(tree, false, key, tableTreeValue(mdata))
-	if !ok {
-		err = ErrKeyAlreadyExists // duplicate key error
-		return
-	}
-	err = tree.flush()
-	if err != nil {
-		return
-	}
-	table.nodeCount += 1
-	if table.key.Type() == Counter {
-		table.counter += 1
-	}
-	err = table.flush()
-	node := avltree.Find(tree, key)
-	if node == nil {
-		bug.Panic("why? not found node")
+	defer tree.invalidateCacheOnAbort(&err)
+	exists := false
+	if keyValue, ok := table.recordKeyValue(record); ok && table.key.IsValidValueType(keyValue) {
+		// 探索で読み込んだノードはtreeのキャッシュに乗るので続くInsertやReplaceでの再読み込みは発生しない
+		exists = avltree.Find(tree, table.toKey(keyValue)) != nil
 	}
-	r = &Record{
-		table: table,
-		data:  node.Value().(tableTreeValue),
+	if exists {
+		r, err = table.replace(tree, record)
+	} else {
+		r, err = table.insert(tree, record)
 	}
 	return
 }
 
-// キーに対応するデータを置き換える。
-// 引数のdataにはmap[string]anyもしくはunkodb.Dataもしくはunkodbタグを付けた構造体のインスタンスを渡す。
-// dataにはキーとカラムの全てをセットしておく必要がある。
-// dataのキーに対応するデータを置き換えることになる。
-// 戻り値の*Recordには置換後のデータのコピーが入る。
+// キーに対応するデータの一部のカラムだけを書き換える。
+// 引数のchangesには書き換えるカラムのカラム名と値を指定する。指定しなかったカラムの値はそのまま維持される。
+// キーの値を書き換えることはできない（changesにキー名を含める場合はキーと同じ値である必要がある）。
+// 書き換え後のデータのサイズが既存の領域に収まる場合は領域の再確保は行われない。
+// 戻り値の*Recordには書き換え後のデータのコピーが入る。
 // 対応するキーが存在しない場合はErrNotFoundKeyのエラーが返る。
-// 引数のdataに不正がある場合は対応したエラーが返る。
+// テーブルに存在しないカラム名が指定された場合はErrUnknownColumnNameのエラーが返る。
 // それ以外のエラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
 //
-//	r, _ := table.Find(unkodb.CounterType(123))
-//	m := r.Take()
-//	m["value"] = m["value"].(int32) + 99
-//	table.Replace(m)
-func (table *Table) Replace(data any) (r *Record, err error) {
+//	r, _ := table.Update(unkodb.CounterType(123), map[string]any{
+//		"price": int64(1980),
+//	})
+func (table *Table) Update(key any, changes map[string]any) (r *Record, err error) {
 	if table.isIterating() {
 		err = ErrInvalidOperation
 		return
@@ -355,32 +1452,59 @@ func (table *Table) Replace(data any) (r *Record, err error) {
 	if !debugMode {
 		defer catchError(&err)
 	}
-	var mdata tableTreeValue
-	mdata, err = parseData(table, data)
-	err = table.CheckData(mdata)
-	if err != nil {
+	key = table.normalizeKey(key)
+	if !table.key.IsValidValueType(key) {
+		err = &ErrUnmatchColumnValueType{table.key}
 		return
 	}
+	avlKey := table.toKey(key)
+	for name, value := range changes {
+		col := table.Column(name)
+		if col == nil {
+			err = ErrUnknownColumnName
+			return
+		}
+		if !col.IsValidValueType(value) {
+			err = invalidValueError(col, value)
+			return
+		}
+		if col == Column(table.key) && avlKey.CompareTo(table.toKey(value)) != avltree.EqualToOtherKey {
+			err = ErrInvalidOperation
+			return
+		}
+	}
 	var tree *tableTree
 	tree, err = newTableTree(table, false)
 	if err != nil {
 		return
 	}
-	key := table.getKey(mdata)
-	_, ok := avltree.Replace(tree, key, mdata)
+	defer tree.invalidateCacheOnAbort(&err)
+	_, ok := avltree.Update(tree, avlKey, func(_ avltree.Key, oldValue any) (newValue any, keepOldValue bool) {
+		// 読み込んだデータも呼び出し元の値も書き換えないようにコピーしたものを書き込む
+		oldRecord := oldValue.(tableTreeValue)
+		record := make(tableTreeValue, len(oldRecord))
+		for name, value := range oldRecord {
+			record[name] = value
+		}
+		for name, value := range changes {
+			record[name] = table.Column(name).copyValue(value)
+		}
+		newValue = record
+		return
+	})
 	if !ok {
 		err = ErrNotFoundKey
 		return
 	}
 	err = tree.flush()
-	node := avltree.Find(tree, key)
+	if err != nil {
+		return
+	}
+	node := avltree.Find(tree, avlKey)
 	if node == nil {
 		bug.Panic("why? not found node")
 	}
-	r = &Record{
-		table: table,
-		data:  node.Value().(tableTreeValue),
-	}
+	r = unwrapTableTreeNode(node).toRecord()
 	return
 }
 
@@ -396,7 +1520,7 @@ func (table *Table) endIteration() {
 	table.iterating--
 }
 
-// テーブルに存在するデータのコピーをキーの昇順でコールバック関数に渡していく。
+// テーブルに存在するデータのコピーをキーの順序(降順キーの場合は降順)でコールバック関数に渡していく。
 // イテレーション中はInsert/Replace/Delete/DeleteTableなどのテーブル変更操作を行うとデータが壊れる。
 // エラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
 // コールバック関数内でのpanicはエラーとして返ることがある。（その場合、スタックトレース取得などはコールバック関数内で頑張って）。
@@ -413,23 +1537,66 @@ func (table *Table) IterateAll(callback IterateCallbackFunc) (err error) {
 	if !debugMode {
 		defer catchError(&err)
 	}
-	table.beginIteration()
-	defer table.endIteration()
-	tree, err := newTableTree(table, true)
+	err = table.iterateNodes(false, func(node *tableTreeNode) (breakIteration bool) {
+		return callback(node.toRecord())
+	})
+	return
+}
+
+// JSONのカラムのJSONパスの位置にある値がpredicateを満たすデータのコピーをキーの順序(降順キーの場合は降順)でコールバック関数に渡していく。
+// predicateにはJSONパスの位置の値をencoding/jsonでanyに変換した値(数値ならfloat64、オブジェクトならmap[string]anyなど)が渡される。
+// JSONパスの位置に値が存在しないデータはpredicateを呼ばずに読み飛ばす。
+// JSONパスの書き方はProjectJSONと同じ。
+// テーブルに存在しないカラム名やJSONではないカラムを指定した場合はErrUnknownColumnNameのエラー、JSONパスが不正な場合はErrInvalidJSONPathのエラーが返る。
+// それ以外の注意点やエラーはIterateAllと同じ。
+//
+//	table.IterateWhereJSON("config", "$.server.port", func(value any) bool {
+//		port, ok := value.(float64)
+//		return ok && port >= 8000
+//	}, func(r *unkodb.Record) (breakIteration bool) {
+//		fmt.Println(r.Key())
+//		return
+//	})
+func (table *Table) IterateWhereJSON(columnName, path string, predicate func(value any) bool, callback IterateCallbackFunc) (err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	if col := table.Column(columnName); col == nil || col.Type() != JSON {
+		err = ErrUnknownColumnName
+		return
+	}
+	var steps []jsonPathStep
+	steps, err = parseJSONPath(path)
 	if err != nil {
-		return err
+		return
 	}
-	avltree.Iterate(tree, false, func(node avltree.Node) (breakIteration bool) {
-		rec := &Record{
-			table: table,
-			data:  node.Value().(tableTreeValue),
+	var jsonErr error
+	err = table.IterateAll(func(r *Record) (breakIteration bool) {
+		doc, found, err := projectJSON(r.Column(columnName).(json.RawMessage), steps)
+		if err != nil {
+			jsonErr = err
+			return true
 		}
-		return callback(rec)
+		if !found {
+			return false
+		}
+		var value any
+		if err = json.Unmarshal(doc, &value); err != nil {
+			jsonErr = err
+			return true
+		}
+		if predicate(value) {
+			return callback(r)
+		}
+		return false
 	})
+	if err == nil {
+		err = jsonErr
+	}
 	return
 }
 
-// テーブルに存在するデータのコピーをキーの降順でコールバック関数に渡していく。
+// テーブルに存在するデータのコピーをキーの順序の逆順(降順キーの場合は昇順)でコールバック関数に渡していく。
 // イテレーション中はInsert/Replace/Delete/DeleteTableなどのテーブル変更操作を行うとデータが壊れる。
 // エラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
 // コールバック関数内でのpanicはエラーとして返ることがある。（その場合、スタックトレース取得などはコールバック関数内で頑張って）。
@@ -446,23 +1613,13 @@ func (table *Table) IterateBackAll(callback IterateCallbackFunc) (err error) {
 	if !debugMode {
 		defer catchError(&err)
 	}
-	table.beginIteration()
-	defer table.endIteration()
-	tree, err := newTableTree(table, true)
-	if err != nil {
-		return err
-	}
-	avltree.Iterate(tree, true, func(node avltree.Node) (breakIteration bool) {
-		rec := &Record{
-			table: table,
-			data:  node.Value().(tableTreeValue),
-		}
-		return callback(rec)
+	err = table.iterateNodes(true, func(node *tableTreeNode) (breakIteration bool) {
+		return callback(node.toRecord())
 	})
 	return
 }
 
-// テーブルの指定範囲内に存在するデータのコピーをキーの昇順でコールバック関数に渡していく。
+// テーブルの指定範囲内に存在するデータのコピーをキーの順序(降順キーの場合は降順)でコールバック関数に渡していく。
 // lowerKey以上upperKey以下のキーの範囲のデータを辿る。
 // キーの指定にはキーのカラム型に合ったGoの型で指定する必要がある。
 // イテレーション中はInsert/Replace/Delete/DeleteTableなどのテーブル変更操作を行うとデータが壊れる。
@@ -480,15 +1637,43 @@ func (table *Table) IterateBackAll(callback IterateCallbackFunc) (err error) {
 //		return
 //	})
 func (table *Table) IterateRange(lowerKey, upperKey any, callback IterateCallbackFunc) (err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	err = table.iterateRangeNodes(false, lowerKey, upperKey, func(node *tableTreeNode) (breakIteration bool) {
+		return callback(node.toRecord())
+	})
+	return
+}
+
+// テーブルの指定範囲内に存在するデータのうち指定したカラムだけをキーの順序(降順キーの場合は降順)でコールバック関数に渡していく。
+// 指定しなかったカラムはファイルから読み込まず、RecordのColumnではnilが返る（キーは常に取得される）。
+// lowerKey以上upperKey以下のキーの範囲のデータを辿る。lowerKeyとupperKeyにnilを指定した場合は全てのデータを辿る。
+// キーの指定にはキーのカラム型に合ったGoの型で指定する必要がある。
+// テーブルに存在しないカラム名を指定した場合はErrUnknownColumnNameのエラーが返る。
+// イテレーション中はInsert/Replace/Delete/DeleteTableなどのテーブル変更操作を行うとデータが壊れる。
+// エラー(IOエラーなど)がある場合は戻り値エラーにnil以外が返る。（たいていプログラムの実行に致命的なエラー）
+// コールバック関数内でのpanicはエラーとして返ることがある。（その場合、スタックトレース取得などはコールバック関数内で頑張って）。
+//
+//	table.IterateRangeColumns(nil, nil, []string{"price"}, func(r *unkodb.Record) (breakIteration bool) {
+//		total += r.Column("price").(int64)
+//		return
+//	})
+func (table *Table) IterateRangeColumns(lowerKey, upperKey any, columnNames []string, callback IterateCallbackFunc) (err error) {
 	if !debugMode {
 		defer catchError(&err)
 	}
 	table.beginIteration()
 	defer table.endIteration()
+	var wanted map[string]bool
+	wanted, err = table.projection(columnNames)
+	if err != nil {
+		return
+	}
 	var lKey, rKey avltree.Key
 	if lowerKey != nil {
 		if table.key.IsValidValueType(lowerKey) {
-			lKey = table.key.toKey(lowerKey)
+			lKey = table.toKey(lowerKey)
 		} else {
 			err = &ErrUnmatchColumnValueType{table.key}
 			return
@@ -496,7 +1681,7 @@ func (table *Table) IterateRange(lowerKey, upperKey any, callback IterateCallbac
 	}
 	if upperKey != nil {
 		if table.key.IsValidValueType(upperKey) {
-			rKey = table.key.toKey(upperKey)
+			rKey = table.toKey(upperKey)
 		} else {
 			err = &ErrUnmatchColumnValueType{table.key}
 			return
@@ -506,17 +1691,14 @@ func (table *Table) IterateRange(lowerKey, upperKey any, callback IterateCallbac
 	if err != nil {
 		return err
 	}
+	lKey, rKey = table.treeRange(lKey, rKey)
 	avltree.RangeIterate(tree, false, lKey, rKey, func(node avltree.Node) (breakIteration bool) {
-		rec := &Record{
-			table: table,
-			data:  node.Value().(tableTreeValue),
-		}
-		return callback(rec)
+		return callback(unwrapTableTreeNode(node).toProjectedRecord(wanted))
 	})
 	return
 }
 
-// テーブルの指定範囲内に存在するデータのコピーをキーの降順でコールバック関数に渡していく。
+// テーブルの指定範囲内に存在するデータのコピーをキーの順序の逆順(降順キーの場合は昇順)でコールバック関数に渡していく。
 // lowerKey以上upperKey以下のキーの範囲のデータを辿る。
 // キーの指定にはキーのカラム型に合ったGoの型で指定する必要がある。
 // イテレーション中はInsert/Replace/Delete/DeleteTableなどのテーブル変更操作を行うとデータが壊れる。
@@ -534,6 +1716,36 @@ func (table *Table) IterateRange(lowerKey, upperKey any, callback IterateCallbac
 //		return
 //	})
 func (table *Table) IterateBackRange(lowerKey, upperKey any, callback IterateCallbackFunc) (err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	err = table.iterateRangeNodes(true, lowerKey, upperKey, func(node *tableTreeNode) (breakIteration bool) {
+		return callback(node.toRecord())
+	})
+	return
+}
+
+// IterateAllやIterateBackAllの処理を行いノードをコールバック関数に渡していく
+// backwardがtrueの場合はキーの順序の逆順で辿る
+func (table *Table) iterateNodes(backward bool, callback func(node *tableTreeNode) (breakIteration bool)) (err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	table.beginIteration()
+	defer table.endIteration()
+	tree, err := newTableTree(table, true)
+	if err != nil {
+		return err
+	}
+	avltree.Iterate(tree, backward, func(node avltree.Node) (breakIteration bool) {
+		return callback(unwrapTableTreeNode(node))
+	})
+	return
+}
+
+// IterateRangeやIterateBackRangeの処理を行いノードをコールバック関数に渡していく
+// backwardがtrueの場合はキーの順序の逆順で辿る
+func (table *Table) iterateRangeNodes(backward bool, lowerKey, upperKey any, callback func(node *tableTreeNode) (breakIteration bool)) (err error) {
 	if !debugMode {
 		defer catchError(&err)
 	}
@@ -542,7 +1754,7 @@ func (table *Table) IterateBackRange(lowerKey, upperKey any, callback IterateCal
 	var lKey, rKey avltree.Key
 	if lowerKey != nil {
 		if table.key.IsValidValueType(lowerKey) {
-			lKey = table.key.toKey(lowerKey)
+			lKey = table.toKey(lowerKey)
 		} else {
 			err = &ErrUnmatchColumnValueType{table.key}
 			return
@@ -550,7 +1762,7 @@ func (table *Table) IterateBackRange(lowerKey, upperKey any, callback IterateCal
 	}
 	if upperKey != nil {
 		if table.key.IsValidValueType(upperKey) {
-			rKey = table.key.toKey(upperKey)
+			rKey = table.toKey(upperKey)
 		} else {
 			err = &ErrUnmatchColumnValueType{table.key}
 			return
@@ -560,12 +1772,9 @@ func (table *Table) IterateBackRange(lowerKey, upperKey any, callback IterateCal
 	if err != nil {
 		return err
 	}
-	avltree.RangeIterate(tree, true, lKey, rKey, func(node avltree.Node) (breakIteration bool) {
-		rec := &Record{
-			table: table,
-			data:  node.Value().(tableTreeValue),
-		}
-		return callback(rec)
+	lKey, rKey = table.treeRange(lKey, rKey)
+	avltree.RangeIterate(tree, backward, lKey, rKey, func(node avltree.Node) (breakIteration bool) {
+		return callback(unwrapTableTreeNode(node))
 	})
 	return
 }
@@ -594,7 +1803,7 @@ func (table *Table) IterateAllKeys(callback IterateKeyCallbackFunc) (err error)
 		return err
 	}
 	avltree.Iterate(tree, false, func(node avltree.Node) (breakIteration bool) {
-		key := table.key.copyValue(table.key.unwrapKey(node.Key()))
+		key := table.key.copyValue(table.unwrapKey(node.Key()))
 		return callback(key)
 	})
 	return
@@ -624,7 +1833,7 @@ func (table *Table) IterateBackAllKeys(callback IterateKeyCallbackFunc) (err err
 		return err
 	}
 	avltree.Iterate(tree, true, func(node avltree.Node) (breakIteration bool) {
-		key := table.key.copyValue(table.key.unwrapKey(node.Key()))
+		key := table.key.copyValue(table.unwrapKey(node.Key()))
 		return callback(key)
 	})
 	return
@@ -656,7 +1865,7 @@ func (table *Table) IterateRangeKeys(lowerKey, upperKey any, callback IterateKey
 	var lKey, rKey avltree.Key
 	if lowerKey != nil {
 		if table.key.IsValidValueType(lowerKey) {
-			lKey = table.key.toKey(lowerKey)
+			lKey = table.toKey(lowerKey)
 		} else {
 			err = &ErrUnmatchColumnValueType{table.key}
 			return
@@ -664,7 +1873,7 @@ func (table *Table) IterateRangeKeys(lowerKey, upperKey any, callback IterateKey
 	}
 	if upperKey != nil {
 		if table.key.IsValidValueType(upperKey) {
-			rKey = table.key.toKey(upperKey)
+			rKey = table.toKey(upperKey)
 		} else {
 			err = &ErrUnmatchColumnValueType{table.key}
 			return
@@ -674,8 +1883,9 @@ func (table *Table) IterateRangeKeys(lowerKey, upperKey any, callback IterateKey
 	if err != nil {
 		return err
 	}
+	lKey, rKey = table.treeRange(lKey, rKey)
 	avltree.RangeIterate(tree, false, lKey, rKey, func(node avltree.Node) (breakIteration bool) {
-		key := table.key.copyValue(table.key.unwrapKey(node.Key()))
+		key := table.key.copyValue(table.unwrapKey(node.Key()))
 		return callback(key)
 	})
 	return
@@ -707,7 +1917,7 @@ func (table *Table) IterateBackRangeKeys(lowerKey, upperKey any, callback Iterat
 	var lKey, rKey avltree.Key
 	if lowerKey != nil {
 		if table.key.IsValidValueType(lowerKey) {
-			lKey = table.key.toKey(lowerKey)
+			lKey = table.toKey(lowerKey)
 		} else {
 			err = &ErrUnmatchColumnValueType{table.key}
 			return
@@ -715,7 +1925,7 @@ func (table *Table) IterateBackRangeKeys(lowerKey, upperKey any, callback Iterat
 	}
 	if upperKey != nil {
 		if table.key.IsValidValueType(upperKey) {
-			rKey = table.key.toKey(upperKey)
+			rKey = table.toKey(upperKey)
 		} else {
 			err = &ErrUnmatchColumnValueType{table.key}
 			return
@@ -725,8 +1935,9 @@ func (table *Table) IterateBackRangeKeys(lowerKey, upperKey any, callback Iterat
 	if err != nil {
 		return err
 	}
+	lKey, rKey = table.treeRange(lKey, rKey)
 	avltree.RangeIterate(tree, true, lKey, rKey, func(node avltree.Node) (breakIteration bool) {
-		key := table.key.copyValue(table.key.unwrapKey(node.Key()))
+		key := table.key.copyValue(table.unwrapKey(node.Key()))
 		return callback(key)
 	})
 	return
@@ -0,0 +1,2689 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neetsdkasu/avltree"
+)
+
+func TestTable_Upsert(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Food struct {
+		Id    CounterType `unkodb:"id,key@Counter"`
+		Name  string      `unkodb:"name,ShortString"`
+		Price int64       `unkodb:"price,Int64"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("foodlist", (*Food)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := table.Upsert(&Food{Name: "りんご", Price: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Key() != any(CounterType(1)) {
+		t.Fatalf("wrong key %#v", r.Key())
+	}
+
+	r, err = table.Upsert(&Food{Id: 1, Name: "青りんご", Price: 550})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Key() != any(CounterType(1)) || r.Column("name") != any("青りんご") {
+		t.Fatalf("wrong record %#v", r.data)
+	}
+
+	r, err = table.Upsert(&Food{Id: 99, Name: "バナナ", Price: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Key() != any(CounterType(2)) {
+		t.Fatalf("wrong key %#v", r.Key())
+	}
+
+	if table.Count() != 2 {
+		t.Fatalf("wrong count %d", table.Count())
+	}
+
+	tc, err := db.CreateTable("games")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.ShortStringKey("title")
+	tc.Int64Column("score")
+	games, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = games.Upsert(map[string]any{
+			"title": "テトリス",
+			"score": int64(i * 100),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if games.Count() != 1 {
+		t.Fatalf("wrong count %d", games.Count())
+	}
+
+	r, err = games.Find("テトリス")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("score") != any(int64(200)) {
+		t.Fatalf("wrong score %#v", r.Column("score"))
+	}
+}
+
+func TestTable_Update(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("foodlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.ShortStringColumn("name")
+	tc.Int64Column("price")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = table.Insert(map[string]any{
+		"name":  "カツカレー",
+		"price": int64(800),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := table.Update(CounterType(1), map[string]any{
+		"price": int64(900),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("name") != any("カツカレー") || r.Column("price") != any(int64(900)) {
+		t.Fatalf("wrong record %#v", r.data)
+	}
+
+	// 同じサイズに収まる更新では領域の再確保は起きない
+	nextAddress := db.file.NextNewSegmentAddress()
+	_, err = table.Update(CounterType(1), map[string]any{
+		"name": "カレー",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.file.NextNewSegmentAddress() != nextAddress {
+		t.Fatal("segment reallocated")
+	}
+
+	r, err = table.Find(CounterType(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("name") != any("カレー") || r.Column("price") != any(int64(900)) {
+		t.Fatalf("wrong record %#v", r.data)
+	}
+
+	// Findと同様にキーをデータの形で指定できる
+	r, err = table.Update(map[string]any{"id": CounterType(1)}, map[string]any{
+		"price": int64(950),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("name") != any("カレー") || r.Column("price") != any(int64(950)) {
+		t.Fatalf("wrong record %#v", r.data)
+	}
+
+	_, err = table.Update(CounterType(2), map[string]any{
+		"price": int64(900),
+	})
+	if err != ErrNotFoundKey {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = table.Update(CounterType(1), map[string]any{
+		"color": "yellow",
+	})
+	if err != ErrUnknownColumnName {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = table.Update(CounterType(1), map[string]any{
+		"price": "free",
+	})
+	if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = table.Update(CounterType(1), map[string]any{
+		"id": CounterType(5),
+	})
+	if err != ErrInvalidOperation {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestTable_Update_dataSeparation(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.TextColumn("body")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !table.dataSeparation.Enabled() {
+		t.Fatal("dataSeparation is not enabled")
+	}
+
+	_, err = table.Insert(map[string]any{
+		"body": "short",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idleCount := avltree.Count(db.segManager.tree)
+
+	body := strings.Repeat("long text ", 100)
+	_, err = table.Update(CounterType(1), map[string]any{
+		"body": body,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 収まらなくなった古いデータ領域は解放されている
+	if c := avltree.Count(db.segManager.tree); c != idleCount+1 {
+		t.Fatalf("wrong idle tree count %d", c)
+	}
+
+	r, err := table.Find(CounterType(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("body") != any(body) {
+		t.Fatalf("wrong body %#v", r.Column("body"))
+	}
+}
+
+func TestTable_ConditionalWrites(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Item struct {
+		Id    CounterType `unkodb:"id,key@Counter"`
+		Name  string      `unkodb:"name,ShortString"`
+		Stock int32       `unkodb:"stock,Int32"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("items", (*Item)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = table.Insert(&Item{Name: "ペン", Stock: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inStock := func(old *Record) bool {
+		return old.Column("stock").(int32) > 0
+	}
+
+	_, err = table.ReplaceIf(&Item{Id: 1, Name: "ペン", Stock: 0}, inStock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = table.ReplaceIf(&Item{Id: 1, Name: "ペン", Stock: -1}, inStock)
+	if err != ErrConditionNotSatisfied {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = table.ReplaceIf(&Item{Id: 2, Name: "ノート", Stock: 0}, inStock)
+	if err != ErrNotFoundKey {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = table.CompareAndSwap(CounterType(1), map[string]any{"stock": int32(5)}, &Item{Id: 1, Name: "ペン", Stock: 10})
+	if err != ErrConditionNotSatisfied {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	// Deleteと同様にキーをデータの形で指定できる
+	r, err := table.CompareAndSwap(&Item{Id: 1}, map[string]any{"stock": int32(0), "name": "ペン"}, &Item{Id: 1, Name: "赤ペン", Stock: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("name") != any("赤ペン") || r.Column("stock") != any(int32(10)) {
+		t.Fatalf("wrong record %#v", r.data)
+	}
+
+	_, err = table.CompareAndSwap(CounterType(1), map[string]any{"color": "red"}, &Item{Id: 1, Name: "赤ペン", Stock: 10})
+	if err != ErrUnknownColumnName {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = table.CompareAndSwap(CounterType(1), nil, &Item{Id: 2, Name: "赤ペン", Stock: 10})
+	if err != ErrInvalidOperation {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	soldOut := func(old *Record) bool {
+		return old.Column("stock").(int32) == 0
+	}
+
+	err = table.DeleteIf(CounterType(1), soldOut)
+	if err != ErrConditionNotSatisfied {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = table.Update(CounterType(1), map[string]any{"stock": int32(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = table.DeleteIf(&Item{Id: 1}, soldOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = table.DeleteIf(CounterType(1), soldOut)
+	if err != ErrNotFoundKey {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	if table.Count() != 0 {
+		t.Fatalf("wrong count %d", table.Count())
+	}
+}
+
+func TestTable_RowVersion(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.ShortStringColumn("title")
+	err = tc.EnableRowVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.EnableRowVersion() != ErrInvalidOperation {
+		t.Fatal("EnableRowVersion after Create")
+	}
+	if !table.RowVersionEnabled() {
+		t.Fatal("row version is not enabled")
+	}
+
+	r, err := table.Insert(map[string]any{"title": "吾輩は猫である"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Version() != 1 {
+		t.Fatalf("wrong version %d", r.Version())
+	}
+
+	_, err = table.Insert(map[string]any{"title": "坊っちゃん"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = table.ReplaceWithVersion(map[string]any{"id": CounterType(1), "title": "こころ"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Version() != 2 {
+		t.Fatalf("wrong version %d", r.Version())
+	}
+
+	_, err = table.ReplaceWithVersion(map[string]any{"id": CounterType(1), "title": "三四郎"}, 1)
+	if err != ErrVersionConflict {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	r, err = table.Update(CounterType(1), map[string]any{"title": "それから"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Version() != 3 {
+		t.Fatalf("wrong version %d", r.Version())
+	}
+
+	err = table.DeleteWithVersion(CounterType(2), 2)
+	if err != ErrVersionConflict {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	// ファイルを開きなおしてもバージョン番号は保持されている
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("books")
+	if !table.RowVersionEnabled() {
+		t.Fatal("row version is not enabled")
+	}
+
+	r, err = table.Find(CounterType(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Version() != 3 || r.Column("title") != any("それから") {
+		t.Fatalf("wrong record %d %#v", r.Version(), r.data)
+	}
+
+	err = table.DeleteWithVersion(CounterType(2), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Count() != 1 {
+		t.Fatalf("wrong count %d", table.Count())
+	}
+
+	other, err := db.CreateTableByOtherTable("books2", table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !other.RowVersionEnabled() {
+		t.Fatal("row version is not enabled")
+	}
+
+	tc, err = db.CreateTable("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.ShortStringColumn("title")
+	plain, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = plain.Insert(map[string]any{"title": "草枕"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Version() != 0 {
+		t.Fatalf("wrong version %d", r.Version())
+	}
+	_, err = plain.ReplaceWithVersion(map[string]any{"id": CounterType(1), "title": "草枕"}, 0)
+	if err != ErrInvalidOperation {
+		t.Fatalf("wrong error %v", err)
+	}
+	err = plain.DeleteWithVersion(CounterType(1), 0)
+	if err != ErrInvalidOperation {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestTable_BulkLoad(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Item struct {
+		Id   CounterType `unkodb:"id,key@Counter"`
+		Name string      `unkodb:"name,ShortString"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("items", (*Item)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const N = 1000
+	i := 0
+	count, err := table.BulkLoad(func() (data any, ok bool) {
+		if i < N {
+			data, ok = &Item{Name: fmt.Sprint("item", i)}, true
+			i++
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != N || table.Count() != N {
+		t.Fatalf("wrong count %d %d", count, table.Count())
+	}
+
+	_, err = table.BulkLoad(func() (data any, ok bool) { return })
+	if err != ErrInvalidOperation {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	// 組み立てた木に対して通常の操作ができる
+	r, err := table.Insert(&Item{Name: "new item"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Key() != any(CounterType(N+1)) {
+		t.Fatalf("wrong key %#v", r.Key())
+	}
+	for id := 1; id <= N; id += 3 {
+		err = table.Delete(CounterType(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("items")
+
+	r, err = table.Find(CounterType(500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("name") != any("item499") {
+		t.Fatalf("wrong record %#v", r.data)
+	}
+
+	prev := CounterType(0)
+	err = table.IterateAll(func(r *Record) (_ bool) {
+		id := r.Key().(CounterType)
+		if id <= prev || id%3 == 1 && id <= N {
+			t.Fatalf("wrong key %d after %d", id, prev)
+		}
+		prev = id
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.ShortStringKey("word")
+	words, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for expected, list := range map[error][]string{
+		ErrNotSortedKey:     {"a", "c", "b"},
+		ErrKeyAlreadyExists: {"a", "b", "b"},
+	} {
+		i = 0
+		_, err = words.BulkLoad(func() (data any, ok bool) {
+			if i < len(list) {
+				data, ok = map[string]any{"word": list[i]}, true
+				i++
+			}
+			return
+		})
+		if err != expected {
+			t.Fatalf("wrong error %v", err)
+		}
+		if words.Count() != 0 {
+			t.Fatalf("wrong count %d", words.Count())
+		}
+	}
+}
+
+func TestTable_BulkLoad_failure(t *testing.T) {
+	if err := RegisterColumnType("TestRejectingPoint", testRejectingPointCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(columnCodecs, "TestRejectingPoint")
+
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("spots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.CustomColumn("point", "TestRejectingPoint")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const N = 7
+	load := func(bad int) (int, error) {
+		i := 0
+		return table.BulkLoad(func() (data any, ok bool) {
+			if i < N {
+				p := testPoint{int32(i), 0}
+				if i == bad {
+					p.X = -1
+				}
+				data, ok = map[string]any{"point": p}, true
+				i++
+			}
+			return
+		})
+	}
+
+	// 中央のデータのノードは最後に作成されるので他のノードを作成した後に失敗する
+	_, err = load(N / 2)
+	if err == nil {
+		t.Fatal("no error")
+	}
+	if table.Count() != 0 || table.rootAddress != nullAddress {
+		t.Fatalf("wrong table %d %d", table.Count(), table.rootAddress)
+	}
+	next := db.segManager.file.NextNewSegmentAddress()
+
+	// 失敗したときに作成したノードの領域は解放されて次の組み立てで再利用される
+	_, err = load(N / 2)
+	if err == nil {
+		t.Fatal("no error")
+	}
+	if db.segManager.file.NextNewSegmentAddress() != next {
+		t.Fatalf("wrong NextNewSegmentAddress %d (want %d)", db.segManager.file.NextNewSegmentAddress(), next)
+	}
+
+	count, err := load(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != N || table.Count() != N {
+		t.Fatalf("wrong count %d %d", count, table.Count())
+	}
+}
+
+func TestTable_FindColumns(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("articles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.ShortStringColumn("title")
+	tc.TextColumn("body")
+	tc.Int64Column("views")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 10; i++ {
+		_, err = table.Insert(map[string]any{
+			"title": fmt.Sprint("title", i),
+			"body":  strings.Repeat("body", i),
+			"views": int64(i * 10),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := table.FindColumns(CounterType(3), "views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Key() != any(CounterType(3)) || r.Column("views") != any(int64(30)) {
+		t.Fatalf("wrong record %#v", r.data)
+	}
+	if r.Column("title") != nil || r.Column("body") != nil {
+		t.Fatalf("wrong record %#v", r.data)
+	}
+
+	// 一部のカラムだけのRecordはCopyToやMoveToで使えない
+	dst := map[string]any{}
+	if err = r.CopyTo(dst); err != ErrProjectedRecord {
+		t.Fatalf("wrong error %v", err)
+	}
+	type Article struct {
+		Id    CounterType `unkodb:"id,key@Counter"`
+		Title string      `unkodb:"title,ShortString"`
+		Views int64       `unkodb:"views,Int64"`
+	}
+	var article Article
+	if err = r.MoveTo(&article); err != ErrProjectedRecord {
+		t.Fatalf("wrong error %v", err)
+	}
+	if len(dst) != 0 || article != (Article{}) {
+		t.Fatalf("wrong dst %#v %#v", dst, article)
+	}
+	taken := r.Take()
+	if len(taken) != 2 || taken["views"] != any(int64(30)) {
+		t.Fatalf("wrong data %#v", taken)
+	}
+
+	// 全てのカラムを指定した場合は通常のRecordと同じ
+	r, err = table.FindColumns(CounterType(3), "title", "body", "views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.CopyTo(dst); err != nil {
+		t.Fatal(err)
+	}
+	if len(dst) != 4 || dst["title"] != any("title3") {
+		t.Fatalf("wrong dst %#v", dst)
+	}
+
+	r, err = table.FindColumns(CounterType(99), "views")
+	if err != nil || r != nil {
+		t.Fatalf("wrong result %#v %v", r, err)
+	}
+
+	_, err = table.FindColumns(CounterType(3), "color")
+	if err != ErrUnknownColumnName {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	// キーだけの場合はデータ分離された領域を読み込まない
+	tree, err := newTableTree(table, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := unwrapTableTreeNode(avltree.Find(tree, table.key.toKey(CounterType(5))))
+	data := node.readValue(map[string]bool{"id": true})
+	if len(data) != 1 || data["id"] != any(uint32(5)) {
+		t.Fatalf("wrong data %#v", data)
+	}
+	if node.separationDataSegment != nil {
+		t.Fatal("separated data segment is loaded")
+	}
+
+	var total int64
+	count := 0
+	err = table.IterateRangeColumns(CounterType(2), CounterType(4), []string{"views", "title"}, func(r *Record) (_ bool) {
+		total += r.Column("views").(int64)
+		if r.Column("title") != any(fmt.Sprint("title", r.Key())) || r.Column("body") != nil {
+			t.Fatalf("wrong record %#v", r.data)
+		}
+		count++
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || total != 90 {
+		t.Fatalf("wrong result %d %d", count, total)
+	}
+
+	count = 0
+	err = table.IterateRangeColumns(nil, nil, nil, func(r *Record) (_ bool) {
+		if len(r.data) != 1 {
+			t.Fatalf("wrong record %#v", r.data)
+		}
+		count++
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 10 {
+		t.Fatalf("wrong count %d", count)
+	}
+}
+
+func TestTable_FindByTypedKey(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Item struct {
+		Id   CounterType `unkodb:"id,key@Counter"`
+		Name string      `unkodb:"name,ShortString"`
+	}
+	items, err := db.CreateTableByTaggedStruct("items", (*Item)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = items.Insert(&Item{Name: "えんぴつ"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.ShortStringKey("word")
+	words, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = words.Insert(map[string]any{"word": "unko"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err = db.CreateTable("scores")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Int64Key("score")
+	scores, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = scores.Insert(map[string]any{"score": int64(-5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r, err := items.FindCounter(1); err != nil || r == nil || r.Column("name") != any("えんぴつ") {
+		t.Fatalf("wrong result %#v %v", r, err)
+	}
+	if r, err := FindKey[CounterType](items, 1); err != nil || r == nil {
+		t.Fatalf("wrong result %#v %v", r, err)
+	}
+	if r, err := items.FindCounter(2); err != nil || r != nil {
+		t.Fatalf("wrong result %#v %v", r, err)
+	}
+	if r, err := words.FindString("unko"); err != nil || r == nil {
+		t.Fatalf("wrong result %#v %v", r, err)
+	}
+	if r, err := scores.FindInt64(-5); err != nil || r == nil {
+		t.Fatalf("wrong result %#v %v", r, err)
+	}
+	if r, err := FindKey(scores, int64(-5)); err != nil || r == nil {
+		t.Fatalf("wrong result %#v %v", r, err)
+	}
+	if _, err := words.FindInt64(1); err == nil {
+		t.Fatal("no error")
+	} else if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+	if _, err := FindKey(scores, int32(-5)); err == nil {
+		t.Fatal("no error")
+	}
+
+	tc, err = db.CreateTable("prices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.DecimalKey("price", 10, 2)
+	tc.TimestampColumn("at")
+	prices, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, err = prices.Insert(map[string]any{"price": NewDecimal(1230, 2), "at": at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := FindKey(prices, NewDecimal(1230, 2)); err != nil || r == nil || !r.Column("at").(time.Time).Equal(at) {
+		t.Fatalf("wrong result %#v %v", r, err)
+	}
+	if _, err := FindKey(prices, at); err == nil {
+		t.Fatal("no error")
+	}
+
+	tc, err = db.CreateTable("measures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Float64Key("value")
+	measures, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = measures.Insert(map[string]any{"value": 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := FindKey(measures, 1.5); err != nil || r == nil {
+		t.Fatalf("wrong result %#v %v", r, err)
+	}
+
+	// Findは引き続き構造体からキーを取り出せる
+	if r, err := items.Find(&Item{Id: 1}); err != nil || r == nil {
+		t.Fatalf("wrong result %#v %v", r, err)
+	}
+}
+
+func TestTable_TimeAndBoolColumns(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Event struct {
+		At       time.Time `unkodb:"at,key@Timestamp"`
+		Day      time.Time `unkodb:"day,Date"`
+		Done     bool      `unkodb:"done"`
+		Modified time.Time `unkodb:"modified"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("events", (*Event)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Key().Type() != Timestamp || table.Column("day").Type() != Date ||
+		table.Column("done").Type() != Bool || table.Column("modified").Type() != Timestamp {
+		t.Fatalf("wrong column types %v %v %v %v", table.Key(), table.Column("day"), table.Column("done"), table.Column("modified"))
+	}
+
+	jst := time.FixedZone("JST", 9*60*60)
+	base := time.Date(2023, 12, 31, 23, 0, 0, 0, jst)
+	const N = 10
+	for i := 0; i < N; i++ {
+		at := base.Add(time.Duration(i) * time.Hour).Add(time.Duration(i))
+		_, err = table.Insert(&Event{At: at, Day: at, Done: i%2 == 0, Modified: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(table *Table) {
+		// 2024-01-01 00:00:00 JST から 2024-01-01 04:00:00 JST まで
+		lower := time.Date(2023, 12, 31, 15, 0, 0, 0, time.UTC)
+		upper := lower.Add(4 * time.Hour)
+		var events []Event
+		err := table.IterateRange(lower, upper, func(r *Record) (_ bool) {
+			var ev Event
+			if err := r.MoveTo(&ev); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, ev)
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 4 {
+			t.Fatalf("wrong count %d", len(events))
+		}
+		for k, ev := range events {
+			i := k + 1
+			at := base.Add(time.Duration(i) * time.Hour).Add(time.Duration(i))
+			if !ev.At.Equal(at) || ev.At.Location() != time.UTC || ev.At.Nanosecond() != i {
+				t.Fatalf("wrong at %v (expected %v)", ev.At, at)
+			}
+			if !ev.Day.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Fatalf("wrong day %v", ev.Day)
+			}
+			if ev.Done != (i%2 == 0) {
+				t.Fatalf("wrong done %v", ev.Done)
+			}
+			if !ev.Modified.Equal(at) {
+				t.Fatalf("wrong modified %v", ev.Modified)
+			}
+		}
+
+		r, err := table.Find(base.In(time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r == nil || r.Column("day") != any(time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("wrong record %#v", r)
+		}
+	}
+
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(db.Table("events"))
+
+	_, err = db.Table("events").Insert(map[string]any{
+		"at":       time.Now(),
+		"day":      time.Now(),
+		"done":     uint8(1),
+		"modified": time.Now(),
+	})
+	if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestTable_DecimalColumn(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Item struct {
+		Price Decimal `unkodb:"price,key@Decimal[8,2]"`
+		Tax   big.Rat `unkodb:"tax,Decimal[5,3]"`
+		Label string  `unkodb:"label,Decimal[10,0]"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("items", (*Item)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ColumnTypeHint(table.Key()) != "Decimal[8,2] (unkodb.Decimal)" {
+		t.Fatalf("wrong hint %s", ColumnTypeHint(table.Key()))
+	}
+
+	prices := []string{"10.5", "-3", "0", "-0.25", "999999.99", "-999999.99", "0.01"}
+	for i, p := range prices {
+		price, err := ParseDecimal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		item := &Item{Price: price, Label: fmt.Sprint(i * 1000)}
+		item.Tax.SetFrac64(int64(i), 8)
+		_, err = table.Insert(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(table *Table) {
+		expected := []string{"-999999.99", "-3.00", "-0.25", "0.00", "0.01", "10.50", "999999.99"}
+		var got []string
+		err := table.IterateAll(func(r *Record) (_ bool) {
+			var item Item
+			if err := r.MoveTo(&item); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, item.Price.String())
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Fatalf("wrong order %v", got)
+		}
+
+		// キーはカラムのscaleに合わせて検索される
+		r, err := table.Find(NewDecimal(105, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Key() != any(NewDecimal(1050, 2)) {
+			t.Fatalf("wrong key %#v", r.Key())
+		}
+		var item Item
+		if err := r.MoveTo(&item); err != nil {
+			t.Fatal(err)
+		}
+		if item.Tax.Cmp(big.NewRat(0, 1)) != 0 || item.Label != "0" {
+			t.Fatalf("wrong item %v %s", item.Tax.String(), item.Label)
+		}
+		r, err = table.Find(NewDecimal(-300, 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.MoveTo(&item); err != nil {
+			t.Fatal(err)
+		}
+		if item.Tax.Cmp(big.NewRat(1, 8)) != 0 || item.Label != "1000" {
+			t.Fatalf("wrong item %v %s", item.Tax.String(), item.Label)
+		}
+	}
+
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("items")
+	check(table)
+
+	wrongs := []map[string]any{
+		// 小数点以下の桁がscaleに収まらない
+		{"price": NewDecimal(1, 3), "tax": NewDecimal(0, 0), "label": NewDecimal(0, 0)},
+		// precisionの桁数を超える
+		{"price": NewDecimal(100000000, 2), "tax": NewDecimal(0, 0), "label": NewDecimal(0, 0)},
+		{"price": float64(1.5), "tax": NewDecimal(0, 0), "label": NewDecimal(0, 0)},
+	}
+	for _, w := range wrongs {
+		_, err = table.Insert(w)
+		if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+			t.Fatalf("wrong error %v", err)
+		}
+	}
+	item := &Item{Price: NewDecimal(2, 0), Label: "12.5"}
+	item.Tax.SetFrac64(1, 3)
+	_, err = table.Insert(item)
+	if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	tc, err := db.CreateTable("wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ps := range [][2]uint8{{0, 0}, {19, 2}, {3, 4}} {
+		if err = tc.DecimalKey("k", ps[0], ps[1]); err != ErrWrongDecimalPrecision {
+			t.Fatalf("wrong error %v", err)
+		}
+		if err = tc.DecimalColumn("c", ps[0], ps[1]); err != ErrWrongDecimalPrecision {
+			t.Fatalf("wrong error %v", err)
+		}
+	}
+}
+
+// encoding.TextMarshalerを実装したUUIDの代わりの型
+type textUUID struct{ text string }
+
+func (u textUUID) MarshalText() ([]byte, error) {
+	return []byte(u.text), nil
+}
+
+func (u *textUUID) UnmarshalText(text []byte) error {
+	u.text = string(text)
+	return nil
+}
+
+func TestTable_UUIDAndInt128Columns(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Account struct {
+		Id      UUID     `unkodb:"id,key@UUID"`
+		Ref     [16]byte `unkodb:"ref,UUID"`
+		Alias   textUUID `unkodb:"alias,UUID"`
+		Balance Int128   `unkodb:"balance"`
+		Total   *big.Int `unkodb:"total,Uint128"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("accounts", (*Account)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Key().Type() != UUIDColumnType || table.Column("balance").Type() != Int128ColumnType ||
+		table.Column("total").Type() != Uint128ColumnType {
+		t.Fatalf("wrong column types %v %v %v", table.Key(), table.Column("balance"), table.Column("total"))
+	}
+
+	ids := []string{
+		"ffffffff-0000-0000-0000-000000000000",
+		"00000000-0000-0000-0000-000000000001",
+		"80000000-0000-0000-0000-000000000000",
+		"7fffffff-ffff-ffff-ffff-ffffffffffff",
+	}
+	for i, s := range ids {
+		id, err := ParseUUID(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = table.Insert(&Account{
+			Id:      id,
+			Ref:     [16]byte{byte(i)},
+			Alias:   textUUID{s},
+			Balance: Int128FromInt64(int64(i) - 2),
+			Total:   new(big.Int).Lsh(big.NewInt(int64(i)), 100),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(table *Table) {
+		expected := []string{ids[1], ids[3], ids[2], ids[0]}
+		var got []string
+		err := table.IterateAll(func(r *Record) (_ bool) {
+			// nilのポインタのフィールドには書き込めない
+			a := Account{Total: new(big.Int)}
+			if err := r.MoveTo(&a); err != nil {
+				t.Fatal(err)
+			}
+			if a.Alias.text != a.Id.String() {
+				t.Fatalf("wrong alias %v", a.Alias)
+			}
+			i := int64(a.Ref[0])
+			if a.Balance != Int128FromInt64(i-2) {
+				t.Fatalf("wrong balance %v", a.Balance)
+			}
+			if a.Total.Cmp(new(big.Int).Lsh(big.NewInt(i), 100)) != 0 {
+				t.Fatalf("wrong total %v", a.Total)
+			}
+			got = append(got, a.Id.String())
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Fatalf("wrong order %v", got)
+		}
+	}
+
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("accounts")
+	check(table)
+
+	tc, err := db.CreateTable("ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Int128Key("id")
+	ledger, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []int64{5, -3, 0, -1 << 63, 1<<63 - 1} {
+		_, err = ledger.Insert(map[string]any{"id": Int128FromInt64(v)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = ledger.Insert(map[string]any{"id": Int128{Hi: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	err = ledger.IterateAll(func(r *Record) (_ bool) {
+		keys = append(keys, r.Key().(Int128).String())
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(keys) != "[-9223372036854775808 -3 0 5 9223372036854775807 18446744073709551616]" {
+		t.Fatalf("wrong order %v", keys)
+	}
+
+	_, err = table.Insert(&Account{Alias: textUUID{"not uuid"}, Total: big.NewInt(0)})
+	if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+	_, err = table.Insert(&Account{Alias: textUUID{ids[0]}, Total: big.NewInt(-1)})
+	if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestTable_FloatKeys(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("scores")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Float64Key("score")
+	tc.ShortStringColumn("name")
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	negNaN := math.Float64frombits(math.Float64bits(math.NaN()) | 1<<63)
+	values := []float64{3.5, math.NaN(), math.Copysign(0, -1), math.Inf(1), -2, 0, math.Inf(-1), negNaN, -math.SmallestNonzeroFloat64}
+	for i, v := range values {
+		_, err = table.Insert(map[string]any{"score": v, "name": fmt.Sprint(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = table.Insert(map[string]any{"score": math.NaN(), "name": "dup"})
+	if err != ErrKeyAlreadyExists {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	check := func(table *Table) {
+		expected := []string{"7", "6", "4", "8", "2", "5", "0", "3", "1"}
+		var got []string
+		err := table.IterateAll(func(r *Record) (_ bool) {
+			got = append(got, r.Column("name").(string))
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Fatalf("wrong order %v", got)
+		}
+
+		// -0と+0は別のキー
+		got = got[:0]
+		err = table.IterateRange(-1.0, math.Copysign(0, -1), func(r *Record) (_ bool) {
+			got = append(got, r.Column("name").(string))
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != "[8 2]" {
+			t.Fatalf("wrong range %v", got)
+		}
+
+		r, err := table.Find(math.NaN())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Column("name") != any("1") || !math.IsNaN(r.Key().(float64)) {
+			t.Fatalf("wrong record %v %v", r.Key(), r.Column("name"))
+		}
+		r, err = table.Find(0.0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Column("name") != any("5") || math.Signbit(r.Key().(float64)) {
+			t.Fatalf("wrong record %v %v", r.Key(), r.Column("name"))
+		}
+	}
+
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(db.Table("scores"))
+
+	type Point struct {
+		X    float32 `unkodb:"x,key@Float32"`
+		Name string  `unkodb:"name,ShortString"`
+	}
+	points, err := db.CreateTableByTaggedStruct("points", (*Point)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []float32{1.5, -1.5, 0.25, -0.25} {
+		_, err = points.Insert(&Point{X: x, Name: fmt.Sprint(x)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var xs []float32
+	err = points.IterateRange(float32(-1), float32(1), func(r *Record) (_ bool) {
+		var p Point
+		if err := r.MoveTo(&p); err != nil {
+			t.Fatal(err)
+		}
+		xs = append(xs, p.X)
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(xs) != "[-0.25 0.25]" {
+		t.Fatalf("wrong range %v", xs)
+	}
+}
+
+func TestTable_ListColumns(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Tag string
+
+	type Article struct {
+		Id     CounterType `unkodb:"id,key@Counter"`
+		Tags   []Tag       `unkodb:"tags,List<ShortString>[5]"`
+		Scores []int       `unkodb:"scores,List<Int64>[3]"`
+		Hashes [][]byte    `unkodb:"hashes,List<ShortBytes>[2]"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("articles", (*Article)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ColumnTypeHint(table.Column("tags")) != "List<ShortString>[5] ([]string)" {
+		t.Fatalf("wrong hint %s", ColumnTypeHint(table.Column("tags")))
+	}
+
+	_, err = table.Insert(&Article{
+		Tags:   []Tag{"go", "db"},
+		Scores: []int{3, -1, 4},
+		Hashes: [][]byte{[]byte("abc"), []byte{0, 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = table.Insert(map[string]any{
+		"id":     CounterType(0),
+		"tags":   []string{},
+		"scores": []int64(nil),
+		"hashes": [][]byte{[]byte("x")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = table.Insert(Data{
+		Key:     CounterType(0),
+		Columns: []any{[]string{"a", "b", "c", "d", "e"}, []int64{7}, [][]byte{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(table *Table) {
+		r, err := table.Find(CounterType(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(r.Column("tags")) != "[go db]" || fmt.Sprint(r.Column("scores")) != "[3 -1 4]" {
+			t.Fatalf("wrong record %v %v", r.Column("tags"), r.Column("scores"))
+		}
+		var copied Article
+		if err := r.CopyTo(&copied); err != nil {
+			t.Fatal(err)
+		}
+		copied.Hashes[0][0] = 'X'
+		if string(r.Column("hashes").([][]byte)[0]) != "abc" {
+			t.Fatal("CopyTo does not copy elements")
+		}
+		var moved Article
+		if err := r.MoveTo(&moved); err != nil {
+			t.Fatal(err)
+		}
+		if moved.Id != 1 || fmt.Sprint(moved.Tags, moved.Scores, moved.Hashes) != "[go db] [3 -1 4] [[97 98 99] [0 1]]" {
+			t.Fatalf("wrong article %#v", moved)
+		}
+
+		r, err = table.Find(CounterType(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var article Article
+		if err := r.MoveTo(&article); err != nil {
+			t.Fatal(err)
+		}
+		if len(article.Tags) != 0 || len(article.Scores) != 0 || string(article.Hashes[0]) != "x" {
+			t.Fatalf("wrong article %#v", article)
+		}
+
+		r, err = table.Find(CounterType(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r.Column("tags").([]string)) != 5 {
+			t.Fatalf("wrong tags %v", r.Column("tags"))
+		}
+	}
+
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("articles")
+	check(table)
+
+	r, err := table.Find(CounterType(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := r.Take()
+	m["scores"] = []int64{10}
+	_, err = table.CompareAndSwap(CounterType(1), map[string]any{"scores": []int64{3, -1, 4}}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = table.CompareAndSwap(CounterType(1), map[string]any{"scores": []int64{3, -1, 4}}, m)
+	if err != ErrConditionNotSatisfied {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	wrongs := []map[string]any{
+		// 要素数が多すぎる
+		{"id": CounterType(0), "tags": []string{}, "scores": []int64{1, 2, 3, 4}, "hashes": [][]byte{}},
+		// 要素の型が違う
+		{"id": CounterType(0), "tags": []string{}, "scores": []int{1}, "hashes": [][]byte{}},
+		// 要素のサイズが大きすぎる
+		{"id": CounterType(0), "tags": []string{strings.Repeat("x", 256)}, "scores": []int64{}, "hashes": [][]byte{}},
+	}
+	for _, w := range wrongs {
+		_, err = table.Insert(w)
+		if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+			t.Fatalf("wrong error %v", err)
+		}
+	}
+
+	tc, err := db.CreateTable("wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	if err = tc.ListColumn("a", Text, 10); err != ErrInvalidListElementType {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = tc.ListColumn("a", Int64, 0); err != ErrSizeMustBePositiveValue {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	type WrongKey struct {
+		Tags []string `unkodb:"tags,key@List<ShortString>[3]"`
+	}
+	_, err = db.CreateTableByTaggedStruct("wrong_key", (*WrongKey)(nil))
+	if _, ok := err.(*ErrWrongTag); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestTable_StructColumn(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Geo struct {
+		Lat float64 `unkodb:"lat,Float64"`
+		Lng float64 `unkodb:"lng,Float64"`
+	}
+
+	type Address struct {
+		City string   `unkodb:"city,ShortString"`
+		Zip  string   `unkodb:"zip,FixedSizeShortString[7]"`
+		Tags []string `unkodb:"tags,List<ShortString>[3]"`
+		Geo  Geo      `unkodb:"geo"`
+	}
+
+	type Person struct {
+		Id      CounterType `unkodb:"id,key@Counter"`
+		Name    string      `unkodb:"name,ShortString"`
+		Address *Address    `unkodb:"address,Struct"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("people", (*Person)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hint := "Struct{city ShortString (string), zip FixedSizeShortString[7] (string), tags List<ShortString>[3] ([]string), geo Struct{lat Float64 (float64), lng Float64 (float64)} (map[string]any)} (map[string]any)"
+	if ColumnTypeHint(table.Column("address")) != hint {
+		t.Fatalf("wrong hint %s", ColumnTypeHint(table.Column("address")))
+	}
+
+	_, err = table.Insert(&Person{
+		Name: "foo",
+		Address: &Address{
+			City: "Tokyo",
+			Zip:  "1000001",
+			Tags: []string{"home"},
+			Geo:  Geo{Lat: 35.68, Lng: 139.76},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = table.Insert(map[string]any{
+		"id":   CounterType(0),
+		"name": "bar",
+		"address": map[string]any{
+			"city": "Osaka",
+			"zip":  "5300001",
+			"tags": []string{},
+			"geo":  map[string]any{"lat": 34.70, "lng": 135.49},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(table *Table) {
+		r, err := table.Find(CounterType(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		address, ok := r.Column("address").(*StructValue)
+		if !ok || address.Column("city") != "Tokyo" || address.Column("geo").(*StructValue).Column("lng") != 139.76 {
+			t.Fatalf("wrong address %#v", r.Column("address"))
+		}
+		if fmt.Sprint(address.Columns()[:3]) != "[Tokyo 1000001 [home]]" || address.Column("color") != nil {
+			t.Fatalf("wrong address %v", address.Columns())
+		}
+		var nested Address
+		if err := address.CopyTo(&nested); err != nil {
+			t.Fatal(err)
+		}
+		if nested.City != "Tokyo" || nested.Geo.Lng != 139.76 {
+			t.Fatalf("wrong address %#v", nested)
+		}
+		nested.Tags[0] = "work"
+		if address.Column("tags").([]string)[0] != "home" {
+			t.Fatal("StructValue.CopyTo does not copy nested values")
+		}
+		m := map[string]any{}
+		if err := address.CopyTo(m); err != nil {
+			t.Fatal(err)
+		}
+		if m["zip"] != "1000001" || m["geo"].(map[string]any)["lat"] != 35.68 {
+			t.Fatalf("wrong address %#v", m)
+		}
+		copied := Person{Address: new(Address)}
+		if err := r.CopyTo(&copied); err != nil {
+			t.Fatal(err)
+		}
+		copied.Address.Tags[0] = "work"
+		if address.Column("tags").([]string)[0] != "home" {
+			t.Fatal("CopyTo does not copy nested values")
+		}
+		moved := Person{Address: new(Address)}
+		if err := r.MoveTo(&moved); err != nil {
+			t.Fatal(err)
+		}
+		if moved.Id != 1 || moved.Name != "foo" || moved.Address.City != "Tokyo" || moved.Address.Zip != "1000001" ||
+			fmt.Sprint(moved.Address.Tags) != "[home]" || moved.Address.Geo.Lat != 35.68 {
+			t.Fatalf("wrong person %#v %#v", moved, moved.Address)
+		}
+
+		r, err = table.Find(CounterType(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data struct {
+			Address map[string]any `unkodb:"address,Struct"`
+		}
+		if err := r.MoveTo(&data); err != nil {
+			t.Fatal(err)
+		}
+		if data.Address["city"] != "Osaka" || data.Address["geo"].(map[string]any)["lat"] != 34.70 {
+			t.Fatalf("wrong address %#v", data.Address)
+		}
+	}
+
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("people")
+	check(table)
+
+	if ColumnTypeHint(table.Column("address")) != hint {
+		t.Fatalf("wrong hint %s", ColumnTypeHint(table.Column("address")))
+	}
+
+	people, err := Typed[Person](table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	person, err := people.Find(CounterType(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if person.Address.City != "Osaka" || person.Address.Geo.Lng != 135.49 {
+		t.Fatalf("wrong person %#v", person.Address)
+	}
+	person.Address.City = "Kyoto"
+	if _, err = people.Replace(person); err != nil {
+		t.Fatal(err)
+	}
+	r, err := table.Find(CounterType(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("address").(*StructValue).Column("city") != "Kyoto" {
+		t.Fatalf("wrong address %#v", r.Column("address"))
+	}
+
+	// StructValueをそのまま書き込むデータの値に使える
+	moved, err := table.Insert(map[string]any{"id": CounterType(0), "name": "baz", "address": r.Column("address")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Column("address").(*StructValue).Column("city") != "Kyoto" {
+		t.Fatalf("wrong address %#v", moved.Column("address"))
+	}
+	_, err = table.CompareAndSwap(moved.Key(), map[string]any{"address": r.Column("address")}, map[string]any{"id": moved.Key(), "name": "qux", "address": r.Column("address")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrongs := []map[string]any{
+		// サブカラムが足りない
+		{"id": CounterType(0), "name": "x", "address": map[string]any{"city": "a", "zip": "1234567", "tags": []string{}}},
+		// サブカラムの型が違う
+		{"id": CounterType(0), "name": "x", "address": map[string]any{"city": 1, "zip": "1234567", "tags": []string{}, "geo": map[string]any{"lat": 0.0, "lng": 0.0}}},
+		// マップではない
+		{"id": CounterType(0), "name": "x", "address": "Tokyo"},
+	}
+	for _, w := range wrongs {
+		_, err = table.Insert(w)
+		if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+			t.Fatalf("wrong error %v", err)
+		}
+	}
+
+	tc, err := db.CreateTable("wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	if _, ok := tc.StructColumn("a", 123).(*ErrWrongTag); !ok {
+		t.Fatal("StructColumn accepts not tagged struct")
+	}
+	if _, ok := tc.StructColumn("a", (*Person)(nil)).(*ErrWrongTag); !ok {
+		t.Fatal("StructColumn accepts key")
+	}
+	if err = tc.StructColumn("geo", Geo{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTable_JSONColumn(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Server struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+
+	type Config struct {
+		Id      CounterType     `unkodb:"id,key@Counter"`
+		Server  Server          `unkodb:"server,JSON"`
+		Raw     json.RawMessage `unkodb:"raw,JSON"`
+		Comment string          `unkodb:"comment,JSON"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("configs", (*Config)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ColumnTypeHint(table.Column("server")) != "JSON (json.RawMessage)" {
+		t.Fatalf("wrong hint %s", ColumnTypeHint(table.Column("server")))
+	}
+
+	for i, host := range []string{"alpha", "beta", "gamma"} {
+		_, err = table.Insert(&Config{
+			Server:  Server{Host: host, Port: 8000 + i*100},
+			Raw:     json.RawMessage(fmt.Sprintf(`{ "tags": ["t%d"], "n": %d }`, i, i)),
+			Comment: `"memo"`,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(table *Table) {
+		r, err := table.Find(CounterType(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(r.Column("raw").(json.RawMessage)) != `{"tags":["t1"],"n":1}` {
+			t.Fatalf("wrong raw %s", r.Column("raw"))
+		}
+		var config Config
+		if err := r.MoveTo(&config); err != nil {
+			t.Fatal(err)
+		}
+		if config.Server.Host != "beta" || config.Server.Port != 8100 || config.Comment != `"memo"` {
+			t.Fatalf("wrong config %#v", config)
+		}
+
+		var keys []CounterType
+		err = table.IterateWhereJSON("server", "$.port", func(value any) bool {
+			return value.(float64) >= 8100
+		}, func(r *Record) (breakIteration bool) {
+			keys = append(keys, r.Key().(CounterType))
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(keys) != "[2 3]" {
+			t.Fatalf("wrong keys %v", keys)
+		}
+
+		keys = nil
+		err = table.IterateWhereJSON("raw", "tags[0]", func(value any) bool {
+			return value == "t0"
+		}, func(r *Record) (breakIteration bool) {
+			keys = append(keys, r.Key().(CounterType))
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(keys) != "[1]" {
+			t.Fatalf("wrong keys %v", keys)
+		}
+	}
+
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("configs")
+	check(table)
+
+	called := false
+	err = table.IterateWhereJSON("raw", "$.none", func(value any) bool {
+		called = true
+		return true
+	}, func(r *Record) (breakIteration bool) {
+		return
+	})
+	if err != nil || called {
+		t.Fatalf("wrong iteration %v %v", err, called)
+	}
+	if err = table.IterateWhereJSON("id", "$", nil, nil); err != ErrUnknownColumnName {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = table.IterateWhereJSON("raw", "$.", nil, nil); err != ErrInvalidJSONPath {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = table.CompareAndSwap(CounterType(1), map[string]any{"raw": json.RawMessage(`{"tags": ["t0"], "n": 0}`)}, map[string]any{
+		"id":      CounterType(1),
+		"server":  json.RawMessage(`{}`),
+		"raw":     json.RawMessage(`[]`),
+		"comment": json.RawMessage(`null`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = table.Insert(map[string]any{
+		"id":      CounterType(0),
+		"server":  json.RawMessage(`{"host":`),
+		"raw":     json.RawMessage(`{}`),
+		"comment": json.RawMessage(`""`),
+	})
+	if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+	_, err = table.Insert(&Config{Comment: "not json"})
+	if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestTable_EnumColumn(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Status string
+
+	type Task struct {
+		Priority string `unkodb:"priority,key@Enum[high,middle,low]"`
+		Status   Status `unkodb:"status,Enum[todo,doing,done]"`
+	}
+
+	table, err := db.CreateTableByTaggedStruct("tasks", (*Task)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ColumnTypeHint(table.Key()) != "Enum[high,middle,low] (string)" {
+		t.Fatalf("wrong hint %s", ColumnTypeHint(table.Key()))
+	}
+
+	for _, task := range []Task{{"low", "todo"}, {"high", "done"}, {"middle", "doing"}} {
+		if _, err = table.Insert(&task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	labels := make([]string, 300)
+	for i := range labels {
+		labels[i] = fmt.Sprint("v", i)
+	}
+	tc, err := db.CreateTable("many")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	tc.EnumColumn("value", labels...)
+	many, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if many.Column("value").MaximumDataByteSize() != 2 {
+		t.Fatalf("wrong size %d", many.Column("value").MaximumDataByteSize())
+	}
+	if _, err = many.Insert(Data{Key: CounterType(0), Columns: []any{"v299"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(table *Table) {
+		var keys []any
+		table.IterateAll(func(r *Record) (breakIteration bool) {
+			keys = append(keys, r.Key(), r.Column("status"))
+			return
+		})
+		if fmt.Sprint(keys) != "[high done middle doing low todo]" {
+			t.Fatalf("wrong order %v", keys)
+		}
+		var task Task
+		r, err := table.Find("middle")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = r.MoveTo(&task); err != nil {
+			t.Fatal(err)
+		}
+		if task.Priority != "middle" || task.Status != "doing" {
+			t.Fatalf("wrong task %#v", task)
+		}
+	}
+
+	check(table)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("tasks")
+	check(table)
+
+	r, err := db.Table("many").Find(CounterType(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Column("value") != "v299" {
+		t.Fatalf("wrong value %v", r.Column("value"))
+	}
+
+	_, err = table.Insert(&Task{"high", "pending"})
+	if e, ok := err.(*ErrUnknownEnumValue); !ok || e.Name() != "status" || e.Value != "pending" {
+		t.Fatalf("wrong error %v", err)
+	}
+	_, err = table.Insert(&Task{"urgent", "todo"})
+	if e, ok := err.(*ErrUnknownEnumValue); !ok || e.Name() != "priority" {
+		t.Fatalf("wrong error %v", err)
+	}
+	_, err = table.Update("low", map[string]any{"status": "canceled"})
+	if _, ok := err.(*ErrUnknownEnumValue); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+	_, err = table.Insert(map[string]any{"priority": "high", "status": 1})
+	if _, ok := err.(*ErrUnmatchColumnValueType); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	tc, err = db.CreateTable("wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tc.EnumKey("k"); err != ErrInvalidEnumValues {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = tc.EnumColumn("c", "a", "b", "a"); err != ErrInvalidEnumValues {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	type WrongTask struct {
+		Status string `unkodb:"status,Enum[todo,doing,done]"`
+	}
+	_, err = Typed[WrongTask](table)
+	if _, ok := err.(*ErrWrongTag); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+	type OtherTask struct {
+		Priority string `unkodb:"priority,key@Enum[high,low]"`
+		Status   string `unkodb:"status,Enum[todo,doing,done]"`
+	}
+	_, err = Typed[OtherTask](table)
+	if _, ok := err.(*ErrWrongTag); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestTable_KeyCollation(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tc.SetKeyCollation(NumericCollation); err != ErrNeedToSetAKey {
+		t.Fatalf("wrong error %v", err)
+	}
+	tc.ShortStringKey("name")
+	tc.Int64Column("size")
+	if err = tc.SetKeyCollation(Collation(100)); err != ErrInvalidCollation {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = tc.SetKeyCollation(NumericCollation); err != nil {
+		t.Fatal(err)
+	}
+	files, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err = db.CreateTable("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.FixedSizeShortStringKey("name", 8)
+	tc.Int64Column("age")
+	if err = tc.SetKeyCollation(ASCIICaseInsensitiveCollation); err != nil {
+		t.Fatal(err)
+	}
+	users, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err = db.CreateTable("wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	if err = tc.SetKeyCollation(UnicodeFoldCollation); err != ErrInvalidCollation {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	for i, name := range []string{"file10", "file2", "file1", "file20", "file3"} {
+		if _, err = files.Insert(Data{Key: name, Columns: []any{int64(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, name := range []string{"bob", "Alice", "carol"} {
+		if _, err = users.Insert(Data{Key: name, Columns: []any{int64(20 + i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = users.Insert(Data{Key: "BOB", Columns: []any{int64(30)}}); err != ErrKeyAlreadyExists {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	check := func(files, users *Table) {
+		if files.KeyCollation() != NumericCollation || users.KeyCollation() != ASCIICaseInsensitiveCollation {
+			t.Fatalf("wrong collation %s %s", files.KeyCollation(), users.KeyCollation())
+		}
+		var keys []any
+		files.IterateAll(func(r *Record) (breakIteration bool) {
+			keys = append(keys, r.Key())
+			return
+		})
+		if fmt.Sprint(keys) != "[file1 file2 file3 file10 file20]" {
+			t.Fatalf("wrong order %v", keys)
+		}
+		keys = nil
+		files.IterateRange("file2", "file10", func(r *Record) (breakIteration bool) {
+			keys = append(keys, r.Key())
+			return
+		})
+		if fmt.Sprint(keys) != "[file2 file3 file10]" {
+			t.Fatalf("wrong range %v", keys)
+		}
+		keys = nil
+		users.IterateAll(func(r *Record) (breakIteration bool) {
+			keys = append(keys, r.Key())
+			return
+		})
+		if fmt.Sprint(keys) != "[Alice    bob      carol   ]" {
+			t.Fatalf("wrong order %q", keys)
+		}
+		r, err := users.Find("BOB")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r == nil || r.Key() != "bob     " || r.Column("age") != int64(20) {
+			t.Fatalf("wrong record %v", r)
+		}
+	}
+
+	check(files, users)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(db.Table("files"), db.Table("users"))
+}
+
+func TestTable_KeyDescending(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Int64Key("at")
+	tc.ShortStringColumn("message")
+	if err = tc.SetKeyDescending(); err != nil {
+		t.Fatal(err)
+	}
+	logs, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tc.SetKeyDescending(); err != ErrInvalidOperation {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	for _, at := range []int64{3, 1, 5, 2, 4} {
+		if _, err = logs.Insert(Data{Key: at, Columns: []any{fmt.Sprint("log", at)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tc, err = db.CreateTable("bulk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Int64Key("at")
+	tc.SetKeyDescending()
+	bulk, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := []int64{30, 20, 10}
+	_, err = bulk.BulkLoad(func() (data any, ok bool) {
+		if len(list) > 0 {
+			data, ok = Data{Key: list[0]}, true
+			list = list[1:]
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(logs, bulk *Table) {
+		if !logs.KeyDescending() {
+			t.Fatal("not descending")
+		}
+		var keys []any
+		callback := func(r *Record) (breakIteration bool) {
+			keys = append(keys, r.Key())
+			return
+		}
+		logs.IterateAll(callback)
+		if fmt.Sprint(keys) != "[5 4 3 2 1]" {
+			t.Fatalf("wrong order %v", keys)
+		}
+		keys = nil
+		logs.IterateBackAll(callback)
+		if fmt.Sprint(keys) != "[1 2 3 4 5]" {
+			t.Fatalf("wrong order %v", keys)
+		}
+		keys = nil
+		logs.IterateRange(int64(2), int64(4), callback)
+		if fmt.Sprint(keys) != "[4 3 2]" {
+			t.Fatalf("wrong range %v", keys)
+		}
+		keys = nil
+		logs.IterateBackRange(int64(2), int64(4), callback)
+		if fmt.Sprint(keys) != "[2 3 4]" {
+			t.Fatalf("wrong range %v", keys)
+		}
+		keys = nil
+		logs.IterateRangeKeys(nil, int64(3), func(key any) (breakIteration bool) {
+			keys = append(keys, key)
+			return
+		})
+		if fmt.Sprint(keys) != "[3 2 1]" {
+			t.Fatalf("wrong range %v", keys)
+		}
+		keys = nil
+		bulk.IterateAll(callback)
+		if fmt.Sprint(keys) != "[30 20 10]" {
+			t.Fatalf("wrong order %v", keys)
+		}
+		r, err := logs.Find(int64(4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r == nil || r.Column("message") != "log4" {
+			t.Fatalf("wrong record %v", r)
+		}
+	}
+
+	check(logs, bulk)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs = db.Table("logs")
+	check(logs, db.Table("bulk"))
+
+	if err = logs.Delete(int64(5)); err != nil {
+		t.Fatal(err)
+	}
+	r, err := logs.Find(int64(5))
+	if err != nil || r != nil {
+		t.Fatalf("wrong find %v %v", r, err)
+	}
+}
+
+func TestTable_LongKeys(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Page struct {
+		URL   string `unkodb:"url,key@LongString"`
+		Title string `unkodb:"title,ShortString"`
+	}
+
+	pages, err := db.CreateTableByTaggedStruct("pages", (*Page)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tc.LongBytesKey("hash"); err != nil {
+		t.Fatal(err)
+	}
+	tc.Int64Column("size")
+	blobs, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := "https://example.com/" + strings.Repeat("a", 1000) + "/"
+	for i := 0; i < 20; i++ {
+		page := &Page{URL: fmt.Sprintf("%s%02d", prefix, i), Title: fmt.Sprint("page", i)}
+		if _, err = pages.Insert(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	longest := strings.Repeat("z", 65535)
+	if _, err = pages.Insert(&Page{URL: longest, Title: "longest"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pages.Insert(&Page{URL: longest + "z", Title: "too long"}); err == nil {
+		t.Fatal("no error")
+	}
+	if _, err = pages.Insert(&Page{URL: prefix + "05", Title: "duplicate"}); err != ErrKeyAlreadyExists {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		hash := bytes.Repeat([]byte{byte(9 - i)}, 300+i)
+		if _, err = blobs.Insert(Data{Key: hash, Columns: []any{int64(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(pages, blobs *Table) {
+		r, err := pages.Find(prefix + "07")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r == nil || r.Column("title") != "page7" {
+			t.Fatalf("wrong record %v", r)
+		}
+		r, err = pages.Find(longest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r == nil || r.Column("title") != "longest" {
+			t.Fatalf("wrong record %v", r)
+		}
+		var titles []any
+		pages.IterateRange(prefix+"10", prefix+"12", func(r *Record) (breakIteration bool) {
+			titles = append(titles, r.Column("title"))
+			return
+		})
+		if fmt.Sprint(titles) != "[page10 page11 page12]" {
+			t.Fatalf("wrong range %v", titles)
+		}
+		var sizes []any
+		blobs.IterateAll(func(r *Record) (breakIteration bool) {
+			sizes = append(sizes, r.Column("size"))
+			return
+		})
+		if fmt.Sprint(sizes) != "[9 8 7 6 5 4 3 2 1 0]" {
+			t.Fatalf("wrong order %v", sizes)
+		}
+		r, err = blobs.Find(bytes.Repeat([]byte{4}, 305))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r == nil || r.Column("size") != int64(5) {
+			t.Fatalf("wrong record %v", r)
+		}
+	}
+
+	check(pages, blobs)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages = db.Table("pages")
+	check(pages, db.Table("blobs"))
+
+	for i := 0; i < 20; i += 2 {
+		if err = pages.Delete(fmt.Sprintf("%s%02d", prefix, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := 0
+	pages.IterateAll(func(r *Record) (breakIteration bool) {
+		count++
+		return
+	})
+	if count != 11 {
+		t.Fatalf("wrong count %d", count)
+	}
+}
+
+func TestTable_Counter64(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Event struct {
+		Id   Counter64Type `unkodb:"id,key@Counter64"`
+		Name string        `unkodb:"name,ShortString"`
+	}
+
+	tc, err := db.CreateTable("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tc.SetCounterOptions(1000, 10); err != ErrNeedToSetAKey {
+		t.Fatalf("wrong error %v", err)
+	}
+	tc.Counter64Key("id")
+	tc.ShortStringColumn("name")
+	if err = tc.SetCounterOptions(0, 10); err != ErrInvalidCounterValue {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = tc.SetCounterOptions(1000, 10); err != nil {
+		t.Fatal(err)
+	}
+	events, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err = db.CreateTable("counts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	counts, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err = db.CreateTable("wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Int64Key("id")
+	if err = tc.SetCounterOptions(1, 1); err != ErrKeyIsNotCounter {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if _, err = events.Insert(&Event{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if id, err := events.NextCounter64ID(); err != nil || id != 1020 {
+		t.Fatalf("wrong next id %d %v", id, err)
+	}
+	if _, err = events.NextCounterID(); err != ErrKeyIsNotCounter {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = events.SetCounter(1 << 40); err != nil {
+		t.Fatal(err)
+	}
+	r, err := events.Insert(&Event{Name: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Key() != uint64(1<<40) {
+		t.Fatalf("wrong key %v", r.Key())
+	}
+
+	if err = counts.SetCounter(math.MaxUint32 + 1); err != ErrInvalidCounterValue {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = counts.SetCounter(0); err != ErrInvalidCounterValue {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err = counts.SetCounter(math.MaxUint32); err != nil {
+		t.Fatal(err)
+	}
+	r, err = counts.Insert(Data{Key: CounterType(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Key() != uint32(math.MaxUint32) {
+		t.Fatalf("wrong key %v", r.Key())
+	}
+
+	check := func(events, counts *Table) {
+		var keys []any
+		events.IterateAll(func(r *Record) (breakIteration bool) {
+			keys = append(keys, r.Key(), r.Column("name"))
+			return
+		})
+		if fmt.Sprint(keys) != fmt.Sprint("[1000 a 1010 b ", uint64(1<<40), " c]") {
+			t.Fatalf("wrong records %v", keys)
+		}
+		if id, err := events.NextCounter64ID(); err != nil || id != 1<<40+10 {
+			t.Fatalf("wrong next id %d %v", id, err)
+		}
+		if _, err := counts.NextCounterID(); err != ErrCounterOverflow {
+			t.Fatalf("wrong error %v", err)
+		}
+		if _, err := counts.Insert(Data{Key: CounterType(0)}); err != ErrCounterOverflow {
+			t.Fatalf("wrong error %v", err)
+		}
+	}
+
+	check(events, counts)
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = db.Table("events")
+	check(events, db.Table("counts"))
+
+	if err = events.SetCounter(math.MaxUint64 - 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = events.Insert(&Event{Name: "last"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = events.Insert(&Event{Name: "over"}); err != ErrCounterOverflow {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestTable_ManyColumns(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err := db.CreateTable("wide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	data := make(map[string]any)
+	for i := 0; i < MaximumColumnCountWithoutKey; i++ {
+		name := fmt.Sprintf("c%d", i)
+		if err = tc.Int16Column(name); err != nil {
+			t.Fatal(err)
+		}
+		data[name] = int16(i)
+	}
+	if err = tc.Int16Column("over"); err != ErrColumnCountIsFull {
+		t.Fatalf("wrong error %v", err)
+	}
+	table, err := tc.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Columns()) != MaximumColumnCountWithoutKey {
+		t.Fatalf("wrong column count %d", len(table.Columns()))
+	}
+	if _, err = table.Insert(data); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := []reflect.StructField{{
+		Name: "Id",
+		Type: reflect.TypeOf(CounterType(0)),
+		Tag:  `unkodb:"id,key@Counter"`,
+	}}
+	for i := 0; i <= MaximumColumnCountWithoutKey; i++ {
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("F%d", i),
+			Type: reflect.TypeOf(int8(0)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`unkodb:"f%d,Int8"`, i)),
+		})
+	}
+	tooWide := reflect.New(reflect.StructOf(fields)).Interface()
+	if _, err = db.CreateTableByTaggedStruct("too_wide", tooWide); err != ErrColumnCountIsFull {
+		t.Fatalf("wrong error %v", err)
+	}
+	taggedWide := reflect.New(reflect.StructOf(fields[:len(fields)-1])).Interface()
+	if _, err = db.CreateTableByTaggedStruct("tagged_wide", taggedWide); err != nil {
+		t.Fatal(err)
+	}
+
+	tc, err = db.CreateTable("long_names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.CounterKey("id")
+	for i := 0; i < 300; i++ {
+		name := fmt.Sprintf("%03d%s", i, strings.Repeat("x", MaximumColumnNameByteSize-3))
+		if err = tc.Int8Column(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = tc.Create(); err != ErrTableSpecIsTooLarge {
+		t.Fatalf("wrong error %v", err)
+	}
+	if db.Table("long_names") != nil {
+		t.Fatal("table must not be created")
+	}
+
+	db, err = Open(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table = db.Table("wide")
+	if table == nil {
+		t.Fatal("not found table")
+	}
+	if len(table.Columns()) != MaximumColumnCountWithoutKey {
+		t.Fatalf("wrong column count %d", len(table.Columns()))
+	}
+	r, err := table.Find(CounterType(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil {
+		t.Fatal("not found record")
+	}
+	for i := 0; i < MaximumColumnCountWithoutKey; i++ {
+		if v := r.Column(fmt.Sprintf("c%d", i)); v != int16(i) {
+			t.Fatalf("wrong value %v (column c%d)", v, i)
+		}
+	}
+	if table = db.Table("tagged_wide"); table == nil || len(table.Columns()) != MaximumColumnCountWithoutKey {
+		t.Fatal("wrong table")
+	}
+}
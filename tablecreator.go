@@ -3,6 +3,11 @@
 
 package unkodb
 
+import (
+	"math"
+	"reflect"
+)
+
 // 新しいテーブルの作成に使用される。
 //
 //	var tc *TableCreator
@@ -18,6 +23,7 @@ type TableCreator struct {
 	key           keyColumn
 	columns       []Column
 	columnNameMap map[string]bool
+	options       tableOptions
 	created       bool
 }
 
@@ -55,17 +61,8 @@ func (tc *TableCreator) Create() (table *Table, err error) {
 		err = ErrNeedToSetAKey
 		return
 	}
-	var dataSize uint64 = 0
-	for _, col := range tc.columns {
-		dataSize += col.MaximumDataByteSize()
-	}
-	var dataSeparation dataSeparationState
-	if dataSize <= noSeparationMaximumDataSize {
-		dataSeparation = dataSeparationDisabled
-	} else {
-		dataSeparation = dataSeparationEnabled
-	}
-	table, err = tc.db.newTable(tc.name, tc.key, tc.columns, dataSeparation)
+	dataSeparation := dataSeparationStateOf(tc.columns)
+	table, err = tc.db.newTable(tc.name, tc.key, tc.columns, dataSeparation, tc.options)
 	if err != nil {
 		return
 	}
@@ -78,6 +75,120 @@ func (tc *TableCreator) Create() (table *Table, err error) {
 	return
 }
 
+// カラムのデータサイズの合計からデータを別の領域に分けて保存するかを決める
+func dataSeparationStateOf(columns []Column) dataSeparationState {
+	var dataSize uint64 = 0
+	for _, col := range columns {
+		dataSize += col.MaximumDataByteSize()
+	}
+	if dataSize <= noSeparationMaximumDataSize {
+		return dataSeparationDisabled
+	} else {
+		return dataSeparationEnabled
+	}
+}
+
+// データごとにバージョン番号を保持するテーブルにする。
+// バージョン番号はデータの挿入時に1となり、置き換えるたびに1ずつ増える。
+// バージョン番号はRecordのVersionメソッドで取得でき、TableのReplaceWithVersionやDeleteWithVersionで楽観的ロックのように使える。
+// バージョン番号の分だけデータごとに8バイト多くファイルの領域を使用する。
+// テーブル作成後に呼び出した場合はErrInvalidOperationのエラーが返る。
+//
+//	tc, _ := db.CreateTable("my_book_table")
+//	tc.CounterKey("id")
+//	tc.ShortStringColumn("title")
+//	tc.EnableRowVersion()
+//	table, _ := tc.Create()
+func (tc *TableCreator) EnableRowVersion() error {
+	if tc.created {
+		return ErrInvalidOperation
+	}
+	tc.options.rowVersion = true
+	return nil
+}
+
+// CounterやCounter64のキーで付与するキーの開始値と増分を設定する。
+// 設定しない場合は開始値も増分も1となる。
+// CounterKeyやCounter64Keyでキーを設定した後に呼び出す。
+// 開始値と増分はファイルに保存される。
+// キーの値がキーのカラム型の最大値を超える場合は一周せずにInsertでErrCounterOverflowのエラーとなる。
+// キーが設定されていない場合はErrNeedToSetAKey、キーがCounterでもCounter64でもない場合はErrKeyIsNotCounter、
+// startやstepが0やキーのカラム型の最大値を超える場合はErrInvalidCounterValue、
+// テーブル作成後に呼び出した場合はErrInvalidOperationのエラーが返る。
+//
+//	tc, _ := db.CreateTable("my_event_table")
+//	tc.Counter64Key("id")
+//	tc.SetCounterOptions(1000, 10) // 1000, 1010, 1020, ...
+//	table, _ := tc.Create()
+func (tc *TableCreator) SetCounterOptions(start, step uint64) error {
+	if tc.created {
+		return ErrInvalidOperation
+	}
+	if tc.key == nil {
+		return ErrNeedToSetAKey
+	}
+	var max uint64
+	switch tc.key.Type() {
+	case Counter:
+		max = math.MaxUint32
+	case Counter64:
+		max = math.MaxUint64
+	default:
+		return ErrKeyIsNotCounter
+	}
+	if start == 0 || step == 0 || start > max || step > max {
+		return ErrInvalidCounterValue
+	}
+	tc.options.counterStart = start
+	tc.options.counterStep = step
+	return nil
+}
+
+// キーの降順でデータを並べるテーブルにする。
+// IterateAllやIterateRangeなどはキーの大きい順に、IterateBackAllやIterateBackRangeなどはキーの小さい順にデータを辿るようになる。
+// IterateRangeなどのlowerKeyとupperKeyは昇順のテーブルと同じくキーの値の下限と上限を指定する。
+// BulkLoadではデータをキーの降順に並べて渡す必要がある。
+// 降順であることはファイルに保存される。
+// テーブル作成後に呼び出した場合はErrInvalidOperationのエラーが返る。
+//
+//	tc, _ := db.CreateTable("my_log_table")
+//	tc.TimestampKey("at")
+//	tc.LongStringColumn("message")
+//	tc.SetKeyDescending()
+//	table, _ := tc.Create()
+func (tc *TableCreator) SetKeyDescending() error {
+	if tc.created {
+		return ErrInvalidOperation
+	}
+	tc.options.descendingKey = true
+	return nil
+}
+
+// キーの照合順序を設定する。
+// ShortStringやFixedSizeShortStringやLongStringのキーを設定した後に呼び出す。
+// 照合順序はファイルに保存され、Find、IterateRange、Insertでの重複の判定などのキーの比較に使われる。
+// 照合順序で等しいと判定されるキー(ASCIICaseInsensitiveCollationでの"abc"と"ABC"など)は同じキーとして扱われる。
+// キーが設定されていない場合はErrNeedToSetAKey、文字列のキーではない場合や不正な照合順序の場合はErrInvalidCollation、
+// テーブル作成後に呼び出した場合はErrInvalidOperationのエラーが返る。
+//
+//	tc, _ := db.CreateTable("my_file_table")
+//	tc.ShortStringKey("file_name")
+//	tc.SetKeyCollation(unkodb.NumericCollation)
+//	table, _ := tc.Create()
+func (tc *TableCreator) SetKeyCollation(collation Collation) error {
+	if tc.created {
+		return ErrInvalidOperation
+	}
+	if tc.key == nil {
+		return ErrNeedToSetAKey
+	}
+	if !collation.isValid() || !setKeyCollation(tc.key, collation) {
+		return ErrInvalidCollation
+	}
+	tc.options.keyCollation = collation
+	return nil
+}
+
 func (tc *TableCreator) has(columnName string) bool {
 	_, ok := tc.columnNameMap[columnName]
 	return ok
@@ -265,6 +376,27 @@ func (tc *TableCreator) CounterKey(newColumnName string) error {
 	})
 }
 
+// Counter64のキーを設定する。
+// 値はGoのuint64の型として扱われる。
+// Counterと同じくデータが挿入時にファイルにCounter64の値が書き込まれる。データの挿入ごとに1ずつ値が増えていく（最初は1から始まる）。unkodb.Counter64Typeはuint64のエイリアス。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) Counter64Key(newColumnName string) error {
+	return tc.setKey(&counter64Column{
+		name: newColumnName,
+	})
+}
+
+// Float32のキーを設定する。
+// 値はGoのfloat32として扱われる。
+// キーの順序は-0と+0やNaNも区別した全順序で、-NaN < -Inf < 負の数 < -0 < +0 < 正の数 < +Inf < +NaN となる(math.NaN()は+NaN)。
+// そのため-0と+0は別のキーとして扱われ、Findなどでは-0で+0のデータを見つけることはできない。NaNはビット列が一致する場合のみ同じキーとなる。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) Float32Key(newColumnName string) error {
+	return tc.setKey(&floatColumn[float32]{
+		name: newColumnName,
+	})
+}
+
 // Float32のカラムを追加する。
 // カラム名に不正がある場合に対応したエラーが返る。
 func (tc *TableCreator) Float32Column(newColumnName string) error {
@@ -273,6 +405,17 @@ func (tc *TableCreator) Float32Column(newColumnName string) error {
 	})
 }
 
+// Float64のキーを設定する。
+// 値はGoのfloat64として扱われる。
+// キーの順序は-0と+0やNaNも区別した全順序で、-NaN < -Inf < 負の数 < -0 < +0 < 正の数 < +Inf < +NaN となる(math.NaN()は+NaN)。
+// そのため-0と+0は別のキーとして扱われ、Findなどでは-0で+0のデータを見つけることはできない。NaNはビット列が一致する場合のみ同じキーとなる。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) Float64Key(newColumnName string) error {
+	return tc.setKey(&floatColumn[float64]{
+		name: newColumnName,
+	})
+}
+
 // Float64のカラムを追加する。
 // カラム名に不正がある場合に対応したエラーが返る。
 func (tc *TableCreator) Float64Column(newColumnName string) error {
@@ -331,6 +474,17 @@ func (tc *TableCreator) FixedSizeShortStringColumn(newColumnName string, size ui
 	})
 }
 
+// LongStringのキーを設定する。
+// 値はGoのstringとして扱われる。
+// 内部的にはstringを[]byteキャストした形で保存される。0～65535バイトに収まる必要がある。バイト長もデータごとに一緒に保存される。キーとして使う場合は`strings.Compare`が順序に使用される。
+// キーはデータごとに全体がそのまま保存され、キーの比較も全体で行われる(キーが長いほどファイルの領域とキーの比較の時間を多く使う)。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) LongStringKey(newColumnName string) error {
+	return tc.setKey(&longStringColumn{
+		name: newColumnName,
+	})
+}
+
 // LongStringのカラムを追加する。
 // 値はGoのstringとして扱われる。
 // 内部的にはstringを[]byteキャストした形で保存される。0～65535バイトに収まる必要がある。バイト長もデータごとに一緒に保存される。
@@ -416,6 +570,17 @@ func (tc *TableCreator) FixedSizeShortBytesColumn(newColumnName string, size uin
 	})
 }
 
+// LongBytesのキーを設定する。
+// 値はGoの[]byteとして扱われる。
+// 0～65535バイトに収まる必要がある。バイト長もデータごとに一緒に保存される。キーとして使う場合はbytes.Compareが順序に使用される。
+// キーはデータごとに全体がそのまま保存され、キーの比較も全体で行われる(キーが長いほどファイルの領域とキーの比較の時間を多く使う)。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) LongBytesKey(newColumnName string) error {
+	return tc.setKey(&longBytesColumn{
+		name: newColumnName,
+	})
+}
+
 // LongBytesのカラムを追加する。
 // 値はGoの[]byteとして扱われる。
 // 0～65535バイトに収まる必要がある。バイト長もデータごとに一緒に保存される。
@@ -450,3 +615,219 @@ func (tc *TableCreator) BlobColumn(newColumnName string) error {
 		name: newColumnName,
 	})
 }
+
+// Boolのカラムを追加する。
+// 値はGoのboolとして扱われる。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) BoolColumn(newColumnName string) error {
+	return tc.addColumn(&boolColumn{
+		name: newColumnName,
+	})
+}
+
+// Timestampのキーを設定する。
+// 値はGoのtime.Timeとして扱われ、UTCにしてナノ秒まで保存される(モノトニッククロックの情報は保存されない)。
+// キーの順序は時刻の順序となる。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) TimestampKey(newColumnName string) error {
+	return tc.setKey(&timestampColumn{
+		name: newColumnName,
+	})
+}
+
+// Timestampのカラムを追加する。
+// 値はGoのtime.Timeとして扱われ、UTCにしてナノ秒まで保存される(モノトニッククロックの情報は保存されない)。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) TimestampColumn(newColumnName string) error {
+	return tc.addColumn(&timestampColumn{
+		name: newColumnName,
+	})
+}
+
+// Dateのカラムを追加する。
+// 値はGoのtime.Timeとして扱われ、time.Timeのロケーションでの年月日だけが保存される。
+// 読み込んだ値はその日付のUTCの0時0分0秒のtime.Timeとなる。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) DateColumn(newColumnName string) error {
+	return tc.addColumn(&dateColumn{
+		name: newColumnName,
+	})
+}
+
+// Decimalのキーを設定する。
+// precisionは1～18の範囲で整数部と小数部を合わせた最大桁数、scaleは0～precisionの範囲で小数点以下の桁数を指定する。
+// 値はunkodb.Decimalとして扱われ、内部的にはscaleに合わせた整数値(int64)として保存される。キーの順序は数値の順序となる。
+// カラム名やprecisionやscaleの指定に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) DecimalKey(newColumnName string, precision, scale uint8) error {
+	if precision < 1 || MaximumDecimalPrecision < precision || precision < scale {
+		return ErrWrongDecimalPrecision
+	}
+	return tc.setKey(&decimalColumn{
+		name:      newColumnName,
+		precision: precision,
+		scale:     scale,
+	})
+}
+
+// Decimalのカラムを追加する。
+// precisionは1～18の範囲で整数部と小数部を合わせた最大桁数、scaleは0～precisionの範囲で小数点以下の桁数を指定する。
+// 値はunkodb.Decimalとして扱われ、内部的にはscaleに合わせた整数値(int64)として保存される。
+// 小数点以下の桁がscaleに収まらない値やprecisionの桁数を超える値は受け付けない。
+// カラム名やprecisionやscaleの指定に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) DecimalColumn(newColumnName string, precision, scale uint8) error {
+	if precision < 1 || MaximumDecimalPrecision < precision || precision < scale {
+		return ErrWrongDecimalPrecision
+	}
+	return tc.addColumn(&decimalColumn{
+		name:      newColumnName,
+		precision: precision,
+		scale:     scale,
+	})
+}
+
+// UUIDのキーを設定する。
+// 値はunkodb.UUIDとして扱われ、16バイトがそのままのバイト順で保存される。キーとして使う場合は`bytes.Compare`が順序に使用される。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) UUIDKey(newColumnName string) error {
+	return tc.setKey(&uuidColumn{
+		name: newColumnName,
+	})
+}
+
+// UUIDのカラムを追加する。
+// 値はunkodb.UUIDとして扱われ、16バイトがそのままのバイト順で保存される。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) UUIDColumn(newColumnName string) error {
+	return tc.addColumn(&uuidColumn{
+		name: newColumnName,
+	})
+}
+
+// Int128のキーを設定する。
+// 値はunkodb.Int128として扱われる。キーの順序は数値の順序となる。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) Int128Key(newColumnName string) error {
+	return tc.setKey(&int128Column{
+		name: newColumnName,
+	})
+}
+
+// Int128のカラムを追加する。
+// 値はunkodb.Int128として扱われる。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) Int128Column(newColumnName string) error {
+	return tc.addColumn(&int128Column{
+		name: newColumnName,
+	})
+}
+
+// Uint128のキーを設定する。
+// 値はunkodb.Uint128として扱われる。キーの順序は数値の順序となる。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) Uint128Key(newColumnName string) error {
+	return tc.setKey(&uint128Column{
+		name: newColumnName,
+	})
+}
+
+// Uint128のカラムを追加する。
+// 値はunkodb.Uint128として扱われる。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) Uint128Column(newColumnName string) error {
+	return tc.addColumn(&uint128Column{
+		name: newColumnName,
+	})
+}
+
+// Listのカラムを追加する。
+// elementTypeには要素のカラム型としてInt8、Uint8、Int16、Uint16、Int32、Uint32、Int64、Uint64、Float32、Float64、Bool、ShortString、ShortBytes、Timestampのいずれかを指定する。
+// maxCountには要素数の上限を1～65535の範囲の中から指定する。
+// 値は要素のカラム型に対応したGoの型のスライス([]int64や[]stringや[][]byteなど)として扱われる。
+// 要素数(2バイト)と各要素が要素のカラム型と同じ形式で保存される。
+// カラム名や要素のカラム型や要素数の上限の指定に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) ListColumn(newColumnName string, elementType ColumnType, maxCount uint16) error {
+	if maxCount == 0 {
+		return ErrSizeMustBePositiveValue
+	}
+	col, ok := newListColumn(newColumnName, elementType, maxCount)
+	if !ok {
+		return ErrInvalidListElementType
+	}
+	return tc.addColumn(col)
+}
+
+// JSONのカラムを追加する。
+// 値はjson.RawMessageとして扱われ、挿入時などにJSONとして正しいかが検査される(不正な場合はErrUnmatchColumnValueTypeのエラーとなる)。
+// 空白を除いた形(json.Compactを適用した形)で0～1073741823バイトに収まる必要がある。バイト長もデータごとに保存される。
+// カラム名に不正がある場合に対応したエラーが返る。
+func (tc *TableCreator) JSONColumn(newColumnName string) error {
+	return tc.addColumn(&jsonColumn{
+		name: newColumnName,
+	})
+}
+
+// Enumのキーを設定する。
+// valuesには値として使える文字列を1～65535個指定する(それぞれ0～255バイト)。
+// キーの順序はvaluesでの宣言順となる。
+// カラム名に不正がある場合に対応したエラーが返る。valuesが空や重複がある場合などはErrInvalidEnumValuesのエラーが返る。
+func (tc *TableCreator) EnumKey(newColumnName string, values ...string) error {
+	col, ok := newEnumColumn(newColumnName, append([]string(nil), values...))
+	if !ok {
+		return ErrInvalidEnumValues
+	}
+	return tc.setKey(col)
+}
+
+// Enumのカラムを追加する。
+// valuesには値として使える文字列を1～65535個指定する(それぞれ0～255バイト)。
+// 値はstringとして扱われ、valuesでの宣言順の序数で保存される(値の数が256以下なら1バイト、それより多い場合は2バイト)。
+// valuesにない文字列を書き込もうとした場合はErrUnknownEnumValueのエラーとなる。
+// カラム名に不正がある場合に対応したエラーが返る。valuesが空や重複がある場合などはErrInvalidEnumValuesのエラーが返る。
+//
+//	tc.EnumColumn("status", "draft", "published", "archived")
+func (tc *TableCreator) EnumColumn(newColumnName string, values ...string) error {
+	col, ok := newEnumColumn(newColumnName, append([]string(nil), values...))
+	if !ok {
+		return ErrInvalidEnumValues
+	}
+	return tc.addColumn(col)
+}
+
+// Structのカラムを追加する。
+// taggedStructにはサブカラムの定義となるunkodbタグ付きの構造体のインスタンス(nilポインタでもよい)を渡す。
+// サブカラムのカラム型にはCounter以外のカラム型を指定でき、タグでキーを指定することはできない。
+// 値はサブカラム名をキーとしたmap[string]anyとして渡す(RecordのColumnではサブカラムの値を参照する*StructValueが返る)。
+// unkodbタグ付きの構造体でデータをやりとりする場合はネストした構造体のフィールドとして扱える。
+// サブカラムの値がそれぞれのカラム型と同じ形式で順に保存される。
+// カラム名や構造体のタグの指定に不正がある場合に対応したエラーが返る。
+//
+//	type Address struct {
+//		City string `unkodb:"city,ShortString"`
+//		Zip  string `unkodb:"zip,FixedSizeShortString[7]"`
+//	}
+//	tc.StructColumn("address", (*Address)(nil))
+func (tc *TableCreator) StructColumn(newColumnName string, taggedStruct any) error {
+	if tc.created {
+		return ErrInvalidOperation
+	}
+	columns, err := createStructSubColumns(reflect.TypeOf(taggedStruct))
+	if err != nil {
+		return err
+	}
+	return tc.addColumn(newStructColumn(newColumnName, columns))
+}
+
+// RegisterColumnTypeで登録した独自のカラム型のカラムを追加する。
+// typeNameには登録した名前を指定する。名前はカラムの情報としてファイルに保存される。
+// 値の検査や変換には登録したColumnCodecが使われ、変換したバイト列の長さもデータごとに保存される。
+// カラム名に不正がある場合に対応したエラーが返る。typeNameが登録されていない場合はErrUnregisteredColumnTypeのエラーが返る。
+func (tc *TableCreator) CustomColumn(newColumnName, typeName string) error {
+	if tc.created {
+		return ErrInvalidOperation
+	}
+	col, err := newCustomColumn(newColumnName, typeName)
+	if err != nil {
+		return err
+	}
+	return tc.addColumn(col)
+}
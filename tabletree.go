@@ -5,6 +5,7 @@ package unkodb
 
 import (
 	"bytes"
+	"math/bits"
 
 	"github.com/neetsdkasu/avltree"
 )
@@ -27,6 +28,10 @@ type tableTree struct {
 	// 木のすべてのノードが参照されるような事態があったらヤバイ（全ノードのイテレーション操作とか）
 	useCache bool
 	cache    tableTreeNodeCache
+	// Batchの間はノードの書き込みを終了時まで遅らせる
+	batching bool
+	// Batchの間に木から外れたノードのセグメントの位置(Batchの終了時に空き領域にする)
+	releasedAddresses []int
 }
 
 type tableTreeNode struct {
@@ -39,6 +44,7 @@ type tableTreeNode struct {
 	updated               bool
 	separationDataAddress int
 	separationDataSegment *segmentBuffer
+	version               uint64
 }
 
 type tableTreeValue = map[string]any
@@ -82,6 +88,35 @@ func (node *tableTreeNode) toNode() avltree.Node {
 	}
 }
 
+func (node *tableTreeNode) toRecord() *Record {
+	return node.toProjectedRecord(nil)
+}
+
+// 指定したカラムとキーだけを持つRecordを作る（wantedがnilの場合は全てのカラムを持つ）
+func (node *tableTreeNode) toProjectedRecord(wanted map[string]bool) *Record {
+	table := node.tree.table
+	data := node.readValue(wanted)
+	return &Record{
+		table:     table,
+		data:      data,
+		version:   node.version,
+		projected: len(data) < len(table.columns)+1,
+	}
+}
+
+// avltreeのコールバックで受け取ったキーと値からRecordを作る
+// バージョン番号は探索経路上のキャッシュ済みのノードから取得するのでファイルの読み込みは発生しない
+func (tree *tableTree) callbackRecord(key avltree.Key, value any) *Record {
+	r := &Record{
+		table: tree.table,
+		data:  value.(tableTreeValue),
+	}
+	if tree.table.options.rowVersion {
+		r.version = unwrapTableTreeNode(avltree.Find(tree, key)).version
+	}
+	return r
+}
+
 func (node *tableTreeNode) position() int {
 	if node == nil {
 		return nullAddress
@@ -91,6 +126,9 @@ func (node *tableTreeNode) position() int {
 }
 
 func (tree *tableTree) flush() (err error) {
+	if tree.batching {
+		return
+	}
 	for _, node := range tree.cache {
 		err = node.flush()
 		if err != nil {
@@ -110,7 +148,7 @@ func (node *tableTreeNode) flush() (err error) {
 	if node == nil || !node.updated {
 		return
 	}
-	buf := node.seg.Buffer()[:tableTreeNodeHeaderByteSize]
+	buf := node.seg.Buffer()[:node.tree.table.nodeHeaderByteSize()]
 	w := newByteEncoder(newByteSliceWriter(buf), fileByteOrder)
 	err = w.Int32(int32(node.leftChildAddress))
 	if err != nil {
@@ -124,34 +162,44 @@ func (node *tableTreeNode) flush() (err error) {
 	if err != nil {
 		bug.Panic(err)
 	}
+	if node.tree.table.options.rowVersion {
+		err = w.Uint64(node.version)
+		if err != nil {
+			bug.Panic(err)
+		}
+	}
+	manager := node.tree.segManager
 	if node.separationDataSegment != nil {
 		err = node.separationDataSegment.Flush()
 		if err != nil {
+			manager.InvalidateSegment(node.separationDataAddress)
 			return
 		}
+		manager.CacheFlushedSegment(node.separationDataSegment, nil)
 	}
 	err = node.seg.Flush()
+	if err != nil {
+		manager.InvalidateSegment(node.position())
+		return
+	}
+	node.updated = false
+	manager.CacheFlushedSegment(node.seg, node.cachedNode())
 	return
 }
 
-func (node *tableTreeNode) writeValue(record tableTreeValue) {
+// ノードにデータを書き込む
+// recordはカラム名をキーとしたマップ(tableTreeValue)もしくはEncoderで書き込まれたデータ(*encodedRecord)
+func (node *tableTreeNode) writeValue(record any) {
 	tree := node.tree
-	buf := node.seg.Buffer()[tableTreeNodeHeaderByteSize:]
+	buf := node.seg.Buffer()[tree.table.nodeHeaderByteSize():]
 	w := newByteEncoder(newByteSliceWriter(buf), fileByteOrder)
-	keyValue := record[tree.table.key.Name()]
+	keyValue, _ := tree.table.recordKeyValue(record)
 	err := tree.table.key.write(w, keyValue)
 	if err != nil {
 		bug.Panicf("tableTreeNode.writeValue: key %#v %v", tree.table.key, err)
 	}
 	if tree.table.dataSeparation.Enabled() {
-		var segmentByteSize uint64 = 0
-		for _, col := range tree.table.columns {
-			if colValue, ok := record[col.Name()]; !ok {
-				bug.Panicf("tableTree.writeValue: not found value of %s", col.Name())
-			} else {
-				segmentByteSize += col.byteSizeHint(colValue)
-			}
-		}
+		segmentByteSize := tree.table.recordColumnsByteSize(record)
 		segmentByteSize = maxValue(segmentByteSize, minimumSegmentByteSize)
 		if node.separationDataAddress == nullAddress {
 			seg, err := tree.segManager.EmptySegment(segmentByteSize)
@@ -172,8 +220,14 @@ func (node *tableTreeNode) writeValue(record tableTreeValue) {
 			if err != nil {
 				panic(err)
 			}
+			oldAddress := node.separationDataAddress
 			node.separationDataAddress = seg.Position()
 			node.separationDataSegment = seg
+			// 収まらなくなった古いデータ領域は再利用できるよう解放しておく
+			err = tree.releaseSegmentByAddress(oldAddress)
+			if err != nil {
+				panic(err)
+			}
 		} else {
 			err = node.separationDataSegment.LoadFullSegment()
 			if err != nil {
@@ -187,19 +241,13 @@ func (node *tableTreeNode) writeValue(record tableTreeValue) {
 		buf := node.separationDataSegment.Buffer()
 		w = newByteEncoder(newByteSliceWriter(buf), fileByteOrder)
 	}
-	for _, col := range tree.table.columns {
-		colValue := record[col.Name()]
-		err = col.write(w, colValue)
-		if err != nil {
-			bug.Panicf("tableTreeNode.writeValue: column %#v %v", col, err)
-		}
-	}
+	tree.table.writeRecordColumns(w, record)
 	node.updated = true
 }
 
-func (tree *tableTree) calcSegmentByteSize(record tableTreeValue) uint64 {
-	var segmentByteSize uint64 = tableTreeNodeHeaderByteSize
-	if keyValue, ok := record[tree.table.key.Name()]; !ok {
+func (tree *tableTree) calcSegmentByteSize(record any) uint64 {
+	var segmentByteSize uint64 = uint64(tree.table.nodeHeaderByteSize())
+	if keyValue, ok := tree.table.recordKeyValue(record); !ok {
 		bug.Panic("tableTree.calcSegmentByteSize: not found key value")
 	} else {
 		segmentByteSize += tree.table.key.byteSizeHint(keyValue)
@@ -207,15 +255,101 @@ func (tree *tableTree) calcSegmentByteSize(record tableTreeValue) uint64 {
 	if tree.table.dataSeparation.Enabled() {
 		segmentByteSize += addressByteSize
 	} else {
-		for _, col := range tree.table.columns {
-			if colValue, ok := record[col.Name()]; !ok {
-				bug.Panicf("tableTree.calcSegmentByteSize: not found value of %s", col.Name())
+		segmentByteSize += tree.table.recordColumnsByteSize(record)
+	}
+	return segmentByteSize
+}
+
+// ノードに書き込むデータのキーの値を返す
+func (table *Table) recordKeyValue(record any) (keyValue any, ok bool) {
+	switch rec := record.(type) {
+	case tableTreeValue:
+		keyValue, ok = rec[table.key.Name()]
+	case *encodedRecord:
+		keyValue, ok = rec.key, rec.key != nil
+	default:
+		bug.Panicf("Table.recordKeyValue: invalid value %#v", record)
+	}
+	return
+}
+
+// ノードに書き込むデータのカラムのデータのバイトサイズ
+func (table *Table) recordColumnsByteSize(record any) (size uint64) {
+	switch rec := record.(type) {
+	case tableTreeValue:
+		for _, col := range table.columns {
+			if colValue, ok := rec[col.Name()]; !ok {
+				bug.Panicf("Table.recordColumnsByteSize: not found value of %s", col.Name())
 			} else {
-				segmentByteSize += col.byteSizeHint(colValue)
+				size += col.byteSizeHint(colValue)
 			}
 		}
+	case *encodedRecord:
+		size = uint64(len(rec.columns))
+	default:
+		bug.Panicf("Table.recordColumnsByteSize: invalid value %#v", record)
+	}
+	return
+}
+
+// 独自のカラム型のカラムがある場合はサイズの計算と書き込みでColumnCodecのEncodeが2回呼ばれないように
+// 先にカラムのデータをカラムの形式で書き込んでおく
+func (table *Table) encodeCodecColumns(record any) any {
+	rec, ok := record.(tableTreeValue)
+	if !ok || !table.hasCodecColumn() {
+		return record
+	}
+	var buf bytes.Buffer
+	table.writeRecordColumns(newByteEncoder(&buf, fileByteOrder), rec)
+	return &encodedRecord{
+		key:     rec[table.key.Name()],
+		columns: buf.Bytes(),
+	}
+}
+
+// ColumnCodecを使うカラムがあるかどうか
+func (table *Table) hasCodecColumn() bool {
+	for _, col := range table.columns {
+		if usesColumnCodec(col) {
+			return true
+		}
+	}
+	return false
+}
+
+func usesColumnCodec(col Column) bool {
+	switch c := col.(type) {
+	case *customColumn:
+		return true
+	case *structColumn:
+		for _, sub := range c.columns {
+			if usesColumnCodec(sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ノードに書き込むデータのカラムのデータを書き込む
+// Encoderで書き込まれたデータはカラムの形式で書き込まれているのでそのまま書き込む
+func (table *Table) writeRecordColumns(w *byteEncoder, record any) {
+	switch rec := record.(type) {
+	case tableTreeValue:
+		for _, col := range table.columns {
+			err := col.write(w, rec[col.Name()])
+			if err != nil {
+				bug.Panicf("Table.writeRecordColumns: column %#v %v", col, err)
+			}
+		}
+	case *encodedRecord:
+		err := w.RawBytes(rec.columns)
+		if err != nil {
+			bug.Panicf("Table.writeRecordColumns: %v", err)
+		}
+	default:
+		bug.Panicf("Table.writeRecordColumns: invalid value %#v", record)
 	}
-	return segmentByteSize
 }
 
 func (tree *tableTree) clearCache() {
@@ -254,6 +388,26 @@ func (tree *tableTree) loadNode(addr int) *tableTreeNode {
 			return cachedNode
 		}
 	}
+	if seg, cached, ok := tree.segManager.cache.GetNode(addr); ok && cached != nil && cached.table == tree.table {
+		// デコード済みのノードがある場合はセグメントを読み直さない
+		if tree.useCache {
+			seg = seg.Clone()
+		}
+		node := &tableTreeNode{
+			tree:                  tree,
+			seg:                   seg,
+			key:                   cached.key,
+			leftChildAddress:      cached.leftChildAddress,
+			rightChildAddress:     cached.rightChildAddress,
+			height:                cached.height,
+			updated:               false,
+			separationDataAddress: cached.separationDataAddress,
+			separationDataSegment: nil,
+			version:               cached.version,
+		}
+		tree.addCache(node)
+		return node
+	}
 	seg, err := tree.segManager.LoadSegment(addr)
 	if err != nil {
 		panic(err) // たぶんファイルIOエラー、バグの場合もあるかも
@@ -277,6 +431,14 @@ func (tree *tableTree) loadNode(addr int) *tableTreeNode {
 		// TODO ちゃんと記述する
 		panic(&ErrWrongFileFormat{err.Error()}) // 不正なファイル(segmentのサイズ情報が壊れている、など)
 	}
+	var version uint64
+	if tree.table.options.rowVersion {
+		err = r.Uint64(&version)
+		if err != nil {
+			// TODO ちゃんと記述する
+			panic(&ErrWrongFileFormat{err.Error()}) // 不正なファイル(segmentのサイズ情報が壊れている、など)
+		}
+	}
 	keyValue, err := tree.table.key.read(r)
 	if err != nil {
 		// TODO ちゃんと記述する
@@ -297,50 +459,178 @@ func (tree *tableTree) loadNode(addr int) *tableTreeNode {
 	node := &tableTreeNode{
 		tree:                  tree,
 		seg:                   seg,
-		key:                   tree.table.key.toKey(keyValue),
+		key:                   tree.table.toKey(keyValue),
 		leftChildAddress:      int(leftChildAddress),
 		rightChildAddress:     int(rightChildAddress),
 		height:                int(height),
 		updated:               false,
 		separationDataAddress: int(separationDataAddress),
 		separationDataSegment: nil,
+		version:               version,
+	}
+	tree.segManager.cache.SetNode(addr, node.cachedNode())
+	if tree.useCache {
+		// キャッシュのセグメントを書き換えないように複製を使う
+		node.seg = seg.Clone()
 	}
 	tree.addCache(node)
 	return node
 }
 
+// キャッシュに保持するデコード済みのノードの情報
+func (node *tableTreeNode) cachedNode() *cachedTableNode {
+	return &cachedTableNode{
+		table:                 node.tree.table,
+		key:                   node.key,
+		leftChildAddress:      node.leftChildAddress,
+		rightChildAddress:     node.rightChildAddress,
+		height:                node.height,
+		separationDataAddress: node.separationDataAddress,
+		version:               node.version,
+	}
+}
+
+// 書き換える木ではキャッシュのセグメントを書き換えないように複製を使う
+func (tree *tableTree) loadSegment(addr int) (*segmentBuffer, error) {
+	if tree.useCache {
+		return tree.segManager.LoadWritableSegment(addr)
+	}
+	return tree.segManager.LoadSegment(addr)
+}
+
+// 書き込みがエラーやpanicで中断された場合に、この木で読み込んだり書き換えたりしたノードのセグメントをキャッシュから除去する
+// panicの場合はcatchErrorより先に呼ばれるのでエラーは設定されていないが、ファイルに書き込まれていないノードが残ることで判別する
+func (tree *tableTree) invalidateCacheOnAbort(err *error) {
+	aborted := *err != nil
+	for _, node := range tree.cache {
+		if node.updated {
+			aborted = true
+			break
+		}
+	}
+	if !aborted {
+		return
+	}
+	for addr, node := range tree.cache {
+		tree.segManager.InvalidateSegment(addr)
+		if node.separationDataAddress != nullAddress {
+			tree.segManager.InvalidateSegment(node.separationDataAddress)
+		}
+	}
+}
+
+// キーの順序(降順キーの場合は降順)に並んだデータから平衡の取れた木を下から順に組み立てる
+// 子ノードから順に作成して書き込むので各ノードの領域は順番に割り当てられる
+// versionsがnilでない場合はバージョン番号も引き継ぐ
+// 途中で失敗した場合はそれまでに作成したノードの領域を解放する
+func (tree *tableTree) buildBalancedTree(keys []avltree.Key, records []tableTreeValue, versions []uint64) (root *tableTreeNode, err error) {
+	var nodes []*tableTreeNode
+	completed := false
+	defer func() {
+		if completed && err == nil {
+			return
+		}
+		for _, node := range nodes {
+			tree.releaseBuiltNode(node)
+		}
+	}()
+	if !debugMode {
+		defer catchError(&err)
+	}
+	root, err = tree.buildBalancedSubtree(keys, records, versions, &nodes)
+	completed = true
+	return
+}
+
+// buildBalancedTreeの再帰部分
+// 作成したノードはnodesに追加する
+func (tree *tableTree) buildBalancedSubtree(keys []avltree.Key, records []tableTreeValue, versions []uint64, nodes *[]*tableTreeNode) (node *tableTreeNode, err error) {
+	if len(records) == 0 {
+		return
+	}
+	mid := len(records) / 2
+	var leftVersions, rightVersions []uint64
+	if versions != nil {
+		leftVersions, rightVersions = versions[:mid], versions[mid+1:]
+	}
+	leftChild, err := tree.buildBalancedSubtree(keys[:mid], records[:mid], leftVersions, nodes)
+	if err != nil {
+		return
+	}
+	rightChild, err := tree.buildBalancedSubtree(keys[mid+1:], records[mid+1:], rightVersions, nodes)
+	if err != nil {
+		return
+	}
+	// 要素数nの完全にバランスした部分木の高さはnのビット長に等しい
+	height := bits.Len(uint(len(records)))
+	node = unwrapTableTreeNode(tree.NewNode(leftChild.toNode(), rightChild.toNode(), height, keys[mid], records[mid]))
+	*nodes = append(*nodes, node)
+	if versions != nil && tree.table.options.rowVersion {
+		node.version = versions[mid]
+	}
+	err = node.flush()
+	return
+}
+
+// 組み立ての途中で失敗した木のノードの領域を解放する
+// 解放のエラーは組み立てのエラーを優先して捨てる(解放できなかった領域はどこからも参照されない領域になる)
+func (tree *tableTree) releaseBuiltNode(node *tableTreeNode) {
+	var err error
+	defer catchError(&err)
+	tree.ReleaseNode(node)
+}
+
 // 木から除去されたノードのリソース管理
 // github.com/neetsdkasu/avltree.NodeReleaser.ReleaseNode() の実装
 func (tree *tableTree) ReleaseNode(node avltree.RealNode) {
 	var err error
 	ttNode := unwrapTableTreeNode(node)
+	if tree.useCache {
+		// 解放した領域は別の用途で再利用されるので古いノードが書き込まれないようにキャッシュから外す
+		delete(tree.cache, ttNode.position())
+	}
 	if tree.table.dataSeparation.Enabled() {
 		if ttNode.separationDataSegment == nil {
-			err = tree.segManager.ReleaseSegmentByAddress(ttNode.separationDataAddress)
+			err = tree.releaseSegmentByAddress(ttNode.separationDataAddress)
 		} else {
-			err = tree.segManager.ReleaseSegment(ttNode.separationDataSegment)
+			err = tree.releaseSegment(ttNode.separationDataSegment)
 		}
 		if err != nil {
 			panic(err)
 		}
 	}
-	err = tree.segManager.ReleaseSegment(ttNode.seg)
+	err = tree.releaseSegment(ttNode.seg)
 	if err != nil {
 		panic(err)
 	}
 }
 
+// 木から外れたセグメントを空き領域にする
+// Batchの間はファイル上の木から参照されたままなのでBatchの終了時まで空き領域にしない
+func (tree *tableTree) releaseSegment(seg *segmentBuffer) error {
+	if tree.batching {
+		tree.releasedAddresses = append(tree.releasedAddresses, seg.Position())
+		return nil
+	}
+	return tree.segManager.ReleaseSegment(seg)
+}
+
+func (tree *tableTree) releaseSegmentByAddress(addr int) error {
+	if tree.batching {
+		tree.releasedAddresses = append(tree.releasedAddresses, addr)
+		return nil
+	}
+	return tree.segManager.ReleaseSegmentByAddress(addr)
+}
+
 // github.com/neetsdkasu/avltree.RealTree.Root() の実装
 func (tree *tableTree) Root() avltree.Node {
 	return tree.loadNode(tree.rootAddress).toNode()
 }
 
 // github.com/neetsdkasu/avltree.RealTree.NewNode(...) の実装
-func (tree *tableTree) NewNode(leftChild, rightChild avltree.Node, height int, key avltree.Key, value any) avltree.RealNode {
-	record, ok := value.(tableTreeValue)
-	if !ok {
-		bug.Panicf("tableTree.NewNode: invalid value %#v", value)
-	}
+func (tree *tableTree) NewNode(leftChild, rightChild avltree.Node, height int, key avltree.Key, record any) avltree.RealNode {
+	record = tree.table.encodeCodecColumns(record)
 	segmentByteSize := tree.calcSegmentByteSize(record)
 	seg, err := tree.segManager.EmptySegment(segmentByteSize)
 	if err != nil {
@@ -351,9 +641,9 @@ func (tree *tableTree) NewNode(leftChild, rightChild avltree.Node, height int, k
 	}
 	if debugMode {
 		// ここでのキーチェックは不要かも
-		if keyValue, ok := record[tree.table.key.Name()]; !ok {
+		if keyValue, ok := tree.table.recordKeyValue(record); !ok {
 			bug.Panic("tableTree.NewNode: no key")
-		} else if key.CompareTo(tree.table.key.toKey(keyValue)) != avltree.EqualToOtherKey {
+		} else if key.CompareTo(tree.table.toKey(keyValue)) != avltree.EqualToOtherKey {
 			bug.Panicf("tableTree.NewNode: not mutch key %v %v", key, record)
 		}
 	}
@@ -367,6 +657,10 @@ func (tree *tableTree) NewNode(leftChild, rightChild avltree.Node, height int, k
 		updated:               true,
 		separationDataAddress: nullAddress,
 		separationDataSegment: nil,
+		version:               0,
+	}
+	if tree.table.options.rowVersion {
+		node.version = 1
 	}
 	node.writeValue(record)
 	tree.addCache(node)
@@ -392,44 +686,98 @@ func (node *tableTreeNode) Key() avltree.Key {
 
 // github.com/neetsdkasu/avltree.RealNode.Value() の実装
 func (node *tableTreeNode) Value() any {
-	var err error
+	return node.readValue(nil)
+}
+
+// 指定したカラムとキーだけを読み込む（wantedがnilの場合は全てのカラムを読み込む）
+// 指定されてないカラムは読み飛ばし、指定されたカラムを全て読み込んだ時点で読み込みを終える
+// キーだけが必要な場合はデータ分離された領域の読み込みも行わない
+func (node *tableTreeNode) readValue(wanted map[string]bool) tableTreeValue {
 	table := node.tree.table
-	buf := node.seg.Buffer()[tableTreeNodeHeaderByteSize:]
-	r := newByteDecoder(bytes.NewReader(buf), fileByteOrder)
+	remaining := len(table.columns)
+	if wanted != nil {
+		remaining = len(wanted)
+		if wanted[table.key.Name()] {
+			remaining--
+		}
+	}
+	keyValue, buf := node.openColumns(remaining > 0)
 	record := make(tableTreeValue)
-	record[table.key.Name()], err = table.key.read(r)
-	if err != nil {
-		panic(err)
+	record[table.key.Name()] = keyValue
+	if remaining == 0 {
+		return record
 	}
-	if table.dataSeparation.Enabled() {
-		if node.separationDataAddress == nullAddress {
-			bug.Panic("separationDataAddress is nullAddress")
+	r := newByteDecoder(bytes.NewReader(buf), fileByteOrder)
+	for _, col := range table.columns {
+		if remaining == 0 {
+			break
 		}
-		if node.separationDataSegment == nil {
-			seg, err := node.tree.segManager.LoadSegment(node.separationDataAddress)
-			if err != nil {
-				panic(err)
-			}
-			node.separationDataSegment = seg
-		} else {
-			// まぁないと思うけど
-			err = node.separationDataSegment.LoadFullSegment()
+		if wanted != nil && !wanted[col.Name()] {
+			err := skipColumn(col, r)
 			if err != nil {
 				panic(err)
 			}
+			continue
 		}
-		buf := node.separationDataSegment.Buffer()
-		r = newByteDecoder(bytes.NewReader(buf), fileByteOrder)
-	}
-	for _, col := range table.columns {
+		var err error
 		record[col.Name()], err = col.read(r)
 		if err != nil {
 			panic(err)
 		}
+		remaining--
 	}
 	return record
 }
 
+// ノードのキーの値とカラムのデータが並んだバイト列を返す
+// withColumnsがfalseの場合はバイト列は返さない(データ分離された領域の読み込みも行わない)
+// バイト列はセグメントのバッファなので書き換えてはならない
+func (node *tableTreeNode) openColumns(withColumns bool) (keyValue any, columns []byte) {
+	table := node.tree.table
+	buf := node.seg.Buffer()[table.nodeHeaderByteSize():]
+	reader := bytes.NewReader(buf)
+	keyValue, err := table.key.read(newByteDecoder(reader, fileByteOrder))
+	if err != nil {
+		panic(err)
+	}
+	if !withColumns {
+		return
+	}
+	if !table.dataSeparation.Enabled() {
+		columns = buf[len(buf)-reader.Len():]
+		return
+	}
+	if node.separationDataAddress == nullAddress {
+		bug.Panic("separationDataAddress is nullAddress")
+	}
+	if node.separationDataSegment == nil {
+		seg, err := node.tree.loadSegment(node.separationDataAddress)
+		if err != nil {
+			panic(err)
+		}
+		node.separationDataSegment = seg
+	} else {
+		// まぁないと思うけど
+		err = node.separationDataSegment.LoadFullSegment()
+		if err != nil {
+			panic(err)
+		}
+	}
+	columns = node.separationDataSegment.Buffer()
+	return
+}
+
+// カラムのデータを読み飛ばす
+// 固定長のカラム(最小と最大のデータサイズが等しいカラム)はデータサイズ分だけ読み飛ばし、
+// それ以外のカラムは読み込んで捨てる
+func skipColumn(col Column, r *byteDecoder) error {
+	if size := col.MinimumDataByteSize(); size == col.MaximumDataByteSize() {
+		return r.Skip(int(size))
+	}
+	_, err := col.read(r)
+	return err
+}
+
 // github.com/neetsdkasu/avltree.RealNode.LeftChild() の実装
 func (node *tableTreeNode) LeftChild() avltree.Node {
 	return node.tree.loadNode(node.leftChildAddress).toNode()
@@ -441,19 +789,16 @@ func (node *tableTreeNode) RightChild() avltree.Node {
 }
 
 // github.com/neetsdkasu/avltree.RealNode.SetValue(...) の実装
-func (node *tableTreeNode) SetValue(newValue any) (_ avltree.Node) {
-	record, ok := newValue.(tableTreeValue)
-	if !ok {
-		bug.Panicf("tableTreeNode.SetValue: invalid value %#v", newValue)
-	}
+func (node *tableTreeNode) SetValue(record any) (_ avltree.Node) {
 	if debugMode {
 		// ここでのキーチェックは不要かも
-		if keyValue, ok := record[node.tree.table.key.Name()]; !ok {
+		if keyValue, ok := node.tree.table.recordKeyValue(record); !ok {
 			bug.Panic("tableTree.NewNode: no key")
-		} else if node.key.CompareTo(node.tree.table.key.toKey(keyValue)) != avltree.EqualToOtherKey {
+		} else if node.key.CompareTo(node.tree.table.toKey(keyValue)) != avltree.EqualToOtherKey {
 			bug.Panicf("tableTree.NewNode: not mutch key %v %v", node.key, record)
 		}
 	}
+	record = node.tree.table.encodeCodecColumns(record)
 	segmentByteSize := node.tree.calcSegmentByteSize(record)
 	if node.seg.Size() < int(segmentByteSize) {
 		seg, err := node.tree.segManager.EmptySegment(segmentByteSize)
@@ -461,12 +806,20 @@ func (node *tableTreeNode) SetValue(newValue any) (_ avltree.Node) {
 			panic(err)
 		}
 		node.seg, seg = seg, node.seg
-		err = node.tree.segManager.ReleaseSegment(seg)
+		if node.tree.useCache {
+			// ノードの位置が変わるのでキャッシュも付け替える
+			delete(node.tree.cache, seg.Position())
+			node.tree.addCache(node)
+		}
+		err = node.tree.releaseSegment(seg)
 		if err != nil {
 			panic(err)
 		}
 	}
 	node.writeValue(record)
+	if node.tree.table.options.rowVersion {
+		node.version++
+	}
 	node.updated = true
 	return node
 }
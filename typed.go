@@ -0,0 +1,312 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/neetsdkasu/unkodb/internal/unkodbtag"
+)
+
+// unkodbタグ付きの構造体の型Tでテーブルを操作するためのラッパー。
+// unkodb.Typedで作成する。
+// タグの解析やテーブルのカラムとの対応の確認は作成時に1度だけ行われる。
+//
+//	type Food struct {
+//		Id    unkodb.CounterType `unkodb:"id,key@Counter"`
+//		Name  string             `unkodb:"name,ShortString"`
+//		Price int64              `unkodb:"price,Int64"`
+//	}
+//	foods, _ := unkodb.Typed[Food](table)
+//	food, _ := foods.Insert(&Food{Name: "カレーパン", Price: 345})
+//	fmt.Println(food.Id, food.Name, food.Price)
+type TypedTable[T any] struct {
+	table  *Table
+	fields []typedField
+}
+
+// 構造体のフィールドとテーブルのカラムの対応
+type typedField struct {
+	index []int
+	name  string
+	col   Column
+}
+
+// 型Tでテーブルを操作するためのTypedTableを作成する。
+// Tのunkodbタグとテーブルのキーとカラムの対応を確認する。
+// Tがunkodbタグ付きの構造体ではない場合や、
+// タグの指定がテーブルのカラムと対応しない場合、テーブルのキーかカラムに対応するフィールドがない場合はErrWrongTagのエラーが返る。
+//
+//	foods, err := unkodb.Typed[Food](db.Table("food_table"))
+func Typed[T any](table *Table) (tt *TypedTable[T], err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct || !isTaggedStruct(t) {
+		err = &ErrWrongTag{fmt.Errorf("%s is not tagged struct", t)}
+		return
+	}
+	var fields []typedField
+	fields, err = newTypedFields(t, table)
+	if err != nil {
+		return
+	}
+	tt = &TypedTable[T]{
+		table:  table,
+		fields: fields,
+	}
+	return
+}
+
+func newTypedFields(t reflect.Type, table *Table) ([]typedField, error) {
+	var fields []typedField
+	m := make(map[string]bool)
+	for _, f := range reflect.VisibleFields(t) {
+		tv, ok := f.Tag.Lookup(structTagKey)
+		if !ok {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		index := unkodbtag.SeparatorIndex(tv)
+		mKey := tv
+		var (
+			ct   ColumnType = invalidColumnType
+			size uint64     = 0
+		)
+		if index >= 0 {
+			var err error
+			mKey = tv[:index]
+			_, ct, size, err = parseTagColumnType(tv[index+1:])
+			if err != nil {
+				return nil, &ErrWrongTag{fmt.Errorf("%w (field: %s)", err, f.Name)}
+			}
+		}
+		if len(mKey) == 0 {
+			mKey = f.Name
+		}
+		if _, ok = m[mKey]; ok {
+			return nil, &ErrWrongTag{fmt.Errorf(`duplicate name "%s" (field: %s)`, mKey, f.Name)}
+		}
+		m[mKey] = true
+		col := table.Column(mKey)
+		if col == nil {
+			return nil, &ErrWrongTag{fmt.Errorf(`not found column "%s" (field: %s)`, mKey, f.Name)}
+		}
+		if ct != invalidColumnType {
+			if col.Type() != ct {
+				return nil, &ErrWrongTag{fmt.Errorf("umatch column type (field: %s)", f.Name)}
+			}
+			if size > 0 && size != columnSizeParam(col) {
+				return nil, &ErrWrongTag{fmt.Errorf("umatch column type (field: %s)", f.Name)}
+			}
+		}
+		if !canConvertToColumnType(ft, col.Type(), columnSizeParam(col)) {
+			return nil, &ErrWrongTag{fmt.Errorf("cannot convert type %s to %s (field: %s)", ft, col.Type().GoTypeHint(), f.Name)}
+		}
+		fields = append(fields, typedField{
+			index: f.Index,
+			name:  mKey,
+			col:   col,
+		})
+	}
+	if !m[table.key.Name()] {
+		return nil, &ErrWrongTag{fmt.Errorf(`not found field for key "%s"`, table.key.Name())}
+	}
+	for _, col := range table.columns {
+		if !m[col.Name()] {
+			return nil, &ErrWrongTag{fmt.Errorf(`not found field for column "%s"`, col.Name())}
+		}
+	}
+	return fields, nil
+}
+
+// Tの値をInsertなどに渡すデータにする
+// TがEncoderを実装している場合はそのまま渡す(Insertなどでマップを経由せずに書き込まれる)
+func (tt *TypedTable[T]) encode(data *T) (any, error) {
+	if data == nil {
+		return nil, ErrNotFoundData
+	}
+	if _, ok := any(data).(Encoder); ok {
+		return data, nil
+	}
+	v := reflect.ValueOf(data).Elem()
+	m := make(tableTreeValue, len(tt.fields))
+	for i := range tt.fields {
+		f := &tt.fields[i]
+		value, ok := tryConvertToColumnValue(v.FieldByIndex(f.index), f.col.Type(), columnSizeParam(f.col))
+		if !ok {
+			// ポインタのフィールドがnilの場合
+			return nil, ErrNotFoundData
+		}
+		m[f.name] = value.Interface()
+	}
+	return m, nil
+}
+
+func (tt *TypedTable[T]) decode(r *Record) (*T, error) {
+	data := new(T)
+	if dec, ok := any(data).(Decoder); ok {
+		if err := decodeRecord(dec, newMapRecordDecoder(r.data)); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	v := reflect.ValueOf(data).Elem()
+	for i := range tt.fields {
+		f := &tt.fields[i]
+		fv := v.FieldByIndex(f.index)
+		for fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		err := tryMoveDataValue(fv, r.data[f.name], f.col)
+		if err != nil {
+			return nil, &ErrWrongTag{fmt.Errorf("%w (column: %s)", err, f.name)}
+		}
+	}
+	return data, nil
+}
+
+// ノードのデータをTの値にする
+// TがDecoderを実装している場合はノードのバイト列から直接読み込む
+func (tt *TypedTable[T]) decodeNode(node *tableTreeNode) (r *T, err error) {
+	if !debugMode {
+		defer catchError(&err)
+	}
+	data := new(T)
+	if dec, ok := any(data).(Decoder); ok {
+		err = decodeRecord(dec, newNodeRecordDecoder(node))
+		if err != nil {
+			return
+		}
+		r = data
+		return
+	}
+	r, err = tt.decode(node.toRecord())
+	return
+}
+
+// 対象のテーブルを返す。
+func (tt *TypedTable[T]) Table() *Table {
+	return tt.table
+}
+
+// データを挿入する。
+// 戻り値には挿入されたデータのコピーが入る（キーがCounterの場合は割り当てられたキーが入る）。
+// エラーはTableのInsertと同じ。
+func (tt *TypedTable[T]) Insert(data *T) (r *T, err error) {
+	m, err := tt.encode(data)
+	if err != nil {
+		return
+	}
+	node, err := tt.table.insertNode(m)
+	if err != nil {
+		return
+	}
+	r, err = tt.decodeNode(node)
+	return
+}
+
+// キーに対応するデータを取得する。
+// 対応するデータが存在しない場合は戻り値はnilとなりエラーもnilとなる。
+// それ以外のエラーはTableのFindと同じ。
+func (tt *TypedTable[T]) Find(key any) (r *T, err error) {
+	node, err := tt.table.findNode(key)
+	if err != nil || node == nil {
+		return
+	}
+	r, err = tt.decodeNode(node)
+	return
+}
+
+// キーに対応するデータを置き換える。
+// 戻り値には置換後のデータのコピーが入る。
+// エラーはTableのReplaceと同じ。
+func (tt *TypedTable[T]) Replace(data *T) (r *T, err error) {
+	m, err := tt.encode(data)
+	if err != nil {
+		return
+	}
+	node, err := tt.table.replaceNode(m, nil)
+	if err != nil {
+		return
+	}
+	r, err = tt.decodeNode(node)
+	return
+}
+
+// 指定したキーに対応するデータとキーを削除する。
+// エラーはTableのDeleteと同じ。
+func (tt *TypedTable[T]) Delete(key any) error {
+	return tt.table.Delete(key)
+}
+
+// iterateの呼び出しで使うコールバック関数を作る
+// ノードから型Tへの変換に失敗した場合はイテレーションを中断してエラーを記録する
+func (tt *TypedTable[T]) wrapCallback(callback func(data *T) (breakIteration bool), decodeErr *error) func(node *tableTreeNode) (breakIteration bool) {
+	return func(node *tableTreeNode) (breakIteration bool) {
+		data, err := tt.decodeNode(node)
+		if err != nil {
+			*decodeErr = err
+			return true
+		}
+		return callback(data)
+	}
+}
+
+// テーブルに存在するデータのコピーをキーの順序(降順キーの場合は降順)でコールバック関数に渡していく。
+// 注意点やエラーはTableのIterateAllと同じ。
+//
+//	foods.IterateAll(func(food *Food) (breakIteration bool) {
+//		fmt.Println(food.Id, food.Name, food.Price)
+//		return
+//	})
+func (tt *TypedTable[T]) IterateAll(callback func(data *T) (breakIteration bool)) (err error) {
+	var decodeErr error
+	err = tt.table.iterateNodes(false, tt.wrapCallback(callback, &decodeErr))
+	if err == nil {
+		err = decodeErr
+	}
+	return
+}
+
+// テーブルに存在するデータのコピーをキーの順序の逆順(降順キーの場合は昇順)でコールバック関数に渡していく。
+// 注意点やエラーはTableのIterateBackAllと同じ。
+func (tt *TypedTable[T]) IterateBackAll(callback func(data *T) (breakIteration bool)) (err error) {
+	var decodeErr error
+	err = tt.table.iterateNodes(true, tt.wrapCallback(callback, &decodeErr))
+	if err == nil {
+		err = decodeErr
+	}
+	return
+}
+
+// テーブルの指定範囲内に存在するデータのコピーをキーの順序(降順キーの場合は降順)でコールバック関数に渡していく。
+// 注意点やエラーはTableのIterateRangeと同じ。
+func (tt *TypedTable[T]) IterateRange(lowerKey, upperKey any, callback func(data *T) (breakIteration bool)) (err error) {
+	var decodeErr error
+	err = tt.table.iterateRangeNodes(false, lowerKey, upperKey, tt.wrapCallback(callback, &decodeErr))
+	if err == nil {
+		err = decodeErr
+	}
+	return
+}
+
+// テーブルの指定範囲内に存在するデータのコピーをキーの順序の逆順(降順キーの場合は昇順)でコールバック関数に渡していく。
+// 注意点やエラーはTableのIterateBackRangeと同じ。
+func (tt *TypedTable[T]) IterateBackRange(lowerKey, upperKey any, callback func(data *T) (breakIteration bool)) (err error) {
+	var decodeErr error
+	err = tt.table.iterateRangeNodes(true, lowerKey, upperKey, tt.wrapCallback(callback, &decodeErr))
+	if err == nil {
+		err = decodeErr
+	}
+	return
+}
@@ -0,0 +1,139 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTyped(t *testing.T) {
+	tempfile, err := os.Create(filepath.Join(t.TempDir(), "test.unkodb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempfile.Close()
+
+	db, err := Create(tempfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Food struct {
+		Id    CounterType `unkodb:"id,key@Counter"`
+		Name  string      `unkodb:"name,ShortString"`
+		Price *int64      `unkodb:"price,Int64"`
+		Code  [4]byte     `unkodb:"code,FixedSizeShortBytes[4]"`
+		Memo  string
+	}
+
+	table, err := db.CreateTableByTaggedStruct("foods", (*Food)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foods, err := Typed[Food](table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if foods.Table() != table {
+		t.Fatal("wrong table")
+	}
+
+	price := int64(234)
+	food, err := foods.Insert(&Food{Name: "クリームパン", Price: &price, Code: [4]byte{1, 2, 3, 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if food.Id != 1 || food.Name != "クリームパン" || *food.Price != 234 || food.Code != [4]byte{1, 2, 3, 4} {
+		t.Fatalf("wrong food %#v", food)
+	}
+
+	price = 123
+	_, err = foods.Insert(&Food{Name: "あんぱん", Price: &price})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = foods.Insert(&Food{Name: "食パン"})
+	if err != ErrNotFoundData {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	price = 987
+	food, err = foods.Replace(&Food{Id: 2, Name: "こしあんぱん", Price: &price})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if food.Name != "こしあんぱん" {
+		t.Fatalf("wrong food %#v", food)
+	}
+
+	food, err = foods.Find(CounterType(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if food == nil || food.Name != "こしあんぱん" || *food.Price != 987 {
+		t.Fatalf("wrong food %#v", food)
+	}
+
+	food, err = foods.Find(CounterType(3))
+	if err != nil || food != nil {
+		t.Fatalf("wrong result %#v %v", food, err)
+	}
+
+	var names []string
+	err = foods.IterateBackAll(func(food *Food) (_ bool) {
+		names = append(names, food.Name)
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "こしあんぱん" || names[1] != "クリームパン" {
+		t.Fatalf("wrong names %v", names)
+	}
+
+	err = foods.Delete(CounterType(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	err = foods.IterateRange(CounterType(1), CounterType(2), func(food *Food) (_ bool) {
+		count++
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("wrong count %d", count)
+	}
+
+	type WrongType struct {
+		Id    CounterType `unkodb:"id,key@Counter"`
+		Name  string      `unkodb:"name,ShortString"`
+		Price string      `unkodb:"price"`
+		Code  []byte      `unkodb:"code"`
+	}
+	_, err = Typed[WrongType](table)
+	if _, ok := err.(*ErrWrongTag); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	type MissingColumn struct {
+		Id   CounterType `unkodb:"id,key@Counter"`
+		Name string      `unkodb:"name,ShortString"`
+	}
+	_, err = Typed[MissingColumn](table)
+	if _, ok := err.(*ErrWrongTag); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+
+	_, err = Typed[int](table)
+	if _, ok := err.(*ErrWrongTag); !ok {
+		t.Fatalf("wrong error %v", err)
+	}
+}
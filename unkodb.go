@@ -13,7 +13,7 @@
 //
 // - データのサイズの変わる更新や削除を行うと使用できないゴミ領域が発生するが対処はしてない。
 //
-// - テーブルの名前やカラムを変える仕組みは無い。
+// - テーブルの名前やカラムを変える仕組みは無い（EnsureTableByTaggedStructでのカラムの追加のみ可能）。
 //
 // - 無駄なIO処理やメモリ確保が多いため大量のデータの取り扱いや頻繁なアクセスには向いてない。
 //
@@ -29,7 +29,7 @@
 //
 // - テーブル名とカラム名に使える文字は今のところ制限は設けていない。
 //
-// - カラム数はテーブルごとに100個まで。
+// - カラム数はテーブルごとに1000個まで（ただしカラム名やカラム型の情報の合計が65535バイトに収まる必要がある）。
 //
 // - 内部的にはAVL木で管理されている（AVL木の実装が正しければよいが･･･）。
 // /
@@ -373,67 +373,34 @@ func (db *UnkoDB) CreateTableByOtherTable(newTableName string, other *Table) (ta
 	if err != nil {
 		return
 	}
-	table, err = db.newTable(newTableName, other.key, other.columns, other.dataSeparation)
+	table, err = db.newTable(newTableName, other.key, other.columns, other.dataSeparation, other.options)
 	return
 }
 
-func (db *UnkoDB) newTable(name string, key keyColumn, columns []Column, dataSeparation dataSeparationState) (*Table, error) {
+func (db *UnkoDB) newTable(name string, key keyColumn, columns []Column, dataSeparation dataSeparationState, options tableOptions) (*Table, error) {
 	table := &Table{
 		db:             db,
 		name:           name,
 		key:            key,
 		columns:        columns,
 		nodeCount:      0,
-		counter:        0,
+		counter:        options.counterStartValue() - 1,
 		rootAddress:    nullAddress,
 		rootAccessor:   nil,
 		columnsSpecBuf: nil,
 		dataSeparation: dataSeparation,
+		options:        options,
 	}
 	table.rootAccessor = table
-	var b bytes.Buffer
-	w := newByteEncoder(&b, fileByteOrder)
-	// tableSpecHeader
-	{
-		err := w.Int32(int32(table.rootAddress))
-		if err != nil {
-			return nil, err
-		}
-		err = w.Int32(int32(table.nodeCount))
-		if err != nil {
-			return nil, err
-		}
-		err = w.Uint32(uint32(table.counter))
-		if err != nil {
-			return nil, err
-		}
-		err = w.Uint8(uint8(table.dataSeparation))
-		if err != nil {
-			return nil, err
-		}
-	}
-	// tableSpecKeyAndColumns
-	{
-		err := w.WriteColumnSpec(table.key)
-		if err != nil {
-			return nil, err
-		}
-		err = w.Uint8(uint8(len(table.columns)))
-		if err != nil {
-			return nil, err
-		}
-		for _, col := range table.columns {
-			err = w.WriteColumnSpec(col)
-			if err != nil {
-				return nil, err
-			}
-		}
+	columnsSpecBuf, err := table.encodeSpec()
+	if err != nil {
+		return nil, err
 	}
-	table.columnsSpecBuf = b.Bytes()
+	table.columnsSpecBuf = columnsSpecBuf
 	data := make(map[string]any)
 	data[tableListKeyName] = table.name
 	data[tableListColumnName] = table.columnsSpecBuf
-	_, err := db.tableList.Insert(data)
+	_, err = db.tableList.Insert(data)
 	if err != nil {
 		return nil, err
 	}
@@ -495,11 +462,22 @@ func (db *UnkoDB) loadTableSpec(tableName string, columnsSpecBuf []byte) (err er
 			err = &ErrWrongFileFormat{fmt.Sprintf("invalid key in %s", tableName)}
 			return
 		}
-		var colCount uint8
-		err = r.Uint8(&colCount)
+		var colCount8 uint8
+		err = r.Uint8(&colCount8)
 		if err != nil {
 			return
 		}
+		colCount := uint16(colCount8)
+		if colCount8 == tableSpecExtendedColumnCount {
+			err = r.Uint16(&colCount)
+			if err != nil {
+				return
+			}
+		}
+		if MaximumColumnCountWithoutKey < colCount {
+			err = &ErrWrongFileFormat{fmt.Sprintf("invalid column count in %s", tableName)}
+			return
+		}
 		columns = make([]Column, colCount)
 		for i := range columns {
 			col, err = r.ReadColumnSpec()
@@ -509,16 +487,27 @@ func (db *UnkoDB) loadTableSpec(tableName string, columnsSpecBuf []byte) (err er
 			columns[i] = col
 		}
 	}
+	// tableSpecOptions
+	var options tableOptions
+	options, err = readTableOptions(r)
+	if err != nil {
+		return
+	}
+	if options.keyCollation != BinaryCollation && !setKeyCollation(key, options.keyCollation) {
+		err = &ErrWrongFileFormat{fmt.Sprintf("invalid key collation in %s", tableName)}
+		return
+	}
 	table := &Table{
 		db:             db,
 		name:           tableName,
 		key:            key,
 		columns:        columns,
 		nodeCount:      int(nodeCount),
-		counter:        uint(counter),
+		counter:        uint64(counter) | uint64(options.counterHigh)<<32,
 		rootAddress:    int(rootAddress),
 		columnsSpecBuf: columnsSpecBuf,
 		dataSeparation: dataSeparationState(dataSeparation),
+		options:        options,
 	}
 	table.rootAccessor = table
 	db.tables = append(db.tables, table)
@@ -555,3 +544,15 @@ func (db *UnkoDB) initTableListTable() error {
 	})
 	return err
 }
+
+// ファイルから読み込んだノードなどを操作をまたいで保持するキャッシュのメモリの上限(バイト数)を設定する。
+// 初期値はDefaultCacheByteSizeで、0を指定するとキャッシュを使わなくなる。
+// キャッシュによって木の根に近いノードなどを毎回ファイルから読み直さずに済むようになる。
+// キャッシュはデータの書き込みや領域の解放に合わせて更新されるため、
+// UnkoDBを経由せずにファイルを書き換えた場合はキャッシュの内容が古くなるので注意。
+//
+//	db, _ := unkodb.Open(file)
+//	db.SetCacheByteSize(16 << 20) // 16MiB
+func (db *UnkoDB) SetCacheByteSize(byteSize int) {
+	db.segManager.SetCacheByteSize(byteSize)
+}
@@ -0,0 +1,74 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"encoding/hex"
+)
+
+// カラム型のUUIDで用いるGoの型。
+// 16バイトのUUIDをそのままのバイト順で保持する。
+// キーとして使う場合はバイト列としての順序(bytes.Compare)が使用される。
+// 文字列にすると"xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"の形式になる。
+//
+//	id, _ := unkodb.ParseUUID("123e4567-e89b-12d3-a456-426614174000")
+type UUID [uuidByteSize]byte
+
+// "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"の形式もしくはハイフン無しの32桁の16進数の文字列からUUIDを作る。
+// 不正な文字列の場合はErrInvalidUUIDのエラーが返る。
+func ParseUUID(s string) (u UUID, err error) {
+	err = u.UnmarshalText([]byte(s))
+	return
+}
+
+// "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"の形式の文字列にして返す。
+func (u UUID) String() string {
+	text, _ := u.MarshalText()
+	return string(text)
+}
+
+// encoding.TextMarshalerの実装。
+// "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"の形式のテキストにする。
+func (u UUID) MarshalText() ([]byte, error) {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return buf, nil
+}
+
+// encoding.TextUnmarshalerの実装。
+// "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"の形式もしくはハイフン無しの32桁の16進数のテキストを読み込む。
+// 不正なテキストの場合はErrInvalidUUIDのエラーが返る。
+func (u *UUID) UnmarshalText(text []byte) error {
+	var digits []byte
+	switch len(text) {
+	default:
+		return ErrInvalidUUID
+	case 32:
+		digits = text
+	case 36:
+		for i, c := range text {
+			if i == 8 || i == 13 || i == 18 || i == 23 {
+				if c != '-' {
+					return ErrInvalidUUID
+				}
+			} else {
+				digits = append(digits, c)
+			}
+		}
+	}
+	var tmp UUID
+	if _, err := hex.Decode(tmp[:], digits); err != nil {
+		return ErrInvalidUUID
+	}
+	*u = tmp
+	return nil
+}
@@ -0,0 +1,35 @@
+// unkodb
+// author: Leonardone @ NEETSDKASU
+
+package unkodb
+
+import (
+	"testing"
+)
+
+func TestUUID(t *testing.T) {
+	const s = "123e4567-e89b-12d3-a456-426614174000"
+	u, err := ParseUUID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := UUID{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	if u != expected {
+		t.Fatalf("wrong uuid %v", [16]byte(u))
+	}
+	if u.String() != s {
+		t.Fatalf("wrong string %s", u)
+	}
+	u2, err := ParseUUID("123E4567E89B12D3A456426614174000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u2 != u {
+		t.Fatalf("wrong uuid %s", u2)
+	}
+	for _, w := range []string{"", "123e4567-e89b-12d3-a456-42661417400", "123e4567-e89b-12d3-a456_426614174000", "123e4567-e89b-12d3-a456-42661417400g"} {
+		if _, err := ParseUUID(w); err != ErrInvalidUUID {
+			t.Fatalf("%q: wrong error %v", w, err)
+		}
+	}
+}